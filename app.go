@@ -5,13 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/curt-hash/mkvbot/pkg/eject"
+	"github.com/curt-hash/mkvbot/pkg/ffmpeg"
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/hook"
+	"github.com/curt-hash/mkvbot/pkg/jobs"
 	"github.com/curt-hash/mkvbot/pkg/makemkv"
 	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
 	"github.com/curt-hash/mkvbot/pkg/moviedb"
@@ -19,22 +26,121 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	// maxDiscFailures is the number of times in a row that the backup of a
+	// disc may fail before it is given up.
+	maxDiscFailures = 3
+
+	// defaultScanDelay is the delay between the scans of a drive.
+	defaultScanDelay = 2 * time.Second
+)
+
 type (
 	applicationConfig struct {
 		outputDirPath              string
+		nameTemplate               string
+		tv                         bool
+		tvNameTemplate             string
+		extras                     bool
+		extrasMinLength            time.Duration
+		backupDisc                 bool
+		skipVerify                 bool
+		skipTags                   bool
+		skipSidecars               bool
+		movieDBs                   []string
+		tmdbAPIKey                 string
+		imdbDatasetIndexPath       string
+		imdbDatasetTypes           []string
+		movieDBCacheDir            string
+		movieDBCacheTTL            time.Duration
 		makemkvConfig              *makemkv.Config
 		debug                      bool
 		quiet                      bool
 		bestTitleHeuristicsWeights map[string]int64
 		askForTitle                bool
 		logFilePath                string
+		historyFilePath            string
+		headless                   bool
+		headlessPolicy             *headlessPolicy
+		hooks                      []*hook.Config
+		transcodePreset            string
+		ffmpegPath                 string
+		keepOriginal               bool
+		libraryDirPath             string
+		notify                     bool
+		jobsFilePath               string
+		jobWorkers                 int
+		scanDelay                  time.Duration
 	}
 
 	application struct {
-		cfg     *applicationConfig
-		con     *makemkv.Con
-		tui     *textUserInterface
-		logFile *os.File
+		cfg            *applicationConfig
+		con            *makemkv.Con
+		ui             userInterface
+		logFile        *os.File
+		history        *history.Store
+		nameTemplate   *nameTemplate
+		tvNameTemplate *nameTemplate
+		movieDBs       []*namedMovieDB
+		jobs           *jobs.Queue
+		jobsView       *jobsView
+		ffmpeg         func() (*ffmpeg.FFmpeg, error)
+	}
+
+	// userInterface is implemented by the text user interface and by the
+	// headless interface.
+	userInterface interface {
+		run() error
+		Stop()
+		waitForInterrupt()
+		beep()
+		logWriter() io.Writer
+		setStatus(format string, args ...any)
+		addView(name string) driveUI
+		showJobs(q *jobs.Queue)
+		updateJobs()
+	}
+
+	// discSource is where discs are read from: a drive or, for the process
+	// command, an ISO image or disc folder.
+	discSource struct {
+		makemkv.Source
+
+		// name is the name of the drive or the path of the ISO image or disc
+		// folder.
+		name string
+
+		// volume is the volume of the drive. It is empty for ISO images and disc
+		// folders, which cannot be ejected.
+		volume string
+
+		// failures is the number of consecutive failed backups of the disc
+		// with the fingerprint failedFingerprint.
+		failedFingerprint string
+		failures          int
+	}
+
+	// movieSearchFunc searches the movie databases.
+	movieSearchFunc func(ctx context.Context, q string) ([]*moviedb.MovieMetadata, error)
+
+	// driveUI shows the progress of a drive's backup loop (or of the process
+	// command) and asks for the decisions that cannot be made automatically.
+	driveUI interface {
+		setSource(src *discSource)
+		setStatus(format string, args ...any)
+		setTask(format string, args ...any)
+		setSubtask(format string, args ...any)
+		setProgress(progress float64)
+		setDiscInfo(info makemkv.Info)
+		setMovieMetadata(md *moviedb.MovieMetadata)
+		setTitleInfo(title *makemkv.Title)
+		getMovieTitleForSearch(ctx context.Context, q string) (string, error)
+		chooseMovieMetadata(ctx context.Context, q string, results []*moviedb.MovieMetadata, search movieSearchFunc) (*moviedb.MovieMetadata, error)
+		getMovieMetadata(ctx context.Context, q string, md *moviedb.MovieMetadata) (*moviedb.MovieMetadata, error)
+		getBestTitle(ctx context.Context, choices []*makemkv.Title) (*makemkv.Title, error)
+		getDuplicateAction(ctx context.Context, prior []*history.Record) (string, string, error)
+		classifyExtras(ctx context.Context, extras []*makemkv.Title) ([]string, error)
+		getEpisodeNumbering(ctx context.Context, series *moviedb.MovieMetadata, episodes []*makemkv.Title, season, firstEpisode int) (int, int, error)
 	}
 )
 
@@ -49,6 +155,36 @@ func (cfg *applicationConfig) validate() error {
 		}
 	}
 
+	if cfg.backupDisc && (cfg.tv || cfg.extras) {
+		return errors.New("a disc backup cannot be combined with TV mode or extras")
+	}
+
+	if cfg.transcodePreset != "" {
+		if cfg.backupDisc {
+			return errors.New("a disc backup cannot be transcoded")
+		}
+
+		if _, err := ffmpeg.GetPreset(cfg.transcodePreset); err != nil {
+			return fmt.Errorf("transcode: %w", err)
+		}
+	}
+
+	if cfg.libraryDirPath != "" {
+		if cfg.backupDisc {
+			return errors.New("a disc backup cannot be moved to a library")
+		}
+
+		if _, err := os.Stat(cfg.libraryDirPath); err != nil {
+			return fmt.Errorf("stat %q: %w", cfg.libraryDirPath, err)
+		}
+	}
+
+	if cfg.headless {
+		if err := cfg.headlessPolicy.validate(); err != nil {
+			return fmt.Errorf("headless policy: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -57,14 +193,45 @@ func newApplication(cfg *applicationConfig) (*application, error) {
 		return nil, fmt.Errorf("validate config %#+v: %w", cfg, err)
 	}
 
+	nameTemplate, err := newNameTemplate(cfg.nameTemplate, nameTemplatePresets)
+	if err != nil {
+		return nil, err
+	}
+
+	tvNameTemplate, err := newNameTemplate(cfg.tvNameTemplate, tvNameTemplatePresets)
+	if err != nil {
+		return nil, fmt.Errorf("tv: %w", err)
+	}
+
+	movieDBs, err := newMovieDBs(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("initialize movie databases: %w", err)
+	}
+
 	con, err := makemkv.New(cfg.makemkvConfig)
 	if err != nil {
 		return nil, fmt.Errorf("initialize makemkv controller: %w", err)
 	}
 
-	tui := newTextUserInterface(newBeeper(!cfg.quiet))
+	// Replayed rips are empty placeholder files, which can be neither verified
+	// nor tagged.
+	if con.Replaying() {
+		cfg.skipVerify, cfg.skipTags = true, true
+	}
 
-	logWriters := []io.Writer{tui.logBox}
+	store, err := history.Open(cfg.historyFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+
+	var ui userInterface
+	if cfg.headless {
+		ui = newHeadlessInterface(newBeeper(!cfg.quiet), cfg.headlessPolicy)
+	} else {
+		ui = newTextUserInterface(newBeeper(!cfg.quiet))
+	}
+
+	logWriters := []io.Writer{ui.logWriter()}
 	var logFile *os.File
 	if cfg.logFilePath != "" {
 		if logFile, err = os.OpenFile(cfg.logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
@@ -75,15 +242,38 @@ func newApplication(cfg *applicationConfig) (*application, error) {
 	}
 	setDefaultLogger(logWriters, cfg.debug)
 
-	return &application{
-		cfg:     cfg,
-		con:     con,
-		tui:     tui,
-		logFile: logFile,
-	}, nil
+	// ffmpeg is only needed for transcoding, which may also be left over in
+	// the job queue from a previous run.
+	newFFmpeg := sync.OnceValues(func() (*ffmpeg.FFmpeg, error) {
+		return ffmpeg.New(&ffmpeg.Config{ExePath: cfg.ffmpegPath})
+	})
+	if cfg.transcodePreset != "" {
+		if _, err := newFFmpeg(); err != nil {
+			return nil, fmt.Errorf("initialize ffmpeg: %w", err)
+		}
+	}
+
+	app := &application{
+		cfg:            cfg,
+		con:            con,
+		ui:             ui,
+		logFile:        logFile,
+		history:        store,
+		nameTemplate:   nameTemplate,
+		tvNameTemplate: tvNameTemplate,
+		movieDBs:       movieDBs,
+		ffmpeg:         newFFmpeg,
+	}
+
+	if err := app.openJobQueue(); err != nil {
+		return nil, fmt.Errorf("open job queue: %w", err)
+	}
+
+	return app, nil
 }
 
-func (app *application) run(ctx context.Context) (err error) {
+// run runs the user interface until the loop returns, e.g., doBackupLoop.
+func (app *application) run(ctx context.Context, loop func(ctx context.Context) error) (err error) {
 	defer func() {
 		if app.logFile != nil {
 			err = errors.Join(err, app.logFile.Close())
@@ -92,43 +282,103 @@ func (app *application) run(ctx context.Context) (err error) {
 
 	ctx, cancel := context.WithCancel(ctx)
 	go func() {
-		app.tui.waitForInterrupt()
+		app.ui.waitForInterrupt()
 		cancel()
 	}()
 
 	var tasks errgroup.Group
-	tasks.Go(app.tui.run)
+	tasks.Go(app.ui.run)
+
+	app.showJobs()
 
-	err = app.doBackupLoop(ctx)
-	app.tui.Stop()
+	var workers sync.WaitGroup
+	workers.Go(func() {
+		app.jobs.Run(ctx, app.cfg.jobWorkers)
+	})
+
+	err = loop(ctx)
+
+	// Finish the queued jobs, e.g., after the process command, unless
+	// interrupted. Interrupted jobs run again on the next start.
+	app.jobs.Close()
+	workers.Wait()
+	if n := app.countUnfinishedJobs(); n > 0 {
+		slog.Warn("jobs are left in the queue", "count", n, "path", app.jobs.Path())
+	}
+
+	app.ui.Stop()
 	return errors.Join(err, tasks.Wait())
 }
 
 func (app *application) doBackupLoop(ctx context.Context) error {
-	app.tui.setStatus("Inspecting drives")
-	drive, err := app.getDrive(ctx)
+	app.ui.setStatus("Inspecting drives")
+	drives, err := app.getDrives(ctx)
 	if err != nil {
 		return err
 	}
 
-	app.tui.setDriveInfo(drive.DriveName.String(), drive.VolumeName.String())
+	var loops errgroup.Group
+	for _, drive := range drives {
+		src := &discSource{
+			Source: makemkv.DriveSource(drive.Index),
+			name:   drive.DriveName.String(),
+			volume: drive.VolumeName.String(),
+		}
+
+		view := app.ui.addView(fmt.Sprintf("Drive %d", drive.Index))
+		view.setSource(src)
+		loops.Go(func() error {
+			return app.doDriveBackupLoop(ctx, src, view)
+		})
+	}
+
+	return loops.Wait()
+}
+
+func (app *application) doDriveBackupLoop(ctx context.Context, src *discSource, view driveUI) error {
+	if app.hasTray(src) {
+		view.setStatus("Closing tray")
+		if err := eject.CloseTray(ctx, src.volume); err != nil && !errors.Is(err, eject.ErrNotSupported) {
+			slog.Warn("failed to close tray", "disc", src, "err", err)
+		}
+	}
 
+	// Waiting for a disc is much cheaper than scanning the drive, where the
+	// drive status is supported.
+	wait := app.hasTray(src)
 	for ctx.Err() == nil {
-		if err := app.tryBackupBestTitle(ctx, drive); err != nil {
-			slog.Error(err.Error())
+		if wait {
+			view.setStatus("Waiting for a disc")
+			if err := eject.WaitForMedia(ctx, src.volume); err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+
+				if !errors.Is(err, eject.ErrNotSupported) {
+					slog.Warn("failed to get drive status; scanning periodically instead", "disc", src, "err", err)
+				}
+				wait = false
+			}
+		}
+
+		if err := app.tryBackup(ctx, src, view); errors.Is(err, makemkv.ErrNoTranscript) {
+			slog.Info("every transcript was replayed", "disc", src)
+			return nil
+		} else if err != nil {
+			slog.Error(err.Error(), "disc", src)
 		}
 
-		app.tui.setStatus("Sleeping for a moment")
+		view.setStatus("Sleeping for a moment")
 		select {
 		case <-ctx.Done():
-		case <-time.After(2 * time.Second):
+		case <-time.After(app.cfg.scanDelay):
 		}
 	}
 
 	return nil
 }
 
-func (app *application) getDrive(ctx context.Context) (*makemkv.DriveScan, error) {
+func (app *application) getDrives(ctx context.Context) ([]*makemkv.DriveScan, error) {
 	iter, err := app.con.ListDrives(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("list drives: %w", err)
@@ -151,77 +401,142 @@ func (app *application) getDrive(ctx context.Context) (*makemkv.DriveScan, error
 		return nil, fmt.Errorf("list drives: %w", err)
 	}
 
-	switch len(drives) {
-	case 0:
+	if len(drives) == 0 {
 		return nil, fmt.Errorf("no drives found")
-	case 1:
-	default:
-		slog.Warn("multiple drives not supported yet; using first drive only")
 	}
 
-	return drives[0], nil
+	for _, drive := range drives {
+		slog.Info("found drive", "drive", drive.Index, "name", drive.DriveName, "volume", drive.VolumeName)
+	}
+
+	return drives, nil
 }
 
-func (app *application) tryBackupBestTitle(ctx context.Context, drive *makemkv.DriveScan) error {
+// tryBackup scans the disc in the drive, if any, and backs up the best title
+// or, in TV mode, every episode.
+func (app *application) tryBackup(ctx context.Context, src *discSource, view driveUI) error {
 	defer func() {
-		app.tui.setDiscInfo(nil)
-		app.tui.setMovieMetadata(nil)
-		app.tui.setTitleInfo(nil)
+		view.setDiscInfo(nil)
+		view.setMovieMetadata(nil)
+		view.setTitleInfo(nil)
 	}()
 
-	app.tui.setStatus("Scanning drive %q", drive.VolumeName)
-	iter, err := app.con.ScanDrive(ctx, drive.Index)
+	disc, err := app.scanDisc(ctx, src, view)
+	if err != nil {
+		return err
+	}
+
+	if disc.TitleCount() == 0 {
+		// An empty drive is the normal case, but ISO images and disc folders
+		// should have titles.
+		if src.Type != makemkv.SourceTypeDrive {
+			return errors.New("no titles found")
+		}
+
+		slog.Debug("no titles found", "disc", src)
+		src.failedFingerprint, src.failures = "", 0
+		return nil
+	}
+
+	// A disc that keeps failing would most likely fail again, so it is left
+	// alone until it is changed.
+	fingerprint := disc.Fingerprint()
+	if fingerprint != src.failedFingerprint {
+		src.failedFingerprint, src.failures = fingerprint, 0
+	}
+	if src.failures >= maxDiscFailures {
+		slog.Debug("skipping disc that failed too often", "disc", src, "fingerprint", fingerprint)
+		return nil
+	}
+
+	view.setDiscInfo(disc.Info)
+
+	switch {
+	case app.cfg.backupDisc:
+		err = app.backupDisc(ctx, src, view, disc)
+	case app.cfg.tv:
+		err = app.backupEpisodes(ctx, src, view, disc)
+	default:
+		err = app.backupBestTitle(ctx, src, view, disc)
+	}
+	if err == nil || ctx.Err() != nil {
+		src.failures = 0
+		return err
+	}
+
+	if src.failures++; src.failures < maxDiscFailures {
+		return err
+	}
+
+	// The disc is ejected to show that mkvbot gave up on it. Once it is out,
+	// putting it back in is a request to try again.
+	slog.Error(err.Error(), "disc", src)
+	err = fmt.Errorf("giving up on the disc after %d failures", src.failures)
+	if app.hasTray(src) {
+		if ejectErr := app.ejectDisc(ctx, src, view); ejectErr != nil {
+			err = fmt.Errorf("%w: %w", err, ejectErr)
+		} else {
+			src.failures = 0
+		}
+	}
+
+	return err
+}
+
+func (app *application) scanDisc(ctx context.Context, src *discSource, view driveUI) (*makemkv.Disc, error) {
+	view.setStatus("Scanning %s", src)
+	iter, err := app.con.Scan(ctx, src.Source)
 	if err != nil {
-		return fmt.Errorf("scan drive %q: %w", drive.VolumeName, err)
+		return nil, fmt.Errorf("scan %s: %w", src, err)
 	}
 
 	for line, err := range iter.Seq {
 		if err != nil {
-			slog.Error(err.Error())
+			slog.Error(err.Error(), "disc", src)
 			continue
 		}
 
 		switch {
 		case line.CurrentTask != nil:
-			app.tui.setTask("%s", line.CurrentTask.Task.Name)
+			view.setTask("%s", line.CurrentTask.Task.Name)
 		case line.CurrentSubtask != nil:
-			app.tui.setSubtask("%s", line.CurrentSubtask.Task.Name)
+			view.setSubtask("%s", line.CurrentSubtask.Task.Name)
 		case line.Progress != nil:
-			app.tui.setProgress(line.Progress.TaskProgress())
+			view.setProgress(line.Progress.TaskProgress())
 		case line.Message != nil:
-			slog.Debug(line.Message.Message.String(), "source", "makemkv")
+			slog.Debug(line.Message.Message.String(), "source", "makemkv", "disc", src)
 		}
 	}
 
-	disc, err := iter.GetResult()
-	if err != nil {
-		return err
-	}
+	return iter.GetResult()
+}
 
-	if disc.TitleCount() == 0 {
-		slog.Debug("no titles found")
-		return nil
+func (app *application) backupBestTitle(ctx context.Context, src *discSource, view driveUI, disc *makemkv.Disc) (err error) {
+	rec := &history.Record{
+		StartTime:  time.Now(),
+		Drive:      src.name,
+		DiscName:   disc.GetAttrDefault(defs.Name, ""),
+		TitleIndex: -1,
 	}
+	defer func() {
+		app.addHistoryRecord(rec, err)
+	}()
 
-	app.tui.setDiscInfo(disc.Info)
-
-	app.tui.setStatus("Getting movie metadata")
-	movieMetadata, err := app.getMovieMetadata(ctx, disc)
+	movieMetadata, dstPath, err := app.identifyDisc(ctx, src, view, disc, rec)
 	if err != nil {
-		return fmt.Errorf("get movie metadata: %w", err)
+		return err
+	}
+	if rec.Outcome == history.OutcomeSkipped {
+		return app.ejectDisc(ctx, src, view)
 	}
-	app.tui.setMovieMetadata(movieMetadata)
-	fileName := makeFileName(movieMetadata)
+	overwrite := dstPath != ""
 
-	var (
-		title *makemkv.Title
-		best  []*makemkv.Title
-	)
-	app.tui.setStatus("Finding best title")
+	var title *makemkv.Title
+	view.setStatus("Finding best title")
+	best, scores := findBestTitle(disc, app.cfg.bestTitleHeuristicsWeights)
+	rec.Scores = scores
 	if app.cfg.askForTitle {
 		best = disc.Titles
-	} else {
-		best = findBestTitle(disc, app.cfg.bestTitleHeuristicsWeights)
 	}
 	switch len(best) {
 	case 0:
@@ -229,78 +544,250 @@ func (app *application) tryBackupBestTitle(ctx context.Context, drive *makemkv.D
 	case 1:
 		title = best[0]
 	default:
-		if title, err = app.tui.getBestTitle(ctx, best); err != nil {
+		if title, err = view.getBestTitle(ctx, best); err != nil {
 			return fmt.Errorf("get best title: %w", err)
 		}
 	}
-	app.tui.setTitleInfo(title)
+	view.setTitleInfo(title)
+	rec.TitleIndex = title.Index
 
-	app.tui.setStatus("Backing up title")
-	if err := app.backupTitle(ctx, drive, title, fileName); err != nil {
+	if dstPath == "" {
+		if dstPath, err = app.outputPath(app.nameTemplate, newNameData(movieMetadata, rec.Edition, disc, title)); err != nil {
+			return err
+		}
+	}
+	rec.OutputPath = dstPath
+
+	// Ask about the extras before the long wait for the main title.
+	var (
+		extras     []*makemkv.Title
+		categories []string
+	)
+	if app.cfg.extras {
+		if extras = findExtras(disc, title, app.cfg.extrasMinLength); len(extras) > 0 {
+			view.setStatus("Classifying extras")
+			if categories, err = view.classifyExtras(ctx, extras); err != nil {
+				return fmt.Errorf("classify extras: %w", err)
+			}
+		}
+	}
+
+	view.setStatus("Backing up title")
+	if err := app.backupTitle(ctx, src, view, title, dstPath, overwrite); err != nil {
 		return fmt.Errorf("backup longest title: %w", err)
 	}
+	rec.Outcome = history.OutcomeSuccess
+	app.tagTitle(src, dstPath, titleTags(rec, movieMetadata, ""))
+	sidecars := app.writeSidecars(src, rec, movieMetadata, disc, title, "")
+
+	var rippedExtras []*rippedTitle
+	if len(extras) > 0 {
+		rippedExtras = app.backupExtras(ctx, src, view, extras, categories, dstPath, overwrite)
+		for _, extra := range rippedExtras {
+			rec.ExtraPaths = append(rec.ExtraPaths, extra.path)
+		}
+		view.setTitleInfo(title)
+	}
+
+	// The hooks update the record once they have run.
+	app.addHistoryRecord(rec, nil)
+	app.queueFollowUpJobs(src, title, dstPath, sidecars, newHookEvent(rec, movieMetadata, title))
+	for _, extra := range rippedExtras {
+		app.queueFollowUpJobs(src, extra.title, extra.path, nil, nil)
+	}
+
+	if err := app.ejectDisc(ctx, src, view); err != nil {
+		return err
+	}
+
+	app.ui.beep()
+	return nil
+}
+
+// identifyDisc sets the fingerprint, movie metadata and edition of rec. If the
+// disc was ripped before, the user decides whether to skip it, in which case
+// rec.Outcome is set to OutcomeSkipped, or to rip it again with the metadata of
+// the last rip. If the last rip is to be replaced, its output path is
+// returned. rec.DiscBackup must be set.
+func (app *application) identifyDisc(ctx context.Context, src *discSource, view driveUI, disc *makemkv.Disc, rec *history.Record) (*moviedb.MovieMetadata, string, error) {
+	rec.Fingerprint = disc.Fingerprint()
+	prior, err := app.history.FindRipped(rec.Fingerprint)
+	if err != nil {
+		return nil, "", fmt.Errorf("find previous rips: %w", err)
+	}
+
+	var (
+		movieMetadata *moviedb.MovieMetadata
+		replacePath   string
+	)
+	if len(prior) > 0 {
+		last := prior[len(prior)-1]
+		slog.Info("disc was ripped before", "disc", src, "fingerprint", rec.Fingerprint, "path", last.OutputPath, "count", len(prior))
+
+		view.setStatus("Disc was ripped before")
+		action, edition, err := view.getDuplicateAction(ctx, prior)
+		if err != nil {
+			return nil, "", fmt.Errorf("get duplicate action: %w", err)
+		}
+
+		movieMetadata = &moviedb.MovieMetadata{
+			Name:   last.Name,
+			Year:   last.Year,
+			ID:     last.MovieID,
+			Source: metadataSourceHistory,
+		}
+
+		switch action {
+		case duplicateActionSkip:
+			rec.Outcome = history.OutcomeSkipped
+		case duplicateActionRerip:
+			// A title cannot replace a disc backup or vice versa.
+			if last.DiscBackup == rec.DiscBackup {
+				replacePath = last.OutputPath
+			}
+		case duplicateActionNewEdition:
+			rec.Edition = edition
+		}
+	} else {
+		view.setStatus("Getting movie metadata")
+		if movieMetadata, err = app.getMovieMetadata(ctx, disc, view); err != nil {
+			return nil, "", fmt.Errorf("get movie metadata: %w", err)
+		}
+	}
+	view.setMovieMetadata(movieMetadata)
+	rec.Name, rec.Year, rec.MovieID = movieMetadata.Name, movieMetadata.Year, movieMetadata.ID
+
+	return movieMetadata, replacePath, nil
+}
+
+// lockTray locks the tray of the drive, if the source is a drive, so the disc
+// cannot be ejected while it is read. It returns a function that unlocks the
+// tray. Failures are only logged, since the lock is merely a precaution.
+func (app *application) lockTray(ctx context.Context, src *discSource) (unlock func()) {
+	if !app.hasTray(src) {
+		return func() {}
+	}
+
+	setLocked := func(ctx context.Context, locked bool) {
+		if err := eject.SetLocked(ctx, src.volume, locked); err != nil && !errors.Is(err, eject.ErrNotSupported) {
+			slog.Warn("failed to lock or unlock tray", "disc", src, "locked", locked, "err", err)
+		}
+	}
+
+	setLocked(ctx, true)
+	return func() {
+		// The tray must be unlocked even if the backup was canceled.
+		setLocked(context.WithoutCancel(ctx), false)
+	}
+}
+
+// hasTray reports whether the tray of the source's drive may be operated. ISO
+// images and disc folders have no tray, and the drive is not touched while
+// transcripts are replayed.
+func (app *application) hasTray(src *discSource) bool {
+	return src.volume != "" && !app.con.Replaying()
+}
 
-	app.tui.setStatus("Ejecting disc")
-	if err := eject.Eject(ctx, drive.VolumeName.String()); err != nil {
+// ejectDisc ejects the disc if the source is a drive.
+func (app *application) ejectDisc(ctx context.Context, src *discSource, view driveUI) error {
+	if !app.hasTray(src) {
+		return nil
+	}
+
+	view.setStatus("Ejecting disc")
+	if err := eject.Eject(ctx, src.volume); err != nil {
 		return fmt.Errorf("eject disc: %w", err)
 	}
 
-	app.tui.beep()
 	return nil
 }
 
-func (app *application) getMovieMetadata(ctx context.Context, disc *makemkv.Disc) (*moviedb.MovieMetadata, error) {
+func (app *application) getMovieMetadata(ctx context.Context, disc *makemkv.Disc, view driveUI) (*moviedb.MovieMetadata, error) {
 	name, err := disc.GetAttr(defs.Name)
 	if err != nil {
 		return nil, fmt.Errorf("get disc attr %s: %w", defs.Name, err)
 	}
 
 	q := regexp.MustCompile("[^a-zA-Z0-9 ]+").ReplaceAllString(name, " ")
-	q, err = app.tui.getMovieTitleForSearch(ctx, q)
+	return app.lookupMetadata(ctx, view, q, app.searchMovieDB)
+}
+
+// lookupMetadata lets the user correct the query q, searches with it and lets
+// the user choose and correct the result.
+func (app *application) lookupMetadata(ctx context.Context, view driveUI, q string, search movieSearchFunc) (*moviedb.MovieMetadata, error) {
+	q, err := view.getMovieTitleForSearch(ctx, q)
 	if err != nil {
 		return nil, err
 	}
 
-	metadata, err := searchMovieDB(q)
+	results, err := search(ctx, q)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	} else if err != nil {
+		slog.Warn("movie metadata lookup failed", "err", err, "query", q)
+	}
+
+	metadata, err := view.chooseMovieMetadata(ctx, q, results, search)
 	if err != nil {
-		slog.Warn("movie metadata lookup failed", "err", err)
-		metadata = &moviedb.MovieMetadata{}
+		return nil, err
 	}
 
-	return app.tui.getMovieMetadata(ctx, metadata)
+	return view.getMovieMetadata(ctx, q, metadata)
 }
 
-func (app *application) backupTitle(ctx context.Context, drive *makemkv.DriveScan, title *makemkv.Title, fileName string) error {
-	dstDir := filepath.Join(app.cfg.outputDirPath, fileName)
-	dstPath := filepath.Join(dstDir, fmt.Sprintf("%s.mkv", fileName))
-	if _, err := os.Stat(dstPath); err == nil {
-		return fmt.Errorf("output file exists: %q", dstPath)
+// addHistoryRecord completes the record and adds it to the history file. The
+// outcome is determined by err unless it was already decided, e.g., if the
+// title was backed up but the disc could not be ejected. A record that was
+// added already, before its follow-up jobs were queued, is not added again.
+func (app *application) addHistoryRecord(rec *history.Record, err error) {
+	if !rec.EndTime.IsZero() {
+		return
+	}
+
+	rec.EndTime = time.Now()
+	switch {
+	case rec.Outcome != "":
+	case err == nil:
+		rec.Outcome = history.OutcomeSuccess
+	case errors.Is(err, context.Canceled):
+		rec.Outcome = history.OutcomeCanceled
+	default:
+		rec.Outcome = history.OutcomeFailure
+		rec.Error = err.Error()
 	}
 
-	app.tui.setStatus("Backing up title to %s", dstDir)
-	seq, err := app.con.BackupTitle(ctx, drive.Index, title.Index, dstDir)
+	if err := app.history.Add(rec); err != nil {
+		slog.Error("failed to add history record", "err", err, "path", app.history.Path())
+	}
+}
+
+// outputPath returns the path of the output file according to the name
+// template.
+func (app *application) outputPath(tmpl *nameTemplate, data *nameData) (string, error) {
+	name, err := tmpl.render(data)
 	if err != nil {
-		return fmt.Errorf("backup title %d to %q: %w", title.Index, dstDir, err)
+		return "", err
 	}
 
-	for line, err := range seq {
-		if err != nil {
-			slog.Error(err.Error())
-			continue
-		}
+	return filepath.Join(app.cfg.outputDirPath, name), nil
+}
 
-		switch {
-		case line.CurrentTask != nil:
-			app.tui.setTask("%s", line.CurrentTask.Task.Name)
-		case line.CurrentSubtask != nil:
-			app.tui.setSubtask("%s", line.CurrentSubtask.Task.Name)
-		case line.Progress != nil:
-			app.tui.setProgress(line.Progress.TaskProgress())
-		case line.Message != nil:
-			slog.Info(line.Message.Message.String(), "source", "makemkv")
-		}
+// backupTitle backs up the title to dstPath. It fails if dstPath exists unless
+// overwrite is true.
+func (app *application) backupTitle(ctx context.Context, src *discSource, view driveUI, title *makemkv.Title, dstPath string, overwrite bool) error {
+	dstDir := filepath.Dir(dstPath)
+	if _, err := os.Stat(dstPath); err == nil && !overwrite {
+		return fmt.Errorf("output file exists: %q", dstPath)
 	}
 
+	view.setStatus("Backing up title to %s", dstDir)
+	defer app.lockTray(ctx, src)()
+	seq, err := app.con.BackupTitle(ctx, src.Source, title.Index, dstDir)
+	if err != nil {
+		return fmt.Errorf("backup title %d to %q: %w", title.Index, dstDir, err)
+	}
+	followProgress(src, view, seq)
+
 	name, err := title.GetAttr(defs.OutputFileName)
 	if err != nil {
 		return fmt.Errorf("title has no output file name")
@@ -311,6 +798,22 @@ func (app *application) backupTitle(ctx context.Context, drive *makemkv.DriveSca
 		return fmt.Errorf("backup file not found at expected path %q: %w", expectedPath, err)
 	}
 
+	// The rip is verified before it replaces a previous rip at dstPath, which
+	// is kept if the rip is invalid.
+	if !app.cfg.skipVerify {
+		view.setStatus("Verifying %s", filepath.Base(dstPath))
+		if err := verifyTitle(title, expectedPath); err != nil {
+			// Keep the file for inspection, but out of the way of the next
+			// attempt and of media servers.
+			ext := filepath.Ext(dstPath)
+			invalidPath := strings.TrimSuffix(dstPath, ext) + ".invalid" + ext
+			if err := os.Rename(expectedPath, invalidPath); err != nil {
+				slog.Error("failed to rename invalid file", "disc", src, "path", expectedPath, "err", err)
+			}
+			return fmt.Errorf("verify %q: %w", expectedPath, err)
+		}
+	}
+
 	if err := os.Rename(expectedPath, dstPath); err != nil {
 		return fmt.Errorf("rename %q to %q: %w", expectedPath, dstPath, err)
 	}
@@ -318,21 +821,26 @@ func (app *application) backupTitle(ctx context.Context, drive *makemkv.DriveSca
 	return nil
 }
 
-func makeFileName(metadata *moviedb.MovieMetadata) string {
-	return fmt.Sprintf("%s (%d) {%s}", sanitizeFileName(metadata.Name), metadata.Year, sanitizeFileName(metadata.ID))
-}
-
-func searchMovieDB(q string) (*moviedb.MovieMetadata, error) {
-	results, err := moviedb.NewIMDb().SearchMovies(q)
-	if err != nil {
-		return nil, fmt.Errorf("search for %q: %w", q, err)
-	}
+// followProgress shows the progress of a backup command and logs its
+// messages.
+func followProgress(src *discSource, view driveUI, seq iter.Seq2[*makemkv.Line, error]) {
+	for line, err := range seq {
+		if err != nil {
+			slog.Error(err.Error(), "disc", src)
+			continue
+		}
 
-	if len(results) == 0 {
-		return nil, fmt.Errorf("no results")
+		switch {
+		case line.CurrentTask != nil:
+			view.setTask("%s", line.CurrentTask.Task.Name)
+		case line.CurrentSubtask != nil:
+			view.setSubtask("%s", line.CurrentSubtask.Task.Name)
+		case line.Progress != nil:
+			view.setProgress(line.Progress.TaskProgress())
+		case line.Message != nil:
+			slog.Info(line.Message.Message.String(), "source", "makemkv", "disc", src)
+		}
 	}
-
-	return results[0], nil
 }
 
 type beeper struct {
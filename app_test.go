@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/hook"
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/matroska"
+)
+
+// fakeExePath is the path of the fakemakemkvcon test double built by TestMain.
+var fakeExePath string
+
+func TestMain(m *testing.M) {
+	os.Exit(func() int {
+		dir, err := os.MkdirTemp("", "fakemakemkvcon")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer os.RemoveAll(dir)
+
+		fakeExePath = filepath.Join(dir, "fakemakemkvcon")
+		if runtime.GOOS == "windows" {
+			fakeExePath += ".exe"
+		}
+
+		cmd := exec.Command("go", "build", "-o", fakeExePath, "./pkg/makemkv/cmd/fakemakemkvcon")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "build fakemakemkvcon: %s\n%s", err, out)
+			return 1
+		}
+
+		return m.Run()
+	}())
+}
+
+// newFakeApplication returns a headless application that runs fakemakemkvcon
+// with a drive that holds the fake disc. The drive's volume is not a drive, so
+// the tray ioctls are not supported. configure may change the configuration.
+func newFakeApplication(t *testing.T, configure func(cfg *applicationConfig)) *application {
+	t.Helper()
+
+	disc, err := filepath.Abs(filepath.Join("pkg", "makemkv", "testdata", "fake", "disc.txt"))
+	require.NoError(t, err)
+
+	b, err := json.Marshal(map[string]any{
+		"drives": []map[string]string{
+			{"name": "BD-RE Fake Drive", "discTitle": "A Fake Movie", "volume": os.DevNull, "disc": disc},
+		},
+		"fullSize": true,
+	})
+	require.NoError(t, err)
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "script.json")
+	require.NoError(t, os.WriteFile(scriptPath, b, 0600))
+	t.Setenv("FAKEMAKEMKVCON_SCRIPT", scriptPath)
+
+	weights := make(map[string]int64, len(bestTitleHeuristics))
+	for _, h := range bestTitleHeuristics {
+		weights[h.name] = h.weight
+	}
+
+	outputDir := filepath.Join(dir, "output")
+	require.NoError(t, os.Mkdir(outputDir, 0775))
+
+	cfg := &applicationConfig{
+		outputDirPath:  outputDir,
+		nameTemplate:   nameTemplatePlex,
+		tvNameTemplate: nameTemplatePlex,
+		movieDBs:       []string{movieDBIMDb},
+		makemkvConfig: &makemkv.Config{
+			ExePath:          fakeExePath,
+			ReadCacheSizeMB:  1,
+			MinLengthSeconds: 120,
+		},
+		quiet:                      true,
+		bestTitleHeuristicsWeights: weights,
+		historyFilePath:            filepath.Join(dir, "history.jsonl"),
+		headless:                   true,
+		headlessPolicy: &headlessPolicy{
+			metadata:  metadataPolicyDiscName,
+			title:     titlePolicyLowestIndex,
+			duplicate: duplicateActionSkip,
+			extras:    extraCategoryOther,
+		},
+		jobsFilePath: filepath.Join(outputDir, defaultJobsFileName),
+		jobWorkers:   1,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	app, err := newApplication(cfg)
+	require.NoError(t, err)
+
+	// The disc is named after its volume name; there is no movie database.
+	app.movieDBs = nil
+
+	return app
+}
+
+// editFakeScript changes the script of fakemakemkvcon, e.g., to add a failure.
+func editFakeScript(t *testing.T, edit func(script map[string]any)) {
+	t.Helper()
+
+	path := os.Getenv("FAKEMAKEMKVCON_SCRIPT")
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var script map[string]any
+	require.NoError(t, json.Unmarshal(b, &script))
+	edit(script)
+
+	b, err = json.Marshal(script)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, b, 0600))
+}
+
+// runBackupLoop runs the backup loop until the history has a record with
+// each of the outcomes.
+func runBackupLoop(t *testing.T, app *application, outcomes ...history.Outcome) []*history.Record {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- app.run(ctx, app.doBackupLoop)
+	}()
+
+	require.Eventually(t, func() bool {
+		records, err := app.history.Records()
+		if err != nil {
+			return false
+		}
+
+		for _, outcome := range outcomes {
+			if !slices.ContainsFunc(records, func(r *history.Record) bool { return r.Outcome == outcome }) {
+				return false
+			}
+		}
+		return true
+	}, time.Minute, 100*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Minute):
+		require.FailNow(t, "the backup loop did not stop")
+	}
+
+	records, err := app.history.Records()
+	require.NoError(t, err)
+
+	return records
+}
+
+func TestBackupLoop(t *testing.T) {
+	app := newFakeApplication(t, nil)
+
+	// The disc is ripped, and then skipped as a duplicate until it is
+	// removed.
+	records := runBackupLoop(t, app, history.OutcomeSuccess, history.OutcomeSkipped)
+	rec := records[0]
+	assert.Equal(t, history.OutcomeSuccess, rec.Outcome)
+	assert.Equal(t, "BD-RE Fake Drive", rec.Drive)
+	assert.Equal(t, 0, rec.TitleIndex)
+	assert.NotEmpty(t, rec.Fingerprint)
+
+	f, err := matroska.ReadFile(rec.OutputPath)
+	require.NoError(t, err)
+	assert.Contains(t, f.Tags, &matroska.Tag{Name: "TITLE", Value: rec.Name})
+	assert.FileExists(t, filepath.Join(filepath.Dir(rec.OutputPath), sidecarFileName))
+
+	for _, rec := range records[1:] {
+		assert.Equal(t, history.OutcomeSkipped, rec.Outcome)
+		assert.Equal(t, records[0].Fingerprint, rec.Fingerprint)
+	}
+}
+
+func TestBackupLoopRerip(t *testing.T) {
+	app := newFakeApplication(t, nil)
+	ripped := runBackupLoop(t, app, history.OutcomeSuccess)[0]
+	before, err := os.Stat(ripped.OutputPath)
+	require.NoError(t, err)
+
+	// The rerip is too small to pass verification, so the first rip stays.
+	app = newFakeApplication(t, func(cfg *applicationConfig) {
+		cfg.outputDirPath = app.cfg.outputDirPath
+		cfg.historyFilePath = app.cfg.historyFilePath
+		cfg.jobsFilePath = app.cfg.jobsFilePath
+		cfg.headlessPolicy.duplicate = duplicateActionRerip
+	})
+	editFakeScript(t, func(script map[string]any) {
+		script["fullSize"] = false
+	})
+	records := runBackupLoop(t, app, history.OutcomeFailure)
+	assert.Contains(t, records[len(records)-1].Error, "verify")
+
+	after, err := os.Stat(ripped.OutputPath)
+	require.NoError(t, err)
+	assert.Equal(t, before.Size(), after.Size())
+	assert.Equal(t, before.ModTime(), after.ModTime())
+
+	ext := filepath.Ext(ripped.OutputPath)
+	assert.FileExists(t, strings.TrimSuffix(ripped.OutputPath, ext)+".invalid"+ext)
+}
+
+func TestBackupLoopGivesUp(t *testing.T) {
+	app := newFakeApplication(t, func(cfg *applicationConfig) {
+		cfg.scanDelay = 0
+	})
+	editFakeScript(t, func(script map[string]any) {
+		script["failures"] = []map[string]any{{"command": "mkv", "afterLines": 3}}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- app.run(ctx, app.doBackupLoop)
+	}()
+
+	countFailures := func() int {
+		records, err := app.history.Records()
+		require.NoError(t, err)
+
+		var n int
+		for _, rec := range records {
+			if rec.Outcome == history.OutcomeFailure {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The disc cannot be ejected, so it stays in the drive, where it is left
+	// alone after the last attempt. The drive is scanned again without delay.
+	require.Eventually(t, func() bool {
+		return countFailures() >= maxDiscFailures
+	}, time.Minute, 100*time.Millisecond)
+	assert.Never(t, func() bool {
+		return countFailures() > maxDiscFailures
+	}, time.Second, 100*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestBackupLoopReplay(t *testing.T) {
+	transcriptDir := t.TempDir()
+	app := newFakeApplication(t, func(cfg *applicationConfig) {
+		cfg.makemkvConfig.RecordDir = transcriptDir
+	})
+	recorded := runBackupLoop(t, app, history.OutcomeSuccess)
+
+	// The replay ends by itself when the transcripts run out, without running
+	// makemkvcon.
+	app = newFakeApplication(t, func(cfg *applicationConfig) {
+		cfg.makemkvConfig.ExePath = ""
+		cfg.makemkvConfig.ReplayDir = transcriptDir
+	})
+	done := make(chan error)
+	go func() {
+		done <- app.run(context.Background(), app.doBackupLoop)
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Minute):
+		require.FailNow(t, "the replay did not stop")
+	}
+
+	records, err := app.history.Records()
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	assert.Equal(t, history.OutcomeSuccess, records[0].Outcome)
+	assert.Equal(t, recorded[0].Fingerprint, records[0].Fingerprint)
+	assert.Equal(t, filepath.Base(recorded[0].OutputPath), filepath.Base(records[0].OutputPath))
+	assert.FileExists(t, records[0].OutputPath)
+}
+
+func TestBackupLoopHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook command needs a POSIX shell")
+	}
+
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	app := newFakeApplication(t, func(cfg *applicationConfig) {
+		cfg.hooks = []*hook.Config{
+			{Command: "cat > " + eventPath},
+			{Command: "exit 3"},
+		}
+	})
+
+	// The hooks run in the job queue, which keeps running after the rip, and
+	// add their results to the history record.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- app.run(ctx, app.doBackupLoop)
+	}()
+	var records []*history.Record
+	require.Eventually(t, func() bool {
+		var err error
+		records, err = app.history.Records()
+		return err == nil && len(records) > 0 && len(records[0].Hooks) == 2
+	}, time.Minute, 100*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []*history.HookResult{
+		{Command: "cat > " + eventPath, Attempts: 1},
+		{Command: "exit 3", Attempts: 1, ExitCode: 3, Error: "exit status 3"},
+	}, records[0].Hooks)
+
+	var event hookEvent
+	b, err := os.ReadFile(eventPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &event))
+	require.NotNil(t, event.Record)
+	assert.Equal(t, records[0].OutputPath, event.Record.OutputPath)
+	assert.Equal(t, history.OutcomeSuccess, event.Record.Outcome)
+}
@@ -53,7 +53,10 @@ var bestTitleHeuristics = []*bestTitleHeuristic{
 	},
 }
 
-func findBestTitle(disc *makemkv.Disc, weights map[string]int64) []*makemkv.Title {
+// findBestTitle returns the title(s) with the highest total weight of the
+// heuristics that selected them, along with the scores of all titles indexed
+// by title index.
+func findBestTitle(disc *makemkv.Disc, weights map[string]int64) ([]*makemkv.Title, []int64) {
 	scores := make([]int64, len(disc.Titles))
 	for _, h := range bestTitleHeuristics {
 		for _, title := range h.f(disc) {
@@ -62,7 +65,9 @@ func findBestTitle(disc *makemkv.Disc, weights map[string]int64) []*makemkv.Titl
 	}
 	slog.Debug("scored titles", "scores", scores)
 
-	return makemkv.Maximums(disc.Titles, func(title *makemkv.Title) (int64, error) {
+	best := makemkv.Maximums(disc.Titles, func(title *makemkv.Title) (int64, error) {
 		return scores[title.Index], nil
 	})
+
+	return best, scores
 }
@@ -2,23 +2,67 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/curt-hash/mkvbot/pkg/ffmpeg"
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
 	"github.com/urfave/cli/v3"
 )
 
 var Version string
 
 const (
-	debugFlagName         = "debug"
-	makemkvconFlagName    = "makemkvcon"
-	profileFlagName       = "profile"
-	createProfileFlagName = "create-profile"
-	cacheFlagName         = "cache"
-	minLengthFlagName     = "minlength"
-	outputDirFlagName     = "output-dir"
-	quietFlagName         = "quiet"
-	askForTitleFlagName   = "ask-title"
-	logFileFlagName       = "log"
+	debugFlagName            = "debug"
+	makemkvconFlagName       = "makemkvcon"
+	profileFlagName          = "profile"
+	createProfileFlagName    = "create-profile"
+	cacheFlagName            = "cache"
+	minLengthFlagName        = "minlength"
+	outputDirFlagName        = "output-dir"
+	quietFlagName            = "quiet"
+	askForTitleFlagName      = "ask-title"
+	logFileFlagName          = "log"
+	recordFlagName           = "record"
+	replayFlagName           = "replay"
+	headlessFlagName         = "headless"
+	metadataPolicyFlagName   = "headless-metadata"
+	titlePolicyFlagName      = "headless-title"
+	duplicatePolicyFlagName  = "headless-duplicate"
+	historyFlagName          = "history"
+	searchFlagName           = "search"
+	outcomeFlagName          = "outcome"
+	formatFlagName           = "format"
+	configFlagName           = "config"
+	configProfileFlagName    = "config-profile"
+	nameTemplateFlagName     = "name-template"
+	tvFlagName               = "tv"
+	tvNameTemplateFlagName   = "tv-name-template"
+	extrasFlagName           = "extras"
+	extrasMinLengthFlagName  = "extras-min-length"
+	extrasPolicyFlagName     = "headless-extras"
+	backupDiscFlagName       = "backup-disc"
+	skipVerifyFlagName       = "skip-verify"
+	skipTagsFlagName         = "skip-tags"
+	skipSidecarsFlagName     = "skip-sidecars"
+	movieDBFlagName          = "moviedb"
+	tmdbAPIKeyFlagName       = "tmdb-api-key"
+	imdbDatasetIndexFlagName = "imdb-dataset-index"
+	imdbDatasetTypesFlagName = "imdb-dataset-types"
+	movieDBCacheDirFlagName  = "moviedb-cache-dir"
+	movieDBCacheTTLFlagName  = "moviedb-cache-ttl"
+	hookFlagName             = "hook"
+	hookTimeoutFlagName      = "hook-timeout"
+	hookRetriesFlagName      = "hook-retries"
+	transcodeFlagName        = "transcode"
+	ffmpegFlagName           = "ffmpeg"
+	keepOriginalFlagName     = "keep-original"
+	libraryDirFlagName       = "library-dir"
+	notifyFlagName           = "notify"
+	jobWorkersFlagName       = "job-workers"
 )
 
 func newCLICommand() *cli.Command {
@@ -28,6 +72,14 @@ func newCLICommand() *cli.Command {
 		Usage:     "Automation for makemkv",
 		Copyright: "(c) 2025 Curt Hash",
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  configFlagName,
+				Usage: fmt.Sprintf("read settings from `FILE` (default: %s in the user config directory or %s next to mkvbot)", filepath.Join(configDirName, defaultConfigFileName), exeConfigFileName),
+			},
+			&cli.StringFlag{
+				Name:  configProfileFlagName,
+				Usage: "apply the settings of the config file profile named `NAME`",
+			},
 			&cli.BoolFlag{
 				Name:  debugFlagName,
 				Value: false,
@@ -49,13 +101,13 @@ func newCLICommand() *cli.Command {
 				Name:  createProfileFlagName,
 				Usage: "create a default profile.xml for use with --profile",
 			},
-			&cli.IntFlag{
+			&cli.Int64Flag{
 				Name:    cacheFlagName,
 				Value:   1024,
 				Usage:   "pass --cache=`SIZE` to makemkv",
 				Aliases: []string{"c"},
 			},
-			&cli.IntFlag{
+			&cli.Int64Flag{
 				Name:    minLengthFlagName,
 				Value:   1800,
 				Usage:   "pass --minlength=`N` to makemkv",
@@ -67,6 +119,98 @@ func newCLICommand() *cli.Command {
 				Usage:   "create output files in `DIR`",
 				Aliases: []string{"o"},
 			},
+			&cli.StringFlag{
+				Name:  nameTemplateFlagName,
+				Value: nameTemplatePlex,
+				Usage: fmt.Sprintf("name output files according to `TEMPLATE`, a preset (%s) or a Go template", strings.Join(nameTemplatePresetNames, ", ")),
+			},
+			&cli.BoolFlag{
+				Name:  tvFlagName,
+				Usage: fmt.Sprintf("rip every episode of TV series discs instead of the best title (implies --%s=%d unless set)", minLengthFlagName, tvMinLengthSeconds),
+			},
+			&cli.StringFlag{
+				Name:  tvNameTemplateFlagName,
+				Value: nameTemplatePlex,
+				Usage: fmt.Sprintf("name episodes according to `TEMPLATE`, a preset (%s) or a Go template", strings.Join(nameTemplatePresetNames, ", ")),
+			},
+			&cli.BoolFlag{
+				Name:  backupDiscFlagName,
+				Usage: "archive the whole disc structure (BDMV or VIDEO_TS) in a directory named by --name-template instead of ripping a title",
+			},
+			&cli.BoolFlag{
+				Name:  skipVerifyFlagName,
+				Usage: "do not check that ripped titles have the tracks, duration and size that makemkvcon reported",
+			},
+			&cli.BoolFlag{
+				Name:  skipTagsFlagName,
+				Usage: "do not write the movie metadata into ripped titles as Matroska tags",
+			},
+			&cli.BoolFlag{
+				Name:  skipSidecarsFlagName,
+				Usage: "do not write NFO and mkvbot.json sidecar files next to ripped titles",
+			},
+			&cli.BoolFlag{
+				Name:  extrasFlagName,
+				Usage: "also rip the other titles as extras of the movie",
+			},
+			&cli.DurationFlag{
+				Name:  extrasMinLengthFlagName,
+				Value: 2 * time.Minute,
+				Usage: fmt.Sprintf("ignore extras shorter than `DURATION` (implies --%s unless set)", minLengthFlagName),
+			},
+			&cli.StringFlag{
+				Name:  transcodeFlagName,
+				Usage: fmt.Sprintf("transcode ripped titles in the background with ffmpeg according to `PRESET` (%s)", strings.Join(ffmpeg.PresetNames(), ", ")),
+			},
+			&cli.StringFlag{
+				Name:  ffmpegFlagName,
+				Usage: "`PATH` to ffmpeg executable",
+			},
+			&cli.BoolFlag{
+				Name:  keepOriginalFlagName,
+				Usage: "keep the original of a transcoded title as .NAME.original.mkv",
+			},
+			&cli.StringFlag{
+				Name:  libraryDirFlagName,
+				Usage: "move ripped titles from the output directory to the same place in the library `DIR` in the background",
+			},
+			&cli.BoolFlag{
+				Name:  notifyFlagName,
+				Usage: "send a desktop notification when a ripped title is ready",
+			},
+			&cli.IntFlag{
+				Name:  jobWorkersFlagName,
+				Value: 2,
+				Usage: "run up to `N` background jobs (verify, transcode, move, notify) at the same time",
+			},
+			&cli.StringSliceFlag{
+				Name:  movieDBFlagName,
+				Value: []string{movieDBIMDb},
+				Usage: fmt.Sprintf("search the movie database `NAME` (%s); repeat to try several in order", strings.Join(movieDBNames, ", ")),
+			},
+			&cli.StringFlag{
+				Name:    tmdbAPIKeyFlagName,
+				Usage:   "TMDb API `KEY` for --moviedb tmdb",
+				Sources: cli.EnvVars("TMDB_API_KEY"),
+			},
+			&cli.StringFlag{
+				Name:  movieDBCacheDirFlagName,
+				Usage: fmt.Sprintf("cache movie database results in `DIR` (default: %s in the user cache directory)", filepath.Join(configDirName, defaultMovieDBCacheDirName)),
+			},
+			&cli.DurationFlag{
+				Name:  movieDBCacheTTLFlagName,
+				Value: 30 * 24 * time.Hour,
+				Usage: "reuse cached movie database results for `DURATION` (0 disables the cache)",
+			},
+			&cli.StringFlag{
+				Name:  imdbDatasetIndexFlagName,
+				Usage: fmt.Sprintf("`FILE` of the index for --moviedb %s (default: %s in the user cache directory)", movieDBIMDbDataset, filepath.Join(configDirName, defaultIMDbDatasetIndexFileName)),
+			},
+			&cli.StringSliceFlag{
+				Name:  imdbDatasetTypesFlagName,
+				Value: slices.Clone(moviedb.DefaultIMDbDatasetTypes),
+				Usage: fmt.Sprintf("search IMDb titles of `TYPE` with --moviedb %s, e.g., movie, tvMovie, video, tvSeries", movieDBIMDbDataset),
+			},
 			&cli.BoolFlag{
 				Name:    quietFlagName,
 				Usage:   "do not beep",
@@ -82,7 +226,93 @@ func newCLICommand() *cli.Command {
 				Usage:   "append log messages to `FILE`",
 				Aliases: []string{"L"},
 			},
+			&cli.StringFlag{
+				Name:  recordFlagName,
+				Usage: "record every makemkvcon invocation to a transcript in `DIR`",
+			},
+			&cli.StringFlag{
+				Name:  replayFlagName,
+				Usage: "replay transcripts from `DIR` instead of running makemkvcon",
+			},
+			&cli.BoolFlag{
+				Name:  headlessFlagName,
+				Usage: "run without a user interface, making decisions automatically",
+			},
+			&cli.StringFlag{
+				Name:  metadataPolicyFlagName,
+				Value: metadataPolicyFirstResult,
+				Usage: fmt.Sprintf("`POLICY` for choosing movie metadata when headless (%s)", strings.Join(metadataPolicies, ", ")),
+			},
+			&cli.StringFlag{
+				Name:  titlePolicyFlagName,
+				Value: titlePolicyLowestIndex,
+				Usage: fmt.Sprintf("`POLICY` for breaking best title ties when headless (%s)", strings.Join(titlePolicies, ", ")),
+			},
+			&cli.StringFlag{
+				Name:  duplicatePolicyFlagName,
+				Value: duplicateActionSkip,
+				Usage: fmt.Sprintf("`ACTION` for discs that were ripped before when headless (%s)", strings.Join(duplicateActions, ", ")),
+			},
+			&cli.StringFlag{
+				Name:  extrasPolicyFlagName,
+				Value: extraCategoryOther,
+				Usage: fmt.Sprintf("`CATEGORY` of extras when headless (%s)", strings.Join(extraCategories, ", ")),
+			},
+			&cli.StringFlag{
+				Name:  historyFlagName,
+				Usage: fmt.Sprintf("record rip attempts in `FILE` (default: %s in the output directory)", defaultHistoryFileName),
+			},
+			&cli.StringSliceFlag{
+				Name:  hookFlagName,
+				Usage: fmt.Sprintf("run the shell `COMMAND` after each successful rip, with %s* environment variables and JSON on stdin; repeat to run several in order", hookEnvPrefix),
+			},
+			&cli.DurationFlag{
+				Name:  hookTimeoutFlagName,
+				Value: 30 * time.Minute,
+				Usage: "kill hooks that run longer than `DURATION` (0 means no limit)",
+			},
+			&cli.IntFlag{
+				Name:  hookRetriesFlagName,
+				Usage: "run failed hooks again up to `N` times",
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:  "history",
+				Usage: "List, search, or export the rip history",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    searchFlagName,
+						Usage:   "only show attempts containing `TEXT`",
+						Aliases: []string{"s"},
+					},
+					&cli.StringFlag{
+						Name:  outcomeFlagName,
+						Usage: "only show attempts with the given `OUTCOME` (success, failure, canceled, skipped)",
+					},
+					&cli.StringFlag{
+						Name:    formatFlagName,
+						Value:   historyFormatTable,
+						Usage:   fmt.Sprintf("output `FORMAT` (%s)", strings.Join(historyFormats, ", ")),
+						Aliases: []string{"f"},
+					},
+				},
+				Action: runHistory,
+			},
+			{
+				Name:      "process",
+				Usage:     "Back up the discs in ISO images and disc folders (e.g., disc backups) instead of drives",
+				ArgsUsage: "PATH...",
+				Action:    runProcess,
+			},
+			{
+				Name:      "import-imdb-dataset",
+				Usage:     fmt.Sprintf("Index the IMDb title.basics.tsv.gz dataset for --moviedb %s", movieDBIMDbDataset),
+				ArgsUsage: "FILE",
+				Action:    runImportIMDbDataset,
+			},
 		},
+		Before: applyConfigFile,
 		Action: func(ctx context.Context, cmd *cli.Command) error {
 			return run(ctx, cmd)
 		},
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configDirName is the name of the mkvbot directory in the user
+	// configuration directory.
+	configDirName = "mkvbot"
+
+	// defaultConfigFileName is the name of the configuration file in the user
+	// configuration directory.
+	defaultConfigFileName = "config.yaml"
+
+	// exeConfigFileName is the name of the configuration file next to the
+	// mkvbot executable.
+	exeConfigFileName = "mkvbot.yaml"
+
+	// configProfilesKey is the key of the named profiles in a configuration
+	// file.
+	configProfilesKey = "profiles"
+)
+
+// configFile is a YAML configuration file. Settings are keyed by flag name,
+// e.g.:
+//
+//	output-dir: /media/movies
+//	minlength: 3600
+//	profiles:
+//	  tv:
+//	    output-dir: /media/tv
+//	    minlength: 600
+//
+// The settings of the selected profile take precedence over the top-level
+// settings. Flags given on the command line take precedence over both.
+type configFile struct {
+	settings map[string]any
+	profiles map[string]map[string]any
+}
+
+// configFilePaths returns the paths that are searched for a configuration
+// file, in order of preference.
+func configFilePaths() []string {
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, configDirName, defaultConfigFileName))
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		paths = append(paths, filepath.Join(filepath.Dir(exe), exeConfigFileName))
+	}
+
+	return paths
+}
+
+// findConfigFile returns the path of the configuration file given by the
+// --config flag or else the first one that exists in configFilePaths. It
+// returns "" if there is none.
+func findConfigFile(cmd *cli.Command) (string, error) {
+	if path := cmd.String(configFlagName); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("config file: %w", err)
+		}
+
+		return path, nil
+	}
+
+	for _, path := range configFilePaths() {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("config file: %w", err)
+		}
+	}
+
+	return "", nil
+}
+
+func loadConfigFile(path string) (*configFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+
+	var raw struct {
+		Profiles map[string]map[string]any `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("parse %q: %w", path, err)
+	}
+
+	settings := map[string]any{}
+	if err := yaml.Unmarshal(b, &settings); err != nil {
+		return nil, fmt.Errorf("parse %q: %w", path, err)
+	}
+	delete(settings, configProfilesKey)
+
+	return &configFile{
+		settings: settings,
+		profiles: raw.Profiles,
+	}, nil
+}
+
+// resolve returns the settings of the named profile merged with the top-level
+// settings. The top-level settings are returned if profile is "".
+func (c *configFile) resolve(profile string) (map[string]any, error) {
+	settings := maps.Clone(c.settings)
+	if profile == "" {
+		return settings, nil
+	}
+
+	profileSettings, ok := c.profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found (expected one of %q)", profile, slices.Sorted(maps.Keys(c.profiles)))
+	}
+	maps.Copy(settings, profileSettings)
+
+	return settings, nil
+}
+
+// applyConfigFile sets the flags of cmd that were not given on the command line
+// according to the configuration file and profile. It is the Before hook of the
+// root command.
+func applyConfigFile(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+	profile := cmd.String(configProfileFlagName)
+
+	path, err := findConfigFile(cmd)
+	if err != nil {
+		return ctx, err
+	}
+
+	if path == "" {
+		if profile != "" {
+			return ctx, fmt.Errorf("config profile %q: no config file found (searched %q)", profile, configFilePaths())
+		}
+
+		return ctx, nil
+	}
+
+	c, err := loadConfigFile(path)
+	if err != nil {
+		return ctx, err
+	}
+
+	settings, err := c.resolve(profile)
+	if err != nil {
+		return ctx, fmt.Errorf("config file %q: %w", path, err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if err := setFlagFromConfig(cmd, name, settings[name]); err != nil {
+			return ctx, fmt.Errorf("config file %q: %w", path, err)
+		}
+	}
+
+	slog.Debug("loaded config file", "path", path, "profile", profile)
+	return ctx, nil
+}
+
+// setFlagFromConfig sets the named flag to value unless it was given on the
+// command line. Lists set each element in turn.
+func setFlagFromConfig(cmd *cli.Command, name string, value any) error {
+	if name == configFlagName || name == configProfileFlagName || !slices.ContainsFunc(cmd.Flags, func(f cli.Flag) bool {
+		return f.Names()[0] == name
+	}) {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+
+	if cmd.IsSet(name) {
+		return nil
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+
+	for _, v := range values {
+		switch v.(type) {
+		case string, bool, int, float64:
+		default:
+			return fmt.Errorf("setting %q: unsupported value %v", name, v)
+		}
+
+		if err := cmd.Set(name, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("setting %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+const testConfig = `
+output-dir: /media/movies
+minlength: 3600
+moviedb: [tmdb, imdb-dataset]
+hook:
+  - echo one
+  - echo two
+debug: true
+moviedb-cache-ttl: 24h
+profiles:
+  tv:
+    output-dir: /media/tv
+    minlength: 600
+    tv: true
+  broken:
+    no-such-flag: 1
+`
+
+// runWithConfig runs the root command with the configuration file and the
+// arguments and returns the command, whose flags are then set, without
+// running its action.
+func runWithConfig(t *testing.T, config string, args ...string) (*cli.Command, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+
+	cmd := newCLICommand()
+	cmd.Writer, cmd.ErrWriter = io.Discard, io.Discard
+	cmd.Action = func(context.Context, *cli.Command) error {
+		return nil
+	}
+
+	err := cmd.Run(context.Background(), append([]string{"mkvbot", "--" + configFlagName, path}, args...))
+	return cmd, err
+}
+
+func TestConfigFile(t *testing.T) {
+	cmd, err := runWithConfig(t, testConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "/media/movies", cmd.String(outputDirFlagName))
+	assert.Equal(t, int64(3600), cmd.Int64(minLengthFlagName))
+	assert.False(t, cmd.Bool(tvFlagName))
+	assert.True(t, cmd.Bool(debugFlagName))
+	assert.Equal(t, 24*time.Hour, cmd.Duration(movieDBCacheTTLFlagName))
+
+	// Lists replace the default and set each element in turn.
+	assert.Equal(t, []string{movieDBTMDb, movieDBIMDbDataset}, cmd.StringSlice(movieDBFlagName))
+	assert.Equal(t, []string{"echo one", "echo two"}, cmd.StringSlice(hookFlagName))
+}
+
+func TestConfigFileFlagsTakePrecedence(t *testing.T) {
+	cmd, err := runWithConfig(t, testConfig,
+		"--"+outputDirFlagName, "/tmp/out",
+		"--"+movieDBFlagName, movieDBIMDb,
+		"--"+configProfileFlagName, "tv",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/out", cmd.String(outputDirFlagName))
+	assert.Equal(t, []string{movieDBIMDb}, cmd.StringSlice(movieDBFlagName))
+
+	// The profile still applies to the flags that were not given.
+	assert.Equal(t, int64(600), cmd.Int64(minLengthFlagName))
+	assert.True(t, cmd.Bool(tvFlagName))
+}
+
+func TestConfigFileProfile(t *testing.T) {
+	cmd, err := runWithConfig(t, testConfig, "--"+configProfileFlagName, "tv")
+	require.NoError(t, err)
+
+	// The profile's settings take precedence over the top-level settings,
+	// which still apply otherwise.
+	assert.Equal(t, "/media/tv", cmd.String(outputDirFlagName))
+	assert.Equal(t, int64(600), cmd.Int64(minLengthFlagName))
+	assert.True(t, cmd.Bool(tvFlagName))
+	assert.Equal(t, []string{"echo one", "echo two"}, cmd.StringSlice(hookFlagName))
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		args   []string
+		err    string
+	}{
+		{
+			name:   "unknown profile",
+			config: testConfig,
+			args:   []string{"--" + configProfileFlagName, "movies"},
+			err:    `profile "movies" not found (expected one of ["broken" "tv"])`,
+		},
+		{
+			name:   "unknown setting in profile",
+			config: testConfig,
+			args:   []string{"--" + configProfileFlagName, "broken"},
+			err:    `unknown setting "no-such-flag"`,
+		},
+		{
+			name:   "unknown setting",
+			config: "outputdir: /media/movies\n",
+			err:    `unknown setting "outputdir"`,
+		},
+		{
+			name:   "config flag",
+			config: "config: other.yaml\n",
+			err:    `unknown setting "config"`,
+		},
+		{
+			name:   "invalid value",
+			config: "minlength: long\n",
+			err:    `setting "minlength"`,
+		},
+		{
+			name:   "unsupported value",
+			config: "output-dir: {a: b}\n",
+			err:    `setting "output-dir": unsupported value`,
+		},
+		{
+			name:   "invalid YAML",
+			config: "output-dir: [\n",
+			err:    "parse",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := runWithConfig(t, tc.config, tc.args...)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestConfigFileMissing(t *testing.T) {
+	cmd := newCLICommand()
+	cmd.Writer, cmd.ErrWriter = io.Discard, io.Discard
+	cmd.Action = func(context.Context, *cli.Command) error {
+		return nil
+	}
+
+	err := cmd.Run(context.Background(), []string{"mkvbot", "--" + configFlagName, filepath.Join(t.TempDir(), "missing.yaml")})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+)
+
+// backupDisc archives the whole disc structure, rather than a title, in a
+// directory named by the name template without the extension.
+func (app *application) backupDisc(ctx context.Context, src *discSource, view driveUI, disc *makemkv.Disc) (err error) {
+	rec := &history.Record{
+		StartTime:  time.Now(),
+		Drive:      src.name,
+		DiscName:   disc.GetAttrDefault(defs.Name, ""),
+		TitleIndex: -1,
+		DiscBackup: true,
+	}
+	defer func() {
+		app.addHistoryRecord(rec, err)
+	}()
+
+	movieMetadata, dstDir, err := app.identifyDisc(ctx, src, view, disc, rec)
+	if err != nil {
+		return err
+	}
+	if rec.Outcome == history.OutcomeSkipped {
+		return app.ejectDisc(ctx, src, view)
+	}
+	overwrite := dstDir != ""
+
+	if dstDir == "" {
+		// The main feature determines the resolution and audio codec.
+		title := &makemkv.Title{Index: -1}
+		if longest := disc.TitlesWithLongestDuration(); len(longest) > 0 {
+			title = longest[0]
+		}
+
+		dstPath, err := app.outputPath(app.nameTemplate, newNameData(movieMetadata, rec.Edition, disc, title))
+		if err != nil {
+			return err
+		}
+		dstDir = strings.TrimSuffix(dstPath, filepath.Ext(dstPath))
+	}
+	rec.OutputPath = dstDir
+
+	// An empty directory may be left over from a failed attempt.
+	if entries, err := os.ReadDir(dstDir); err == nil && len(entries) > 0 && !overwrite {
+		return fmt.Errorf("output directory exists and is not empty: %q", dstDir)
+	}
+
+	view.setStatus("Backing up disc to %s", dstDir)
+	defer app.lockTray(ctx, src)()
+	seq, err := app.con.BackupDisc(ctx, src.Source, dstDir)
+	if err != nil {
+		return fmt.Errorf("backup disc to %q: %w", dstDir, err)
+	}
+	followProgress(src, view, seq)
+
+	if err := checkDiscStructure(dstDir); err != nil {
+		return err
+	}
+	rec.Outcome = history.OutcomeSuccess
+	app.addHistoryRecord(rec, nil)
+	app.queueHookJobs(src, newHookEvent(rec, movieMetadata, nil))
+
+	if err := app.ejectDisc(ctx, src, view); err != nil {
+		return err
+	}
+
+	app.ui.beep()
+	return nil
+}
+
+// checkDiscStructure returns an error if dir does not contain a Blu-ray or DVD
+// disc structure.
+func checkDiscStructure(dir string) error {
+	if !makemkv.IsDiscFolder(dir) {
+		return fmt.Errorf("backup not found at expected path %q: no %s directory", dir, strings.Join(makemkv.DiscFolderNames, " or "))
+	}
+
+	return nil
+}
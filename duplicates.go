@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	// duplicateActionSkip ejects the disc without ripping it.
+	duplicateActionSkip = "skip"
+
+	// duplicateActionRerip rips the disc again, replacing the previous output.
+	duplicateActionRerip = "rerip"
+
+	// duplicateActionNewEdition rips the disc again as a new edition of the
+	// movie, keeping the previous output.
+	duplicateActionNewEdition = "new-edition"
+)
+
+var duplicateActions = []string{duplicateActionSkip, duplicateActionRerip, duplicateActionNewEdition}
+
+func validateDuplicateAction(a string) error {
+	if !slices.Contains(duplicateActions, a) {
+		return fmt.Errorf("invalid duplicate action %q (expected one of %q)", a, duplicateActions)
+	}
+
+	return nil
+}
+
+// defaultEdition returns the default edition name of a disc that was ripped n
+// times before.
+func defaultEdition(n int) string {
+	return fmt.Sprintf("Edition %d", n+1)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+)
+
+const (
+	extraCategoryFeaturettes     = "featurettes"
+	extraCategoryBehindTheScenes = "behind-the-scenes"
+	extraCategoryDeletedScenes   = "deleted-scenes"
+	extraCategoryOther           = "other"
+
+	// extraCategorySkip means the extra is not ripped.
+	extraCategorySkip = "skip"
+)
+
+var (
+	extraCategories = []string{extraCategoryFeaturettes, extraCategoryBehindTheScenes, extraCategoryDeletedScenes, extraCategoryOther, extraCategorySkip}
+
+	// extraDirNames are the names of the subdirectories of the movie directory
+	// in which Plex and Jellyfin look for extras.
+	extraDirNames = map[string]string{
+		extraCategoryFeaturettes:     "Featurettes",
+		extraCategoryBehindTheScenes: "Behind The Scenes",
+		extraCategoryDeletedScenes:   "Deleted Scenes",
+		extraCategoryOther:           "Other",
+	}
+)
+
+// rippedTitle is a title that was backed up to path.
+type rippedTitle struct {
+	title *makemkv.Title
+	path  string
+}
+
+func validateExtraCategory(c string) error {
+	if !slices.Contains(extraCategories, c) {
+		return fmt.Errorf("invalid extra category %q (expected one of %q)", c, extraCategories)
+	}
+
+	return nil
+}
+
+// findExtras returns the titles other than the main title that are at least
+// minLength long. Titles that share segments with the main title (e.g.,
+// other cuts of the movie) or repeat the segments of an earlier extra (e.g.,
+// other angles) are ignored.
+func findExtras(disc *makemkv.Disc, main *makemkv.Title, minLength time.Duration) []*makemkv.Title {
+	mainSegments := main.Segments()
+	seen := map[string]bool{}
+
+	var extras []*makemkv.Title
+	for _, title := range disc.Titles {
+		if title == main {
+			continue
+		}
+
+		if d, err := title.GetAttrDuration(defs.Duration); err != nil || d < minLength {
+			continue
+		}
+
+		if segments := title.Segments(); len(segments) > 0 {
+			key := fmt.Sprint(segments)
+			if seen[key] || slices.ContainsFunc(segments, func(segment int) bool {
+				return slices.Contains(mainSegments, segment)
+			}) {
+				continue
+			}
+			seen[key] = true
+		}
+
+		extras = append(extras, title)
+	}
+
+	return extras
+}
+
+// extraPath returns the path of an extra of the movie at moviePath.
+func extraPath(moviePath, category string, title *makemkv.Title) string {
+	return filepath.Join(filepath.Dir(moviePath), extraDirNames[category], fmt.Sprintf("Title %d.mkv", title.Index))
+}
+
+// backupExtras backs up the extras into the subdirectories of the movie
+// directory given by their categories. Failures are logged rather than
+// returned, since the movie itself was backed up. It returns the extras that
+// were backed up.
+func (app *application) backupExtras(ctx context.Context, src *discSource, view driveUI, extras []*makemkv.Title, categories []string, moviePath string, overwrite bool) []*rippedTitle {
+	var ripped []*rippedTitle
+	for i, title := range extras {
+		if categories[i] == extraCategorySkip {
+			continue
+		}
+
+		dstPath := extraPath(moviePath, categories[i], title)
+		view.setTitleInfo(title)
+		view.setStatus("Backing up extra %d of %d", i+1, len(extras))
+		if err := app.backupTitle(ctx, src, view, title, dstPath, overwrite); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+
+			slog.Error("failed to back up extra", "disc", src, "title", title.Index, "err", err)
+			continue
+		}
+
+		slog.Info("backed up extra", "disc", src, "title", title.Index, "category", categories[i], "path", dstPath)
+		ripped = append(ripped, &rippedTitle{title: title, path: dstPath})
+	}
+
+	return ripped
+}
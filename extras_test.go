@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+)
+
+func TestFindExtras(t *testing.T) {
+	disc := &makemkv.Disc{}
+	for i, title := range []struct {
+		duration, segments string
+	}{
+		{"1:52:10", "1,2"},   // the main feature
+		{"1:58:00", "1,3"},   // another cut of the main feature
+		{"0:25:00", "20"},    // a featurette
+		{"0:25:00", "20"},    // another angle of the featurette
+		{"0:02:30", "10"},    // a trailer, which is too short
+		{"0:12:00", "30-32"}, // deleted scenes
+		{"0:08:00", ""},      // a title without segments
+		{"", "40"},           // a title without a duration
+	} {
+		info := makemkv.Info{}
+		if title.duration != "" {
+			info = append(info, &makemkv.Attribute{ID: int(defs.Duration), Value: makemkv.Str(title.duration)})
+		}
+		if title.segments != "" {
+			info = append(info, &makemkv.Attribute{ID: int(defs.SegmentsMap), Value: makemkv.Str(title.segments)})
+		}
+		disc.GetTitle(i).Info = info
+	}
+
+	var indexes []int
+	for _, title := range findExtras(disc, disc.Titles[0], 5*time.Minute) {
+		indexes = append(indexes, title.Index)
+	}
+	assert.Equal(t, []int{2, 5, 6}, indexes)
+
+	// Without a minimum length, the trailer is an extra too. The other angle
+	// of the main title is not, and titles without a duration never are.
+	indexes = nil
+	for _, title := range findExtras(disc, disc.Titles[2], 0) {
+		indexes = append(indexes, title.Index)
+	}
+	assert.Equal(t, []int{0, 1, 4, 5, 6}, indexes)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/jobs"
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+const (
+	// metadataPolicyFirstResult accepts the most relevant movie database
+	// result.
+	metadataPolicyFirstResult = "first-result"
+
+	// metadataPolicyDiscName ignores the movie database and names the output
+	// after the disc.
+	metadataPolicyDiscName = "disc-name"
+
+	// titlePolicyLowestIndex chooses the tied title with the lowest index.
+	titlePolicyLowestIndex = "lowest-index"
+
+	// titlePolicyHighestIndex chooses the tied title with the highest index.
+	titlePolicyHighestIndex = "highest-index"
+
+	// titlePolicyLargest chooses the tied title with the most bytes.
+	titlePolicyLargest = "largest"
+)
+
+var (
+	metadataPolicies = []string{metadataPolicyFirstResult, metadataPolicyDiscName}
+	titlePolicies    = []string{titlePolicyLowestIndex, titlePolicyHighestIndex, titlePolicyLargest}
+)
+
+// headlessPolicy determines how the headless interface answers the questions
+// that the text user interface asks the user.
+//
+// If the movie database search finds nothing, the disc name is used regardless
+// of policy.
+type headlessPolicy struct {
+	metadata  string
+	title     string
+	duplicate string
+	extras    string
+}
+
+func (p *headlessPolicy) validate() error {
+	if !slices.Contains(metadataPolicies, p.metadata) {
+		return fmt.Errorf("invalid metadata policy %q (expected one of %q)", p.metadata, metadataPolicies)
+	}
+
+	if !slices.Contains(titlePolicies, p.title) {
+		return fmt.Errorf("invalid title policy %q (expected one of %q)", p.title, titlePolicies)
+	}
+
+	if err := validateDuplicateAction(p.duplicate); err != nil {
+		return err
+	}
+
+	if err := validateExtraCategory(p.extras); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// headlessInterface is a userInterface for running without a terminal, e.g.,
+// as a service. Decisions are made according to a headlessPolicy and logged.
+type headlessInterface struct {
+	*beeper
+
+	policy *headlessPolicy
+
+	done chan struct{}
+	stop func()
+}
+
+var _ userInterface = (*headlessInterface)(nil)
+
+func newHeadlessInterface(beeper *beeper, policy *headlessPolicy) *headlessInterface {
+	done := make(chan struct{})
+
+	return &headlessInterface{
+		beeper: beeper,
+		policy: policy,
+		done:   done,
+		stop: sync.OnceFunc(func() {
+			close(done)
+		}),
+	}
+}
+
+// run blocks until Stop is called.
+func (h *headlessInterface) run() error {
+	<-h.done
+	return nil
+}
+
+func (h *headlessInterface) Stop() {
+	h.stop()
+}
+
+// waitForInterrupt returns after SIGINT or SIGTERM.
+func (h *headlessInterface) waitForInterrupt() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	<-ctx.Done()
+}
+
+func (h *headlessInterface) logWriter() io.Writer {
+	return os.Stderr
+}
+
+func (h *headlessInterface) setStatus(format string, args ...any) {
+	slog.Info(fmt.Sprintf(format, args...))
+}
+
+// showJobs does nothing, since the jobs are logged.
+func (h *headlessInterface) showJobs(*jobs.Queue) {}
+
+func (h *headlessInterface) updateJobs() {}
+
+func (h *headlessInterface) addView(string) driveUI {
+	return &headlessDrive{
+		policy: h.policy,
+	}
+}
+
+// headlessDrive is the driveUI of the headless interface.
+type headlessDrive struct {
+	policy *headlessPolicy
+	src    *discSource
+}
+
+func (d *headlessDrive) setSource(src *discSource) {
+	d.src = src
+}
+
+func (d *headlessDrive) setStatus(format string, args ...any) {
+	slog.Debug(fmt.Sprintf(format, args...), "disc", d.src)
+}
+
+func (d *headlessDrive) setTask(format string, args ...any) {
+	slog.Debug(fmt.Sprintf(format, args...), "disc", d.src)
+}
+
+func (d *headlessDrive) setSubtask(string, ...any) {}
+
+func (d *headlessDrive) setProgress(float64) {}
+
+func (d *headlessDrive) setDiscInfo(makemkv.Info) {}
+
+func (d *headlessDrive) setMovieMetadata(*moviedb.MovieMetadata) {}
+
+func (d *headlessDrive) setTitleInfo(*makemkv.Title) {}
+
+func (d *headlessDrive) getMovieTitleForSearch(_ context.Context, q string) (string, error) {
+	slog.Info("automatically accepted search query", "disc", d.src, "query", q)
+	return q, nil
+}
+
+// chooseMovieMetadata chooses the most relevant result or, if there are none,
+// falls back to the query.
+func (d *headlessDrive) chooseMovieMetadata(_ context.Context, q string, results []*moviedb.MovieMetadata, _ movieSearchFunc) (*moviedb.MovieMetadata, error) {
+	if len(results) == 0 {
+		slog.Info("no movie metadata found; falling back to disc name", "disc", d.src, "query", q)
+		return &moviedb.MovieMetadata{
+			Name:   q,
+			Source: metadataSourceQuery,
+		}, nil
+	}
+
+	return results[0], nil
+}
+
+func (d *headlessDrive) getMovieMetadata(_ context.Context, q string, md *moviedb.MovieMetadata) (*moviedb.MovieMetadata, error) {
+	if d.policy.metadata == metadataPolicyDiscName {
+		md = &moviedb.MovieMetadata{
+			Name:   q,
+			Source: metadataSourceQuery,
+		}
+	}
+
+	slog.Info("automatically accepted movie metadata", "disc", d.src, "policy", d.policy.metadata, "name", md.Name, "year", md.Year, "id", md.ID)
+	return md, nil
+}
+
+func (d *headlessDrive) getBestTitle(_ context.Context, choices []*makemkv.Title) (*makemkv.Title, error) {
+	indexes := make([]int, len(choices))
+	for i, choice := range choices {
+		indexes[i] = choice.Index
+	}
+
+	if d.policy.title == titlePolicyLargest {
+		// Fall back to the lowest index if sizes are unknown or tied.
+		if largest := makemkv.Maximums(choices, func(title *makemkv.Title) (int, error) {
+			return title.GetAttrInt(defs.DiscSizeBytes)
+		}); len(largest) > 0 {
+			choices = largest
+		}
+	}
+
+	title := slices.MinFunc(choices, compareTitleIndex)
+	if d.policy.title == titlePolicyHighestIndex {
+		title = slices.MaxFunc(choices, compareTitleIndex)
+	}
+
+	slog.Info("automatically chose best title", "disc", d.src, "policy", d.policy.title, "title", title.Index, "choices", indexes)
+	return title, nil
+}
+
+func (d *headlessDrive) getDuplicateAction(_ context.Context, prior []*history.Record) (string, string, error) {
+	edition := ""
+	if d.policy.duplicate == duplicateActionNewEdition {
+		edition = defaultEdition(len(prior))
+	}
+
+	slog.Info("automatically chose duplicate action", "disc", d.src, "action", d.policy.duplicate, "edition", edition)
+	return d.policy.duplicate, edition, nil
+}
+
+// classifyExtras puts every extra in the category given by the policy.
+func (d *headlessDrive) classifyExtras(_ context.Context, extras []*makemkv.Title) ([]string, error) {
+	categories := make([]string, len(extras))
+	indexes := make([]int, len(extras))
+	for i, title := range extras {
+		categories[i] = d.policy.extras
+		indexes[i] = title.Index
+	}
+
+	slog.Info("automatically classified extras", "disc", d.src, "category", d.policy.extras, "titles", indexes)
+	return categories, nil
+}
+
+func (d *headlessDrive) getEpisodeNumbering(_ context.Context, series *moviedb.MovieMetadata, episodes []*makemkv.Title, season, firstEpisode int) (int, int, error) {
+	slog.Info("automatically accepted episode numbering", "disc", d.src, "series", series.Name, "season", season, "first", firstEpisode, "count", len(episodes))
+	return season, firstEpisode, nil
+}
+
+func compareTitleIndex(a, b *makemkv.Title) int {
+	return a.Index - b.Index
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	defaultHistoryFileName = "mkvbot-history.jsonl"
+
+	historyFormatTable = "table"
+	historyFormatCSV   = "csv"
+	historyFormatJSON  = "json"
+)
+
+var historyFormats = []string{historyFormatTable, historyFormatCSV, historyFormatJSON}
+
+// historyFilePath returns the path of the history file, which is in the
+// output directory unless specified.
+func historyFilePath(cmd *cli.Command) string {
+	if path := cmd.String(historyFlagName); path != "" {
+		return path
+	}
+
+	return filepath.Join(cmd.String(outputDirFlagName), defaultHistoryFileName)
+}
+
+func runHistory(_ context.Context, cmd *cli.Command) error {
+	format := cmd.String(formatFlagName)
+	if !slices.Contains(historyFormats, format) {
+		return fmt.Errorf("invalid format %q (expected one of %q)", format, historyFormats)
+	}
+
+	records, err := history.OpenReadOnly(historyFilePath(cmd)).Records()
+	if err != nil {
+		return fmt.Errorf("read history: %w", err)
+	}
+
+	q, outcome := cmd.String(searchFlagName), history.Outcome(cmd.String(outcomeFlagName))
+	records = slices.DeleteFunc(records, func(r *history.Record) bool {
+		return (q != "" && !r.Matches(q)) || (outcome != "" && r.Outcome != outcome)
+	})
+
+	w := cmd.Root().Writer
+	switch format {
+	case historyFormatCSV:
+		return history.WriteCSV(w, records)
+	case historyFormatJSON:
+		return history.WriteJSON(w, records)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tDISC\tTITLE\tNAME\tYEAR\tOUTCOME\tOUTPUT")
+	for _, r := range records {
+		title := "-"
+		if r.TitleIndex >= 0 {
+			title = strconv.Itoa(r.TitleIndex)
+		}
+
+		output := r.OutputPath
+		if r.Outcome != history.OutcomeSuccess {
+			output = r.Error
+		}
+
+		name := r.Name
+		if r.Episode > 0 {
+			name = fmt.Sprintf("%s S%02dE%02d", name, r.Season, r.Episode)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", r.StartTime.Format(time.DateTime), r.DiscName, title, name, r.Year, r.Outcome, output)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/hook"
+	"github.com/curt-hash/mkvbot/pkg/jobs"
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+const (
+	// hookEnvPrefix is the prefix of the environment variables set for hooks.
+	hookEnvPrefix = "MKVBOT_"
+
+	// hookRetryDelay is the delay before a failed hook is run again.
+	hookRetryDelay = 10 * time.Second
+)
+
+// hookEvent is the JSON document that hooks receive on standard input.
+type hookEvent struct {
+	Record *history.Record `json:"record"`
+	IMDbID string          `json:"imdbId,omitempty"`
+	TMDbID string          `json:"tmdbId,omitempty"`
+
+	// Title maps the names of the title's attributes, e.g., "Duration", to
+	// their values.
+	Title map[string]string `json:"title,omitempty"`
+}
+
+// newHookEvent describes the successful rip of title (nil for a disc backup).
+func newHookEvent(rec *history.Record, md *moviedb.MovieMetadata, title *makemkv.Title) *hookEvent {
+	event := &hookEvent{
+		Record: rec,
+		IMDbID: md.IMDbID,
+		TMDbID: md.TMDbID,
+	}
+
+	if title != nil {
+		event.Title = make(map[string]string, len(title.Info))
+		for _, attr := range title.Info {
+			event.Title[defs.Attr(attr.ID).String()] = string(attr.Value)
+		}
+	}
+
+	return event
+}
+
+// env returns the environment variables that describe the event, e.g.,
+// MKVBOT_OUTPUT_PATH and MKVBOT_TITLE_DURATION.
+func (e *hookEvent) env() []string {
+	rec := e.Record
+	vars := map[string]string{
+		"OUTPUT_PATH": rec.OutputPath,
+		"EXTRA_PATHS": strings.Join(rec.ExtraPaths, string(os.PathListSeparator)),
+		"DISC_BACKUP": strconv.FormatBool(rec.DiscBackup),
+		"DISC_NAME":   rec.DiscName,
+		"DRIVE":       rec.Drive,
+		"TITLE_INDEX": strconv.Itoa(rec.TitleIndex),
+		"NAME":        rec.Name,
+		"YEAR":        strconv.Itoa(rec.Year),
+		"MOVIE_ID":    rec.MovieID,
+		"IMDB_ID":     e.IMDbID,
+		"TMDB_ID":     e.TMDbID,
+		"EDITION":     rec.Edition,
+		"SEASON":      strconv.Itoa(rec.Season),
+		"EPISODE":     strconv.Itoa(rec.Episode),
+	}
+	for name, value := range e.Title {
+		vars["TITLE_"+screamingSnakeCase(name)] = value
+	}
+
+	env := make([]string, 0, len(vars))
+	for name, value := range vars {
+		env = append(env, hookEnvPrefix+name+"="+value)
+	}
+	slices.Sort(env)
+
+	return env
+}
+
+// screamingSnakeCase converts a name like "OutputFileName" to
+// "OUTPUT_FILE_NAME".
+func screamingSnakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+
+	return b.String()
+}
+
+// finalHookEvent returns a copy of the event with the paths that the files
+// have when the hooks run, i.e., in the library if there is one.
+func (app *application) finalHookEvent(event *hookEvent) *hookEvent {
+	finalPath := func(path string) string {
+		if app.cfg.libraryDirPath == "" {
+			return path
+		}
+
+		_, dst, err := app.libraryPath(path)
+		if err != nil {
+			return path
+		}
+		return dst
+	}
+
+	rec := *event.Record
+	rec.OutputPath = finalPath(rec.OutputPath)
+	rec.ExtraPaths = make([]string, len(event.Record.ExtraPaths))
+	for i, path := range event.Record.ExtraPaths {
+		rec.ExtraPaths[i] = finalPath(path)
+	}
+
+	e := *event
+	e.Record = &rec
+	return &e
+}
+
+// newHookJobs returns a job per post-rip hook, in order, for the event.
+func (app *application) newHookJobs(event *hookEvent) ([]*jobs.Job, error) {
+	if len(app.cfg.hooks) == 0 {
+		return nil, nil
+	}
+
+	input, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("encode hook event: %w", err)
+	}
+
+	hookJobs := make([]*jobs.Job, len(app.cfg.hooks))
+	for i, cfg := range app.cfg.hooks {
+		hookJobs[i] = &jobs.Job{
+			Kind:        jobKindHook,
+			Description: filepath.Base(event.Record.OutputPath),
+			Params: map[string]string{
+				"command": cfg.Command,
+				"timeout": cfg.Timeout.String(),
+				"retries": strconv.Itoa(cfg.Retries),
+				"event":   string(input),
+			},
+		}
+	}
+
+	return hookJobs, nil
+}
+
+// hook runs the job's hook command with the event of the job and adds its
+// result to the history record of the rip. The hook is retried as configured
+// before the job fails.
+func (app *application) hook(ctx context.Context, job *jobs.Job, _ func(float64)) error {
+	input := []byte(job.Params["event"])
+	var event hookEvent
+	if err := json.Unmarshal(input, &event); err != nil {
+		return fmt.Errorf("decode hook event: %w", err)
+	}
+	if event.Record == nil {
+		return errors.New("hook event has no record")
+	}
+
+	timeout, _ := time.ParseDuration(job.Params["timeout"])
+	retries, _ := strconv.Atoi(job.Params["retries"])
+	cfg := &hook.Config{
+		Command:    job.Params["command"],
+		Timeout:    timeout,
+		Retries:    retries,
+		RetryDelay: hookRetryDelay,
+	}
+
+	res := hook.Run(ctx, cfg, event.env(), input)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	result := &history.HookResult{
+		Command:  cfg.Command,
+		Attempts: res.Attempts,
+		ExitCode: res.ExitCode,
+	}
+	output := strings.TrimSpace(res.Output)
+	if res.Err != nil {
+		result.Error = res.Err.Error()
+		slog.Error("hook failed", "command", cfg.Command, "path", event.Record.OutputPath, "exitCode", res.ExitCode, "attempts", res.Attempts, "err", res.Err, "output", output)
+	} else {
+		slog.Info("hook succeeded", "command", cfg.Command, "path", event.Record.OutputPath, "exitCode", res.ExitCode, "attempts", res.Attempts, "output", output)
+	}
+	app.addHookResult(event.Record, result)
+
+	if res.Err != nil {
+		return fmt.Errorf("run %q: %w", cfg.Command, res.Err)
+	}
+
+	return nil
+}
+
+// addHookResult adds the result of a hook to the history record of the rip,
+// replacing the result of an earlier run of the same hook.
+func (app *application) addHookResult(rip *history.Record, result *history.HookResult) {
+	err := app.history.Update(func(r *history.Record) bool {
+		return r.StartTime.Equal(rip.StartTime) && r.Drive == rip.Drive && r.TitleIndex == rip.TitleIndex && r.Episode == rip.Episode
+	}, func(r *history.Record) {
+		r.Hooks = slices.DeleteFunc(r.Hooks, func(h *history.HookResult) bool {
+			return h.Command == result.Command
+		})
+		r.Hooks = append(r.Hooks, result)
+	})
+	if err != nil {
+		slog.Error("failed to add hook result to history", "command", result.Command, "path", app.history.Path(), "err", err)
+	}
+}
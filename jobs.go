@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/jobs"
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/matroska"
+	"github.com/gen2brain/beeep"
+)
+
+const (
+	// defaultJobsFileName is the name of the job queue file in the output
+	// directory.
+	defaultJobsFileName = "mkvbot-jobs.json"
+
+	// The kinds of the jobs that follow the rip of a title.
+	jobKindVerify    = "verify"
+	jobKindTranscode = "transcode"
+	jobKindMove      = "move"
+	jobKindNotify    = "notify"
+	jobKindHook      = "hook"
+)
+
+// jobsView shows a summary of the job queue in a status box, which is added
+// when the first job shows up.
+type jobsView struct {
+	ui userInterface
+
+	mu      sync.Mutex
+	view    driveUI
+	status  string
+	running int
+}
+
+// update summarizes the jobs.
+func (v *jobsView) update(all []jobs.Job) {
+	var queued, failed int
+	var running []jobs.Job
+	for _, job := range all {
+		switch job.State {
+		case jobs.StateQueued:
+			queued++
+		case jobs.StateRunning:
+			running = append(running, job)
+		case jobs.StateFailed:
+			failed++
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.view == nil {
+		if len(running)+queued+failed == 0 {
+			return
+		}
+		v.view = v.ui.addView("Jobs")
+	}
+
+	status := fmt.Sprintf("%d running, %d queued, %d failed", len(running), queued, failed)
+	if status != v.status {
+		v.status = status
+		v.view.setStatus("%s", status)
+	}
+
+	if len(running) == 0 {
+		if v.running != 0 {
+			v.running = 0
+			v.view.setTask("")
+			v.view.setProgress(0)
+		}
+		return
+	}
+
+	job := running[0]
+	if job.ID != v.running {
+		v.running = job.ID
+		v.view.setTask("%s %s", job.Kind, job.Description)
+	}
+	v.view.setProgress(job.Progress)
+}
+
+// openJobQueue opens the job queue and registers the handlers of the job
+// kinds. The jobs are shown by showJobs.
+func (app *application) openJobQueue() error {
+	q, err := jobs.Open(app.cfg.jobsFilePath)
+	if err != nil {
+		return err
+	}
+
+	for kind, h := range map[string]jobs.Handler{
+		jobKindVerify:    app.verify,
+		jobKindTranscode: app.transcode,
+		jobKindMove:      app.move,
+		jobKindNotify:    app.notify,
+		jobKindHook:      app.hook,
+	} {
+		q.Handle(kind, logJob(h))
+	}
+
+	app.jobs = q
+	app.jobsView = &jobsView{ui: app.ui}
+
+	return nil
+}
+
+// showJobs shows the jobs left from a previous run and keeps the user
+// interface up to date. It must be called after the user interface starts and
+// before the jobs change.
+func (app *application) showJobs() {
+	app.jobs.OnChange(func() {
+		app.jobsView.update(app.jobs.Jobs())
+		app.ui.updateJobs()
+	})
+
+	app.ui.showJobs(app.jobs)
+	app.jobsView.update(app.jobs.Jobs())
+}
+
+// countUnfinishedJobs returns the number of queued and failed jobs.
+func (app *application) countUnfinishedJobs() int {
+	var n int
+	for _, job := range app.jobs.Jobs() {
+		if job.State == jobs.StateQueued || job.State == jobs.StateFailed {
+			n++
+		}
+	}
+
+	return n
+}
+
+// logJob logs the start and end of the jobs run by h.
+func logJob(h jobs.Handler) jobs.Handler {
+	return func(ctx context.Context, job *jobs.Job, progress func(float64)) error {
+		slog.Info("running job", "job", job.ID, "kind", job.Kind, "description", job.Description, "attempt", job.Attempts)
+
+		start := time.Now()
+		err := h(ctx, job, progress)
+		switch {
+		case err == nil:
+			slog.Info("job succeeded", "job", job.ID, "kind", job.Kind, "elapsed", time.Since(start).Round(time.Second))
+		case ctx.Err() != nil:
+			slog.Warn("job interrupted", "job", job.ID, "kind", job.Kind)
+		default:
+			slog.Error("job failed", "job", job.ID, "kind", job.Kind, "err", err)
+		}
+
+		return err
+	}
+}
+
+// queueFollowUpJobs queues the jobs that follow the rip of the title to path:
+// verify the file, transcode it, move it and its sidecar files to the library,
+// send a desktop notification and run the hooks, as configured. Each job runs
+// after the one before it succeeds. event describes the rip of a movie or
+// episode for the notification and hooks; it is nil for extras.
+func (app *application) queueFollowUpJobs(src *discSource, title *makemkv.Title, path string, sidecars []string, event *hookEvent) {
+	// The jobs may run after a restart from another working directory.
+	path, err := filepath.Abs(path)
+	if err != nil {
+		slog.Error("failed to get absolute path", "disc", src, "path", path, "err", err)
+		return
+	}
+
+	var followUps []*jobs.Job
+	if app.cfg.transcodePreset != "" {
+		duration, _ := title.GetAttrDuration(defs.Duration)
+		followUps = append(followUps, app.newTranscodeJob(path, duration))
+
+		// The kept original moves along with the title.
+		if app.cfg.keepOriginal {
+			sidecars = append(slices.Clip(sidecars), originalPath(path))
+		}
+	}
+
+	finalPath := path
+	if app.cfg.libraryDirPath != "" {
+		outputDir, err := filepath.Abs(app.cfg.outputDirPath)
+		if err != nil {
+			slog.Error("failed to get absolute path", "disc", src, "path", app.cfg.outputDirPath, "err", err)
+			return
+		}
+
+		for i, p := range append([]string{path}, sidecars...) {
+			abs, dst, err := app.libraryPath(p)
+			if err != nil {
+				slog.Error("failed to get library path", "disc", src, "path", p, "err", err)
+				return
+			}
+
+			if i == 0 {
+				finalPath = dst
+			}
+			followUps = append(followUps, &jobs.Job{
+				Kind:        jobKindMove,
+				Description: filepath.Base(abs),
+				Params:      map[string]string{"path": abs, "dst": dst, "outputDir": outputDir},
+			})
+		}
+	}
+
+	if event != nil {
+		if app.cfg.notify {
+			name := strings.TrimSuffix(filepath.Base(finalPath), filepath.Ext(finalPath))
+			followUps = append(followUps, &jobs.Job{
+				Kind:        jobKindNotify,
+				Description: name,
+				Params:      map[string]string{"message": name + " is ready"},
+			})
+		}
+
+		hookJobs, err := app.newHookJobs(app.finalHookEvent(event))
+		if err != nil {
+			slog.Error("failed to queue hooks", "disc", src, "path", path, "err", err)
+		}
+		followUps = append(followUps, hookJobs...)
+	}
+
+	if len(followUps) == 0 {
+		return
+	}
+
+	verify := &jobs.Job{
+		Kind:        jobKindVerify,
+		Description: filepath.Base(path),
+		Params:      map[string]string{"path": path},
+	}
+	if err := app.jobs.Add(append([]*jobs.Job{verify}, followUps...)...); err != nil {
+		slog.Error("failed to queue jobs", "disc", src, "path", path, "err", err)
+		return
+	}
+
+	slog.Info("queued follow-up jobs", "disc", src, "path", path, "count", len(followUps)+1)
+}
+
+// libraryPath returns the absolute path of the file at path in the output
+// directory and the path it is moved to in the library.
+func (app *application) libraryPath(path string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	outputDir, err := filepath.Abs(app.cfg.outputDirPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	libraryDir, err := filepath.Abs(app.cfg.libraryDirPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	rel, err := filepath.Rel(outputDir, abs)
+	if err != nil {
+		return "", "", err
+	}
+
+	return abs, filepath.Join(libraryDir, rel), nil
+}
+
+// queueHookJobs queues the hooks for the event, which describes a rip without
+// follow-up jobs, such as a disc backup.
+func (app *application) queueHookJobs(src *discSource, event *hookEvent) {
+	hookJobs, err := app.newHookJobs(event)
+	if err != nil {
+		slog.Error("failed to queue hooks", "disc", src, "err", err)
+		return
+	}
+
+	if len(hookJobs) == 0 {
+		return
+	}
+
+	if err := app.jobs.Add(hookJobs...); err != nil {
+		slog.Error("failed to queue jobs", "disc", src, "err", err)
+		return
+	}
+
+	slog.Info("queued hooks", "disc", src, "count", len(hookJobs))
+}
+
+// verify checks that the ripped title is still a readable Matroska file with
+// tracks before the jobs that follow it touch the file. The rip was checked
+// against the disc when it finished, but the title is not known here, since
+// the job may run after a restart.
+func (app *application) verify(_ context.Context, job *jobs.Job, _ func(float64)) error {
+	path := job.Params["path"]
+	f, err := matroska.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if len(f.Tracks) == 0 {
+		return fmt.Errorf("%q has no tracks", path)
+	}
+
+	return nil
+}
+
+// move moves the file to its place in the library, replacing any file there,
+// and removes the directories of the output directory that are left empty.
+func (app *application) move(ctx context.Context, job *jobs.Job, progress func(float64)) error {
+	src, dst, outputDir := job.Params["path"], job.Params["dst"], job.Params["outputDir"]
+	if err := os.MkdirAll(filepath.Dir(dst), 0775); err != nil {
+		return fmt.Errorf("make directory for %q: %w", dst, err)
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		// The library may be on another file system.
+		if err := copyFile(ctx, src, dst, progress); err != nil {
+			return err
+		}
+
+		if err := os.Remove(src); err != nil {
+			return fmt.Errorf("remove %q: %w", src, err)
+		}
+	}
+
+	for dir := filepath.Dir(src); isBelow(dir, outputDir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// notify sends a desktop notification.
+func (app *application) notify(_ context.Context, job *jobs.Job, _ func(float64)) error {
+	return beeep.Notify("mkvbot", job.Params["message"], "")
+}
+
+// isBelow returns true if path is in the directory dir or its subdirectories.
+func isBelow(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// copyFile copies the file at src to dst through a temporary file next to dst
+// and reports the progress.
+func copyFile(ctx context.Context, src, dst string, progress func(float64)) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", src, err)
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %q: %w", src, err)
+	}
+
+	tmpPath := dst + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("create %q: %w", tmpPath, err)
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, os.Remove(tmpPath))
+		}
+	}()
+
+	w := &progressWriter{w: out, size: fi.Size(), progress: progress, stop: ctx.Err}
+	_, err = io.Copy(w, in)
+	if err = errors.Join(err, out.Close()); err != nil {
+		return fmt.Errorf("copy %q to %q: %w", src, tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return fmt.Errorf("rename %q to %q: %w", tmpPath, dst, err)
+	}
+
+	return nil
+}
+
+// progressWriter reports the progress of writing size bytes to w, in steps of
+// one percent, and fails once stop returns an error, e.g., ctx.Err.
+type progressWriter struct {
+	w        io.Writer
+	size     int64
+	written  int64
+	percent  int64
+	progress func(float64)
+	stop     func() error
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	if err := pw.stop(); err != nil {
+		return 0, err
+	}
+
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	if percent := pw.written * 100 / max(pw.size, 1); percent != pw.percent {
+		pw.percent = percent
+		pw.progress(float64(percent) / 100)
+	}
+
+	return n, err
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 
+	"github.com/curt-hash/mkvbot/pkg/hook"
 	"github.com/curt-hash/mkvbot/pkg/makemkv"
 	"github.com/urfave/cli/v3"
 )
@@ -24,20 +26,47 @@ func main() {
 }
 
 func run(ctx context.Context, cmd *cli.Command) error {
+	app, err := newApplicationFromCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	return app.run(ctx, app.doBackupLoop)
+}
+
+func runProcess(ctx context.Context, cmd *cli.Command) error {
+	paths := cmd.Args().Slice()
+	if len(paths) == 0 {
+		return errors.New("expected at least one PATH")
+	}
+
+	app, err := newApplicationFromCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	return app.run(ctx, func(ctx context.Context) error {
+		return app.processPaths(ctx, paths)
+	})
+}
+
+// newApplicationFromCommand configures the application with the command's
+// flags.
+func newApplicationFromCommand(cmd *cli.Command) (*application, error) {
 	if cmd.Bool(createProfileFlagName) {
 		name := "profile.xml"
 		if _, err := os.Stat(name); err == nil {
-			return fmt.Errorf("create %q: file exists", name)
+			return nil, fmt.Errorf("create %q: file exists", name)
 		}
 		if err := os.WriteFile(name, profileBytes, 0600); err != nil {
-			return fmt.Errorf("create %q: %w", name, err)
+			return nil, fmt.Errorf("create %q: %w", name, err)
 		}
 	}
 
 	profilePath := cmd.String(profileFlagName)
 	if _, err := os.Stat(profilePath); err == nil {
 		if profilePath, err = filepath.Abs(profilePath); err != nil {
-			return fmt.Errorf("get absolute path of %q: %w", profilePath, err)
+			return nil, fmt.Errorf("get absolute path of %q: %w", profilePath, err)
 		}
 	} else {
 		slog.Warn("profile does not exist", "path", profilePath)
@@ -49,25 +78,77 @@ func run(ctx context.Context, cmd *cli.Command) error {
 		weights[h.name] = cmd.Int64(h.flagName)
 	}
 
+	minLength := cmd.Int64(minLengthFlagName)
+	if !cmd.IsSet(minLengthFlagName) {
+		if cmd.Bool(tvFlagName) {
+			minLength = min(minLength, tvMinLengthSeconds)
+		}
+		if cmd.Bool(extrasFlagName) {
+			minLength = min(minLength, int64(cmd.Duration(extrasMinLengthFlagName).Seconds()))
+		}
+	}
+
 	cfg := &applicationConfig{
-		outputDirPath: cmd.String(outputDirFlagName),
+		outputDirPath:        cmd.String(outputDirFlagName),
+		nameTemplate:         cmd.String(nameTemplateFlagName),
+		tv:                   cmd.Bool(tvFlagName),
+		tvNameTemplate:       cmd.String(tvNameTemplateFlagName),
+		extras:               cmd.Bool(extrasFlagName),
+		extrasMinLength:      cmd.Duration(extrasMinLengthFlagName),
+		backupDisc:           cmd.Bool(backupDiscFlagName),
+		skipVerify:           cmd.Bool(skipVerifyFlagName),
+		skipTags:             cmd.Bool(skipTagsFlagName),
+		skipSidecars:         cmd.Bool(skipSidecarsFlagName),
+		movieDBs:             cmd.StringSlice(movieDBFlagName),
+		tmdbAPIKey:           cmd.String(tmdbAPIKeyFlagName),
+		imdbDatasetIndexPath: imdbDatasetIndexPath(cmd),
+		imdbDatasetTypes:     cmd.StringSlice(imdbDatasetTypesFlagName),
+		movieDBCacheDir:      movieDBCacheDir(cmd),
+		movieDBCacheTTL:      cmd.Duration(movieDBCacheTTLFlagName),
 		makemkvConfig: &makemkv.Config{
 			ExePath:          cmd.String(makemkvconFlagName),
 			ProfilePath:      profilePath,
 			ReadCacheSizeMB:  cmd.Int64(cacheFlagName),
-			MinLengthSeconds: cmd.Int64(minLengthFlagName),
+			MinLengthSeconds: minLength,
+			RecordDir:        cmd.String(recordFlagName),
+			ReplayDir:        cmd.String(replayFlagName),
 		},
 		debug:                      cmd.Bool(debugFlagName),
 		quiet:                      cmd.Bool(quietFlagName),
 		bestTitleHeuristicsWeights: weights,
 		askForTitle:                cmd.Bool(askForTitleFlagName),
 		logFilePath:                cmd.String(logFileFlagName),
+		historyFilePath:            historyFilePath(cmd),
+		headless:                   cmd.Bool(headlessFlagName),
+		transcodePreset:            cmd.String(transcodeFlagName),
+		ffmpegPath:                 cmd.String(ffmpegFlagName),
+		keepOriginal:               cmd.Bool(keepOriginalFlagName),
+		libraryDirPath:             cmd.String(libraryDirFlagName),
+		notify:                     cmd.Bool(notifyFlagName),
+		jobsFilePath:               filepath.Join(cmd.String(outputDirFlagName), defaultJobsFileName),
+		jobWorkers:                 cmd.Int(jobWorkersFlagName),
+		scanDelay:                  defaultScanDelay,
+		headlessPolicy: &headlessPolicy{
+			metadata:  cmd.String(metadataPolicyFlagName),
+			title:     cmd.String(titlePolicyFlagName),
+			duplicate: cmd.String(duplicatePolicyFlagName),
+			extras:    cmd.String(extrasPolicyFlagName),
+		},
+	}
+
+	for _, command := range cmd.StringSlice(hookFlagName) {
+		cfg.hooks = append(cfg.hooks, &hook.Config{
+			Command:    command,
+			Timeout:    cmd.Duration(hookTimeoutFlagName),
+			Retries:    cmd.Int(hookRetriesFlagName),
+			RetryDelay: hookRetryDelay,
+		})
 	}
 
 	app, err := newApplication(cfg)
 	if err != nil {
-		return fmt.Errorf("initialize application: %w", err)
+		return nil, fmt.Errorf("initialize application: %w", err)
 	}
 
-	return app.run(ctx)
+	return app, nil
 }
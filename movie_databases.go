@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	movieDBIMDb        = "imdb"
+	movieDBTMDb        = "tmdb"
+	movieDBIMDbDataset = "imdb-dataset"
+
+	// maxMovieDBResults is the maximum number of search results offered to the
+	// user.
+	maxMovieDBResults = 10
+
+	// defaultIMDbDatasetIndexFileName is the name of the IMDb dataset index in
+	// the user cache directory.
+	defaultIMDbDatasetIndexFileName = "imdb-title-basics.tsv"
+
+	// defaultMovieDBCacheDirName is the name of the directory of cached movie
+	// database results in the user cache directory.
+	defaultMovieDBCacheDirName = "moviedb"
+)
+
+var movieDBNames = []string{movieDBIMDb, movieDBTMDb, movieDBIMDbDataset}
+
+// imdbDatasetIndexPath returns the path of the IMDb dataset index, which is in
+// the user cache directory unless specified.
+func imdbDatasetIndexPath(cmd *cli.Command) string {
+	if path := cmd.String(imdbDatasetIndexFlagName); path != "" {
+		return path
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return defaultIMDbDatasetIndexFileName
+	}
+
+	return filepath.Join(dir, configDirName, defaultIMDbDatasetIndexFileName)
+}
+
+// movieDBCacheDir returns the directory of cached movie database results,
+// which is in the user cache directory unless specified.
+func movieDBCacheDir(cmd *cli.Command) string {
+	if dir := cmd.String(movieDBCacheDirFlagName); dir != "" {
+		return dir
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return defaultMovieDBCacheDirName
+	}
+
+	return filepath.Join(dir, configDirName, defaultMovieDBCacheDirName)
+}
+
+// runImportIMDbDataset imports the IMDb title.basics dataset into the index
+// used by the imdb-dataset movie database.
+func runImportIMDbDataset(_ context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected the path of title.basics.tsv.gz")
+	}
+
+	src := cmd.Args().First()
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open dataset: %w", err)
+	}
+	defer f.Close()
+
+	dst := imdbDatasetIndexPath(cmd)
+	n, err := moviedb.ImportIMDbDataset(dst, f)
+	if err != nil {
+		return fmt.Errorf("import %q: %w", src, err)
+	}
+
+	fmt.Fprintf(cmd.Root().Writer, "Indexed %d titles in %s\n", n, dst)
+	return nil
+}
+
+// namedMovieDB is a movie database backend selected with --moviedb.
+type namedMovieDB struct {
+	moviedb.MovieDB
+
+	name string
+}
+
+// newMovieDBs returns the movie databases named in cfg in the same order.
+func newMovieDBs(cfg *applicationConfig) ([]*namedMovieDB, error) {
+	if len(cfg.movieDBs) == 0 {
+		return nil, errors.New("no movie databases")
+	}
+
+	dbs := make([]*namedMovieDB, len(cfg.movieDBs))
+	for i, name := range cfg.movieDBs {
+		var (
+			db  moviedb.MovieDB
+			err error
+		)
+		switch name {
+		case movieDBIMDb:
+			db = moviedb.NewIMDb()
+		case movieDBTMDb:
+			db, err = moviedb.NewTMDb(&moviedb.TMDbConfig{
+				APIKey: cfg.tmdbAPIKey,
+			})
+		case movieDBIMDbDataset:
+			db, err = moviedb.NewIMDbDataset(&moviedb.IMDbDatasetConfig{
+				IndexPath: cfg.imdbDatasetIndexPath,
+				Types:     cfg.imdbDatasetTypes,
+			})
+		default:
+			err = fmt.Errorf("invalid movie database %q (expected one of %q)", name, movieDBNames)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// The offline database is fast enough without a cache.
+		if cfg.movieDBCacheTTL > 0 && name != movieDBIMDbDataset {
+			if db, err = moviedb.NewCache(db, &moviedb.CacheConfig{
+				Dir:       cfg.movieDBCacheDir,
+				Namespace: name,
+				TTL:       cfg.movieDBCacheTTL,
+			}); err != nil {
+				return nil, fmt.Errorf("initialize %s cache: %w", name, err)
+			}
+		}
+
+		dbs[i] = &namedMovieDB{
+			MovieDB: db,
+			name:    name,
+		}
+	}
+
+	return dbs, nil
+}
+
+// searchMovieDB returns the most relevant results of the first movie database
+// that returns any.
+func (app *application) searchMovieDB(ctx context.Context, q string) ([]*moviedb.MovieMetadata, error) {
+	return app.search(ctx, q, func(db *namedMovieDB) ([]*moviedb.MovieMetadata, error) {
+		return db.SearchMovies(ctx, q)
+	})
+}
+
+// searchSeriesDB returns the most relevant TV series of the first movie
+// database that supports them and returns any.
+func (app *application) searchSeriesDB(ctx context.Context, q string) ([]*moviedb.MovieMetadata, error) {
+	return app.search(ctx, q, func(db *namedMovieDB) ([]*moviedb.MovieMetadata, error) {
+		seriesDB, ok := db.MovieDB.(moviedb.SeriesDB)
+		if !ok {
+			return nil, moviedb.ErrNotSupported
+		}
+
+		return seriesDB.SearchSeries(ctx, q)
+	})
+}
+
+// search returns the first non-empty results of f, which is called with each
+// movie database in turn.
+func (app *application) search(ctx context.Context, q string, f func(db *namedMovieDB) ([]*moviedb.MovieMetadata, error)) ([]*moviedb.MovieMetadata, error) {
+	var errs error
+	for _, db := range app.movieDBs {
+		results, err := f(db)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil && len(results) == 0 {
+			err = errors.New("no results")
+		}
+		if err != nil {
+			slog.Debug("movie database search failed", "moviedb", db.name, "query", q, "err", err)
+			err = fmt.Errorf("%s: %w", db.name, err)
+			if errs != nil {
+				// Keep the message on one line, unlike errors.Join.
+				err = fmt.Errorf("%w; %w", errs, err)
+			}
+			errs = err
+			continue
+		}
+
+		results = results[:min(len(results), maxMovieDBResults)]
+		for _, md := range results {
+			md.Source = db.name
+		}
+		return results, nil
+	}
+
+	return nil, fmt.Errorf("search for %q: %w", q, errs)
+}
+
+// getEpisodeNames returns the names of the episodes of the season according
+// to the first movie database that knows them, indexed by episode number. It
+// returns nil if none does.
+func (app *application) getEpisodeNames(ctx context.Context, series *moviedb.MovieMetadata, season int) map[int]string {
+	for _, db := range app.movieDBs {
+		seriesDB, ok := db.MovieDB.(moviedb.SeriesDB)
+		if !ok {
+			continue
+		}
+
+		episodes, err := seriesDB.GetSeason(ctx, series, season)
+		if err != nil || len(episodes) == 0 {
+			if ctx.Err() == nil && !errors.Is(err, moviedb.ErrNotSupported) {
+				slog.Debug("episode lookup failed", "moviedb", db.name, "series", series.ID, "season", season, "err", err)
+			}
+			continue
+		}
+
+		names := make(map[int]string, len(episodes))
+		for _, episode := range episodes {
+			names[episode.Episode] = episode.Name
+		}
+
+		return names
+	}
+
+	slog.Warn("episode names not found", "series", series.Name, "id", series.ID, "season", season)
+	return nil
+}
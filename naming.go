@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+// tvEpisodePath is the common end of the TV presets. It expects the series name
+// and year in $n.
+const tvEpisodePath = `Season {{printf "%02d" .Season}}/` +
+	`{{$n}} - S{{printf "%02d" .Season}}E{{printf "%02d" .Episode}}{{with .EpisodeName}} - {{.}}{{end}}{{with .Edition}} - {{.}}{{end}}`
+
+const (
+	nameTemplatePlex     = "plex"
+	nameTemplateJellyfin = "jellyfin"
+	nameTemplateKodi     = "kodi"
+	nameTemplateEmby     = "emby"
+)
+
+var (
+	nameTemplatePresetNames = []string{nameTemplatePlex, nameTemplateJellyfin, nameTemplateKodi, nameTemplateEmby}
+
+	// nameTemplatePresets are the templates of the media servers' recommended
+	// naming schemes.
+	nameTemplatePresets = map[string]string{
+		// https://support.plex.tv/articles/naming-and-organizing-your-movie-media-files
+		nameTemplatePlex: `{{$n := .Name}}{{with .Year}}{{$n = printf "%s (%d)" $n .}}{{end}}` +
+			`{{with .ID}}{{$n = printf "%s {%s}" $n .}}{{end}}` +
+			`{{$n}}/{{$n}}{{with .Edition}} {edition-{{.}}}{{end}}`,
+
+		// https://jellyfin.org/docs/general/server/media/movies
+		nameTemplateJellyfin: `{{$n := .Name}}{{with .Year}}{{$n = printf "%s (%d)" $n .}}{{end}}` +
+			`{{$n}}{{with .IMDbID}} [imdbid-{{.}}]{{else}}{{with .TMDbID}} [tmdbid-{{.}}]{{end}}{{end}}/{{$n}}{{with .Edition}} - {{.}}{{end}}`,
+
+		// https://kodi.wiki/view/Naming_video_files/Movies
+		nameTemplateKodi: `{{$n := .Name}}{{with .Year}}{{$n = printf "%s (%d)" $n .}}{{end}}` +
+			`{{$n}}/{{$n}}{{with .Edition}} - {{.}}{{end}}`,
+
+		// https://emby.media/support/articles/Movie-Naming.html
+		nameTemplateEmby: `{{$n := .Name}}{{with .Year}}{{$n = printf "%s (%d)" $n .}}{{end}}` +
+			`{{$n}}{{with .IMDbID}} [imdbid={{.}}]{{else}}{{with .TMDbID}} [tmdbid={{.}}]{{end}}{{end}}/{{$n}}{{with .Edition}} - {{.}}{{end}}`,
+	}
+
+	// tvNameTemplatePresets are the templates of the media servers'
+	// recommended naming schemes for TV series. Episodes are named like "Show
+	// (2008) - S01E02 - Episode Title" in a "Season 01" directory.
+	tvNameTemplatePresets = map[string]string{
+		// https://support.plex.tv/articles/naming-and-organizing-your-tv-show-files
+		nameTemplatePlex: `{{$n := .Name}}{{with .Year}}{{$n = printf "%s (%d)" $n .}}{{end}}` +
+			`{{$n}}{{with .ID}} {{printf "{%s}" .}}{{end}}/` + tvEpisodePath,
+
+		// https://jellyfin.org/docs/general/server/media/shows
+		nameTemplateJellyfin: `{{$n := .Name}}{{with .Year}}{{$n = printf "%s (%d)" $n .}}{{end}}` +
+			`{{$n}}{{with .TMDbID}} [tmdbid-{{.}}]{{else}}{{with .IMDbID}} [imdbid-{{.}}]{{end}}{{end}}/` + tvEpisodePath,
+
+		// https://kodi.wiki/view/Naming_video_files/TV_shows
+		nameTemplateKodi: `{{$n := .Name}}{{with .Year}}{{$n = printf "%s (%d)" $n .}}{{end}}` +
+			`{{$n}}/` + tvEpisodePath,
+
+		// https://emby.media/support/articles/TV-Naming.html
+		nameTemplateEmby: `{{$n := .Name}}{{with .Year}}{{$n = printf "%s (%d)" $n .}}{{end}}` +
+			`{{$n}}{{with .TMDbID}} [tmdbid={{.}}]{{else}}{{with .IMDbID}} [imdbid={{.}}]{{end}}{{end}}/` + tvEpisodePath,
+	}
+)
+
+// nameData is the data available to name templates. Strings are sanitized
+// with sanitizeFileName, so they cannot introduce path separators.
+type nameData struct {
+	// Name and Year are the movie metadata.
+	Name string
+	Year int
+
+	// ID is the movie database identifier, e.g., "imdb-tt0118715".
+	ID string
+
+	// IMDbID is the IMDb identifier, e.g., "tt0118715", if known.
+	IMDbID string
+
+	// TMDbID is the TMDb identifier, e.g., "603", if known.
+	TMDbID string
+
+	// Edition distinguishes multiple rips of the same movie, e.g., "Director's
+	// Cut".
+	Edition string
+
+	// Resolution is the resolution of the first video stream of the title,
+	// e.g., "1080p".
+	Resolution string
+
+	// AudioCodec is the codec of the first audio stream of the title, e.g.,
+	// "TrueHD".
+	AudioCodec string
+
+	// DiscLabel is the name of the disc, e.g., "A Fake Movie".
+	DiscLabel string
+
+	// TitleIndex is the index of the title.
+	TitleIndex int
+
+	// Season, Episode and EpisodeName describe the title in TV mode. Name,
+	// Year and the IDs are those of the series.
+	Season      int
+	Episode     int
+	EpisodeName string
+}
+
+func newNameData(md *moviedb.MovieMetadata, edition string, disc *makemkv.Disc, title *makemkv.Title) *nameData {
+	data := &nameData{
+		Name:       sanitizeFileName(md.Name),
+		Year:       md.Year,
+		ID:         sanitizeFileName(md.ID),
+		Edition:    sanitizeFileName(edition),
+		DiscLabel:  sanitizeFileName(disc.GetAttrDefault(defs.Name, "")),
+		TitleIndex: title.Index,
+	}
+
+	imdbID, tmdbID := movieIDs(md)
+	data.IMDbID, data.TMDbID = sanitizeFileName(imdbID), sanitizeFileName(tmdbID)
+
+	for _, stream := range title.Streams {
+		switch stream.Type() {
+		case defs.TypeCodeVideo:
+			if data.Resolution == "" {
+				data.Resolution = resolution(stream.GetAttrDefault(defs.VideoSize, ""))
+			}
+		case defs.TypeCodeAudio:
+			if data.AudioCodec == "" {
+				data.AudioCodec = sanitizeFileName(stream.GetAttrDefault(defs.CodecShort, ""))
+			}
+		}
+	}
+
+	return data
+}
+
+// movieIDs returns the IMDb and TMDb identifiers of the movie, if known.
+func movieIDs(md *moviedb.MovieMetadata) (imdbID, tmdbID string) {
+	// Metadata from the history only has the ID.
+	imdbID, tmdbID = md.IMDbID, md.TMDbID
+	if id, ok := strings.CutPrefix(md.ID, "imdb-"); ok && imdbID == "" {
+		imdbID = id
+	}
+	if id, ok := strings.CutPrefix(md.ID, "tmdb-"); ok && tmdbID == "" {
+		tmdbID = id
+	}
+
+	return imdbID, tmdbID
+}
+
+// resolution converts a video size like "1920x1080" to a resolution like
+// "1080p". It returns "" if the size is malformed.
+func resolution(videoSize string) string {
+	var width, height int
+	if _, err := fmt.Sscanf(videoSize, "%dx%d", &width, &height); err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%dp", height)
+}
+
+// nameTemplate renders the path of an output file, relative to the output
+// directory, using "/" to separate directories.
+type nameTemplate struct {
+	*template.Template
+}
+
+// newNameTemplate parses s, which is either the name of one of the presets or
+// a text/template over nameData.
+func newNameTemplate(s string, presets map[string]string) (*nameTemplate, error) {
+	text, ok := presets[s]
+	if !ok {
+		text = s
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse name template: %w", err)
+	}
+
+	t := &nameTemplate{tmpl}
+
+	// Catch references to unknown fields early.
+	if _, err := t.render(&nameData{Name: "Name", Year: 2000, Season: 1, Episode: 1}); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// render returns the path of the output file with extension ".mkv". Each path
+// component is sanitized with sanitizeFileName. The values in data must not
+// contain path separators.
+func (t *nameTemplate) render(data *nameData) (string, error) {
+	for _, s := range []string{
+		data.Name, data.ID, data.IMDbID, data.TMDbID, data.Edition,
+		data.Resolution, data.AudioCodec, data.DiscLabel, data.EpisodeName,
+	} {
+		if strings.ContainsAny(s, `/\`) {
+			return "", fmt.Errorf("name template value %q contains a path separator", s)
+		}
+	}
+
+	var buf strings.Builder
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("execute name template: %w", err)
+	}
+
+	components := strings.Split(path.Clean(buf.String()), "/")
+	for i, component := range components {
+		component = strings.TrimSpace(sanitizeFileName(component))
+		switch component {
+		case "", ".", "..":
+			return "", fmt.Errorf("name template rendered invalid path %q", buf.String())
+		}
+		components[i] = component
+	}
+
+	return filepath.Join(components...) + ".mkv", nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameTemplatePresets(t *testing.T) {
+	movie := &nameData{Name: "The Matrix", Year: 1999, ID: "imdb-tt0133093", IMDbID: "tt0133093", TMDbID: "603"}
+	edition := &nameData{Name: "Blade Runner", Year: 1982, ID: "tmdb-78", TMDbID: "78", Edition: "Final Cut"}
+	unknown := &nameData{Name: "A Movie"}
+	episode := &nameData{
+		Name: "Breaking Bad", Year: 2008, ID: "tmdb-1396", IMDbID: "tt0903747", TMDbID: "1396",
+		Season: 1, Episode: 2, EpisodeName: "Cat's in the Bag...",
+	}
+
+	for _, tc := range []struct {
+		preset   string
+		presets  map[string]string
+		data     *nameData
+		expected string
+	}{
+		{nameTemplatePlex, nameTemplatePresets, movie, "The Matrix (1999) {imdb-tt0133093}/The Matrix (1999) {imdb-tt0133093}"},
+		{nameTemplatePlex, nameTemplatePresets, edition, "Blade Runner (1982) {tmdb-78}/Blade Runner (1982) {tmdb-78} {edition-Final Cut}"},
+		{nameTemplatePlex, nameTemplatePresets, unknown, "A Movie/A Movie"},
+		{nameTemplateJellyfin, nameTemplatePresets, movie, "The Matrix (1999) [imdbid-tt0133093]/The Matrix (1999)"},
+		{nameTemplateJellyfin, nameTemplatePresets, edition, "Blade Runner (1982) [tmdbid-78]/Blade Runner (1982) - Final Cut"},
+		{nameTemplateJellyfin, nameTemplatePresets, unknown, "A Movie/A Movie"},
+		{nameTemplateKodi, nameTemplatePresets, movie, "The Matrix (1999)/The Matrix (1999)"},
+		{nameTemplateKodi, nameTemplatePresets, edition, "Blade Runner (1982)/Blade Runner (1982) - Final Cut"},
+		{nameTemplateKodi, nameTemplatePresets, unknown, "A Movie/A Movie"},
+		{nameTemplateEmby, nameTemplatePresets, movie, "The Matrix (1999) [imdbid=tt0133093]/The Matrix (1999)"},
+		{nameTemplateEmby, nameTemplatePresets, edition, "Blade Runner (1982) [tmdbid=78]/Blade Runner (1982) - Final Cut"},
+		{nameTemplateEmby, nameTemplatePresets, unknown, "A Movie/A Movie"},
+		{nameTemplatePlex, tvNameTemplatePresets, episode, "Breaking Bad (2008) {tmdb-1396}/Season 01/Breaking Bad (2008) - S01E02 - Cat's in the Bag..."},
+		{nameTemplateJellyfin, tvNameTemplatePresets, episode, "Breaking Bad (2008) [tmdbid-1396]/Season 01/Breaking Bad (2008) - S01E02 - Cat's in the Bag..."},
+		{nameTemplateKodi, tvNameTemplatePresets, episode, "Breaking Bad (2008)/Season 01/Breaking Bad (2008) - S01E02 - Cat's in the Bag..."},
+		{nameTemplateEmby, tvNameTemplatePresets, episode, "Breaking Bad (2008) [tmdbid=1396]/Season 01/Breaking Bad (2008) - S01E02 - Cat's in the Bag..."},
+		{nameTemplateKodi, tvNameTemplatePresets, &nameData{Name: "Show", Season: 12, Episode: 3}, "Show/Season 12/Show - S12E03"},
+	} {
+		tmpl, err := newNameTemplate(tc.preset, tc.presets)
+		require.NoError(t, err)
+
+		name, err := tmpl.render(tc.data)
+		require.NoError(t, err, "%s %+v", tc.preset, tc.data)
+		assert.Equal(t, filepath.FromSlash(tc.expected)+".mkv", name)
+	}
+}
+
+func TestNameTemplateRender(t *testing.T) {
+	for _, tc := range []struct {
+		name, text string
+		data       *nameData
+		expected   string
+	}{
+		{name: "custom", text: "{{.Name}} ({{.Resolution}} {{.AudioCodec}})", data: &nameData{Name: "A Movie", Resolution: "1080p", AudioCodec: "TrueHD"}, expected: "A Movie (1080p TrueHD)"},
+		{name: "cleaned", text: "movies//{{.Name}}/./{{.Name}}", data: &nameData{Name: "A Movie"}, expected: "movies/A Movie/A Movie"},
+		{name: "sanitized", text: "{{.Name}}: {{.Year}}?", data: &nameData{Name: "A Movie", Year: 2000}, expected: "A Movie- 2000"},
+		{name: "empty", text: "{{.Name}}", data: &nameData{}},
+		{name: "dot", text: "{{.Name}}", data: &nameData{Name: "."}},
+		{name: "dot dot", text: "{{.Name}}", data: &nameData{Name: ".."}},
+		{name: "slash in value", text: "{{.Name}}", data: &nameData{Name: "movies/A Movie"}},
+		{name: "backslash in value", text: "{{.Name}}", data: &nameData{Name: `movies\A Movie`}},
+		{name: "separator in episode name", text: "{{.Name}} - {{.EpisodeName}}", data: &nameData{Name: "Show", EpisodeName: "../Pilot"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := newNameTemplate(tc.text, nameTemplatePresets)
+			require.NoError(t, err)
+
+			name, err := tmpl.render(tc.data)
+			if tc.expected == "" {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, filepath.FromSlash(tc.expected)+".mkv", name)
+		})
+	}
+}
+
+func TestNewNameTemplateErrors(t *testing.T) {
+	// The templates are rendered once to catch errors early, including
+	// invalid paths.
+	for _, text := range []string{
+		"{{.Name",
+		"{{.Title}}",
+		"{{.Name}}/ /{{.Name}}",
+		"../{{.Name}}",
+		"/movies/{{.Name}}",
+	} {
+		_, err := newNameTemplate(text, nameTemplatePresets)
+		assert.Error(t, err, text)
+	}
+}
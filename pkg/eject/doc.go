@@ -1,12 +1,19 @@
 /*
 Package eject provides a cross-platform Eject function that attempts to eject a
-disc given the volume name.
+disc given the volume name, and functions to close, lock and get the status of
+a drive's tray where the platform supports them.
 
-The implementation currently relies on platform-specific command execution:
-`eject` on Linux, `drutil` on Darwin, and `powershell` on Windows.
+On Linux, the implementation uses the CD-ROM ioctls of the kernel. Elsewhere,
+it relies on platform-specific command execution: `drutil` on Darwin and
+`powershell` on Windows.
 
 For example:
 
 	err := Eject(context.Background(), "D:")
+
+On Linux, the package also reports the status of a drive's tray and media,
+which lets callers wait for a disc without scanning the drive:
+
+	err := WaitForMedia(ctx, "/dev/sr0")
 */
 package eject
@@ -15,3 +15,18 @@ func Eject(ctx context.Context, _ string) error {
 		"eject",
 	).Run()
 }
+
+// CloseTray attempts to close the tray.
+func CloseTray(ctx context.Context, _ string) error {
+	return exec.CommandContext(
+		ctx,
+		"drutil",
+		"tray",
+		"close",
+	).Run()
+}
+
+// SetLocked returns ErrNotSupported.
+func SetLocked(context.Context, string, bool) error {
+	return ErrNotSupported
+}
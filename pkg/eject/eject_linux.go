@@ -4,14 +4,61 @@ package eject
 
 import (
 	"context"
-	"os/exec"
+	"fmt"
+	"log/slog"
+
+	"golang.org/x/sys/unix"
+)
+
+// Linux CD-ROM ioctl requests from linux/cdrom.h.
+const (
+	cdromEject     = 0x5309
+	cdromCloseTray = 0x5319
+	cdromLockDoor  = 0x5329
 )
 
-// Eject attempts to eject the disc identified by volumeName.
+// Eject attempts to eject the disc identified by volumeName, e.g., "/dev/sr0".
+// The tray is unlocked first. Some drives cannot lock their trays, so the disc
+// is ejected even if unlocking fails.
 func Eject(ctx context.Context, volumeName string) error {
-	return exec.CommandContext(
-		ctx,
-		"eject",
-		volumeName,
-	).Run()
+	if err := SetLocked(ctx, volumeName, false); err != nil {
+		slog.Debug("failed to unlock tray", "volume", volumeName, "err", err)
+	}
+
+	return driveIoctl(ctx, volumeName, "eject", cdromEject, 0)
+}
+
+// CloseTray closes the tray of the drive identified by volumeName.
+func CloseTray(ctx context.Context, volumeName string) error {
+	return driveIoctl(ctx, volumeName, "close tray of", cdromCloseTray, 0)
+}
+
+// SetLocked locks or unlocks the tray of the drive identified by volumeName.
+// A locked tray stays locked until it is unlocked, even after mkvbot exits.
+func SetLocked(ctx context.Context, volumeName string, locked bool) error {
+	if locked {
+		return driveIoctl(ctx, volumeName, "lock", cdromLockDoor, 1)
+	}
+
+	return driveIoctl(ctx, volumeName, "unlock", cdromLockDoor, 0)
+}
+
+// driveIoctl opens the drive and issues the request. verb describes the
+// request in errors.
+func driveIoctl(ctx context.Context, volumeName, verb string, req, arg uintptr) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fd, err := openDevice(volumeName)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	if _, err := ioctl(fd, req, arg); err != nil {
+		return fmt.Errorf("%s %q: %w", verb, volumeName, err)
+	}
+
+	return nil
 }
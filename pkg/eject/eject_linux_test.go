@@ -0,0 +1,34 @@
+//go:build linux
+
+package eject_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/eject"
+)
+
+func TestDriveStatusNotADrive(t *testing.T) {
+	status, err := eject.DriveStatus(os.DevNull)
+	require.ErrorIs(t, err, eject.ErrNotSupported)
+	assert.Equal(t, eject.StatusUnknown, status)
+
+	require.ErrorIs(t, eject.WaitForMedia(context.Background(), os.DevNull), eject.ErrNotSupported)
+}
+
+func TestDriveStatusMissingDevice(t *testing.T) {
+	_, err := eject.DriveStatus("/dev/does-not-exist")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestTrayNotADrive(t *testing.T) {
+	ctx := context.Background()
+	require.ErrorIs(t, eject.CloseTray(ctx, os.DevNull), eject.ErrNotSupported)
+	require.ErrorIs(t, eject.SetLocked(ctx, os.DevNull, true), eject.ErrNotSupported)
+	require.ErrorIs(t, eject.Eject(ctx, os.DevNull), eject.ErrNotSupported)
+}
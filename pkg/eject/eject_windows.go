@@ -17,3 +17,13 @@ func Eject(ctx context.Context, volumeName string) error {
 		fmt.Sprintf("(new-object -COM Shell.Application).NameSpace(17).ParseName('%s').InvokeVerb('Eject')", volumeName),
 	).Run()
 }
+
+// CloseTray returns ErrNotSupported.
+func CloseTray(context.Context, string) error {
+	return ErrNotSupported
+}
+
+// SetLocked returns ErrNotSupported.
+func SetLocked(context.Context, string, bool) error {
+	return ErrNotSupported
+}
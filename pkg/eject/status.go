@@ -0,0 +1,66 @@
+package eject
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Status is the state of a drive's tray and media.
+type Status int
+
+const (
+	StatusUnknown Status = iota
+	StatusNoDisc
+	StatusTrayOpen
+	StatusNotReady
+	StatusDiscOK
+)
+
+// PollInterval is how often WaitForMedia checks the drive status.
+var PollInterval = time.Second
+
+// ErrNotSupported is returned on platforms, and by drives, that do not support
+// an operation, e.g., reporting their status.
+var ErrNotSupported = errors.New("not supported")
+
+func (s Status) String() string {
+	switch s {
+	case StatusNoDisc:
+		return "no disc"
+	case StatusTrayOpen:
+		return "tray open"
+	case StatusNotReady:
+		return "not ready"
+	case StatusDiscOK:
+		return "disc ok"
+	default:
+		return "unknown"
+	}
+}
+
+// WaitForMedia blocks until the drive identified by device has a disc that is
+// ready to be read or ctx is done. It returns ErrNotSupported if the drive
+// status cannot be determined, in which case the caller should fall back to
+// polling the drive some other way.
+func WaitForMedia(ctx context.Context, device string) error {
+	for {
+		status, err := DriveStatus(device)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case StatusDiscOK:
+			return nil
+		case StatusUnknown:
+			return ErrNotSupported
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(PollInterval):
+		}
+	}
+}
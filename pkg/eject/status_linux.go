@@ -0,0 +1,64 @@
+//go:build linux
+
+package eject
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// Linux CD-ROM ioctl requests and arguments from linux/cdrom.h.
+const (
+	cdromDriveStatus = 0x5326
+	cdslCurrent      = 0x7fffffff
+)
+
+// DriveStatus returns the status of the drive identified by device, e.g.,
+// "/dev/sr0".
+func DriveStatus(device string) (Status, error) {
+	fd, err := openDevice(device)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	defer unix.Close(fd)
+
+	status, err := ioctl(fd, cdromDriveStatus, cdslCurrent)
+	if err != nil {
+		return StatusUnknown, fmt.Errorf("get status of %q: %w", device, err)
+	}
+
+	// The CDS_* values match Status.
+	if s := Status(status); s >= StatusUnknown && s <= StatusDiscOK {
+		return s, nil
+	}
+
+	return StatusUnknown, nil
+}
+
+// openDevice opens the device without waiting for a disc, which also works
+// while the tray is open.
+func openDevice(device string) (int, error) {
+	fd, err := unix.Open(device, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("open %q: %w", device, err)
+	}
+
+	return fd, nil
+}
+
+// ioctl issues the request with an integer argument and returns the result.
+// Errors that mean the device or drive does not support the request wrap
+// ErrNotSupported.
+func ioctl(fd int, req, arg uintptr) (int, error) {
+	r, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, arg)
+	switch errno {
+	case 0:
+	case unix.ENOTTY, unix.EINVAL, unix.ENOSYS:
+		return -1, fmt.Errorf("%w: %w", ErrNotSupported, errno)
+	default:
+		return -1, errno
+	}
+
+	return int(r), nil
+}
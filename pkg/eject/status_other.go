@@ -0,0 +1,8 @@
+//go:build !linux
+
+package eject
+
+// DriveStatus returns ErrNotSupported.
+func DriveStatus(string) (Status, error) {
+	return StatusUnknown, ErrNotSupported
+}
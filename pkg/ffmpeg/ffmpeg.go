@@ -0,0 +1,111 @@
+// Package ffmpeg transcodes video files with ffmpeg and reports the progress.
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config configures FFmpeg.
+type Config struct {
+	// ExePath is the path of the ffmpeg executable. If empty, FindExe is used.
+	ExePath string
+}
+
+// FFmpeg runs ffmpeg.
+type FFmpeg struct {
+	exePath string
+}
+
+// New returns an FFmpeg that runs the executable given by cfg.
+func New(cfg *Config) (*FFmpeg, error) {
+	exePath := cfg.ExePath
+	if exePath == "" {
+		var err error
+		if exePath, err = FindExe(); err != nil {
+			return nil, fmt.Errorf("find ffmpeg: %w", err)
+		}
+	}
+
+	if _, err := os.Stat(exePath); err != nil {
+		return nil, fmt.Errorf("stat %q: %w", exePath, err)
+	}
+
+	return &FFmpeg{
+		exePath: exePath,
+	}, nil
+}
+
+// Transcode transcodes the file at srcPath to dstPath, which is overwritten,
+// according to the preset. duration is the duration of the input, which is
+// used to report the progress, from 0 to 1, to the progress function. If
+// Transcode fails, dstPath is removed.
+func (f *FFmpeg) Transcode(ctx context.Context, srcPath, dstPath string, preset *Preset, duration time.Duration, progress func(float64)) error {
+	args := slices.Concat(
+		[]string{"-hide_banner", "-nostdin", "-nostats", "-loglevel", "error", "-y", "-i", srcPath},
+		preset.Args,
+		[]string{"-progress", "pipe:1", dstPath},
+	)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, f.exePath, args...)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("get stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start %q: %w", f.exePath, err)
+	}
+
+	parseProgress(stdout, duration, progress)
+
+	if err := cmd.Wait(); err != nil {
+		_ = os.Remove(dstPath)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("transcode %q: %w: %s", srcPath, err, msg)
+		}
+		return fmt.Errorf("transcode %q: %w", srcPath, err)
+	}
+
+	return nil
+}
+
+// parseProgress reads the key=value lines written by ffmpeg -progress and
+// reports the output time as a fraction of duration.
+func parseProgress(r io.Reader, duration time.Duration, progress func(float64)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "out_time_us":
+			us, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || duration <= 0 {
+				continue
+			}
+
+			progress(min(max(float64(time.Duration(us)*time.Microsecond)/float64(duration), 0), 1))
+		case "progress":
+			if value == "end" {
+				progress(1)
+			}
+		}
+	}
+
+	// Drain the pipe so that ffmpeg does not block if scanning failed.
+	_, _ = io.Copy(io.Discard, r)
+}
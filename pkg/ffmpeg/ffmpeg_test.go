@@ -0,0 +1,93 @@
+package ffmpeg_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/ffmpeg"
+)
+
+// fakeFFmpeg is a shell script that writes progress like ffmpeg -progress and
+// copies the input (the argument after -i) to the output (the last argument).
+// It fails if the input contains "fail".
+const fakeFFmpeg = `#!/bin/sh
+while [ "$1" != "-i" ]; do shift; done
+in=$2
+for out; do :; done
+if grep -q fail "$in"; then echo "Invalid data found when processing input" >&2; exit 1; fi
+printf 'frame=1\nout_time_us=30000000\nprogress=continue\n'
+printf 'out_time_us=N/A\nout_time_us=60000000\nprogress=continue\n'
+cp "$in" "$out"
+printf 'progress=end\n'
+`
+
+func newFakeFFmpeg(t *testing.T) *ffmpeg.FFmpeg {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	exePath := filepath.Join(t.TempDir(), "ffmpeg")
+	require.NoError(t, os.WriteFile(exePath, []byte(fakeFFmpeg), 0700))
+
+	f, err := ffmpeg.New(&ffmpeg.Config{ExePath: exePath})
+	require.NoError(t, err)
+
+	return f
+}
+
+func TestTranscode(t *testing.T) {
+	f := newFakeFFmpeg(t)
+	preset, err := ffmpeg.GetPreset("h265")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "in.mkv"), filepath.Join(dir, "out.mkv")
+	require.NoError(t, os.WriteFile(src, []byte("video"), 0600))
+
+	var progress []float64
+	err = f.Transcode(context.Background(), src, dst, preset, 2*time.Minute, func(p float64) {
+		progress = append(progress, p)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.25, 0.5, 1}, progress)
+	assert.FileExists(t, dst)
+}
+
+func TestTranscodeFailure(t *testing.T) {
+	f := newFakeFFmpeg(t)
+
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "in.mkv"), filepath.Join(dir, "out.mkv")
+	require.NoError(t, os.WriteFile(src, []byte("fail"), 0600))
+	require.NoError(t, os.WriteFile(dst, []byte("partial"), 0600))
+
+	err := f.Transcode(context.Background(), src, dst, ffmpeg.Presets[0], time.Minute, func(float64) {})
+	require.ErrorContains(t, err, "Invalid data found when processing input")
+	assert.NoFileExists(t, dst)
+}
+
+func TestGetPreset(t *testing.T) {
+	for _, name := range ffmpeg.PresetNames() {
+		p, err := ffmpeg.GetPreset(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, p.Name)
+		assert.NotEmpty(t, p.Args)
+	}
+
+	_, err := ffmpeg.GetPreset("vp9")
+	require.ErrorContains(t, err, `invalid preset "vp9"`)
+}
+
+func TestNewMissingExe(t *testing.T) {
+	_, err := ffmpeg.New(&ffmpeg.Config{ExePath: filepath.Join(t.TempDir(), "ffmpeg")})
+	require.Error(t, err)
+}
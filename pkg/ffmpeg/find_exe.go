@@ -0,0 +1,13 @@
+//go:build linux || darwin
+
+package ffmpeg
+
+import (
+	"os/exec"
+)
+
+// FindExe attempts to return the path of the ffmpeg executable on Linux and
+// Darwin operating systems.
+func FindExe() (string, error) {
+	return exec.LookPath("ffmpeg")
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// FindExe attempts to return the path of the ffmpeg executable on Windows
+// operating systems.
+func FindExe() (string, error) {
+	exe := "ffmpeg.exe"
+	if path, err := exec.LookPath(exe); err == nil {
+		return path, nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("get working directory: %w", err)
+	}
+
+	volume := filepath.VolumeName(wd)
+	return filepath.Join(volume+"\\", "Program Files", "ffmpeg", "bin", exe), nil
+}
@@ -0,0 +1,57 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Preset is a named set of ffmpeg output options.
+type Preset struct {
+	Name        string
+	Description string
+
+	// Args are the ffmpeg arguments between the input and the output file.
+	Args []string
+}
+
+// Presets are the built-in presets. They keep every stream and re-encode only
+// the video. Audio and subtitles are copied.
+var Presets = []*Preset{
+	{
+		Name:        "h264",
+		Description: "H.264 (x264, CRF 20), widely compatible",
+		Args:        []string{"-map", "0", "-c", "copy", "-c:v", "libx264", "-preset", "slow", "-crf", "20"},
+	},
+	{
+		Name:        "h265",
+		Description: "H.265/HEVC (x265, CRF 22), about half the size of H.264",
+		Args:        []string{"-map", "0", "-c", "copy", "-c:v", "libx265", "-preset", "medium", "-crf", "22"},
+	},
+	{
+		Name:        "av1",
+		Description: "AV1 (SVT-AV1, CRF 30), smallest but least compatible",
+		Args:        []string{"-map", "0", "-c", "copy", "-c:v", "libsvtav1", "-preset", "6", "-crf", "30"},
+	},
+}
+
+// PresetNames returns the names of the built-in presets.
+func PresetNames() []string {
+	names := make([]string, len(Presets))
+	for i, p := range Presets {
+		names[i] = p.Name
+	}
+
+	return names
+}
+
+// GetPreset returns the built-in preset with the given name.
+func GetPreset(name string) (*Preset, error) {
+	i := slices.IndexFunc(Presets, func(p *Preset) bool {
+		return p.Name == name
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("invalid preset %q (expected one of %q)", name, PresetNames())
+	}
+
+	return Presets[i], nil
+}
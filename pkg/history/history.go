@@ -0,0 +1,401 @@
+// Package history provides a persistent record of rip attempts.
+//
+// Records are stored in a JSON Lines file, one record per line, so that the
+// file can be appended to safely and inspected with ordinary tools.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome is the outcome of a rip attempt.
+type Outcome string
+
+const (
+	// OutcomeSuccess means the title was backed up.
+	OutcomeSuccess Outcome = "success"
+
+	// OutcomeFailure means the attempt failed. See Record.Error.
+	OutcomeFailure Outcome = "failure"
+
+	// OutcomeCanceled means the attempt was interrupted, e.g., by Ctrl+C.
+	OutcomeCanceled Outcome = "canceled"
+
+	// OutcomeSkipped means the disc was not ripped because it had been ripped
+	// before.
+	OutcomeSkipped Outcome = "skipped"
+)
+
+// Record describes a rip attempt.
+type Record struct {
+	// StartTime is when the disc was scanned.
+	StartTime time.Time `json:"startTime"`
+
+	// EndTime is when the attempt finished.
+	EndTime time.Time `json:"endTime"`
+
+	// Drive is the name of the drive or the path of the ISO image or disc
+	// folder.
+	Drive string `json:"drive"`
+
+	// DiscName is the name of the disc reported by makemkv.
+	DiscName string `json:"discName"`
+
+	// Fingerprint identifies the disc. See makemkv.Disc.Fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// TitleIndex is the index of the chosen title, or -1 if no title was
+	// chosen.
+	TitleIndex int `json:"titleIndex"`
+
+	// DiscBackup is true if the whole disc structure was backed up rather than
+	// a title. OutputPath is then a directory.
+	DiscBackup bool `json:"discBackup,omitempty"`
+
+	// Scores are the best title heuristic scores indexed by title index.
+	Scores []int64 `json:"scores,omitempty"`
+
+	// Name, Year and MovieID are the movie metadata.
+	Name    string `json:"name,omitempty"`
+	Year    int    `json:"year,omitempty"`
+	MovieID string `json:"movieId,omitempty"`
+
+	// Edition distinguishes multiple rips of the same movie.
+	Edition string `json:"edition,omitempty"`
+
+	// Season and Episode identify the episode if the title is an episode of
+	// the TV series identified by Name and MovieID.
+	Season  int `json:"season,omitempty"`
+	Episode int `json:"episode,omitempty"`
+
+	// OutputPath is the path of the output file.
+	OutputPath string `json:"outputPath,omitempty"`
+
+	// ExtraPaths are the paths of the extras that were backed up along with
+	// the title.
+	ExtraPaths []string `json:"extraPaths,omitempty"`
+
+	// Hooks are the results of the post-rip hooks, which are added when the
+	// hooks have run.
+	Hooks []*HookResult `json:"hooks,omitempty"`
+
+	// Outcome is the outcome of the attempt.
+	Outcome Outcome `json:"outcome"`
+
+	// Error describes the failure if Outcome is OutcomeFailure.
+	Error string `json:"error,omitempty"`
+}
+
+// HookResult is the result of a post-rip hook.
+type HookResult struct {
+	// Command is the hook command.
+	Command string `json:"command"`
+
+	// Attempts is the number of times the command was run.
+	Attempts int `json:"attempts"`
+
+	// ExitCode is the exit code of the last attempt, or -1 if the command
+	// did not exit normally, e.g., because it timed out.
+	ExitCode int `json:"exitCode"`
+
+	// Error describes the failure of the last attempt, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Matches returns true if any of the descriptive fields of the record contain
+// q, ignoring case.
+func (r *Record) Matches(q string) bool {
+	q = strings.ToLower(q)
+	for _, s := range []string{r.Drive, r.DiscName, r.Name, r.MovieID, r.OutputPath, string(r.Outcome), r.Error} {
+		if strings.Contains(strings.ToLower(s), q) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	// ErrReadOnly is returned when changing a read-only Store.
+	ErrReadOnly = errors.New("history is read-only")
+
+	// ErrNotFound is returned by Store.Update if no record matches.
+	ErrNotFound = errors.New("record not found")
+)
+
+// Store is a history file.
+type Store struct {
+	path     string
+	readOnly bool
+
+	mu sync.Mutex
+}
+
+// Open returns the Store for the history file at path. The file (and its
+// directory) is created if necessary.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return nil, fmt.Errorf("make directory for %q: %w", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		path: path,
+	}, nil
+}
+
+// OpenReadOnly returns a read-only Store for the history file at path, e.g.,
+// to list the records. The file is not created; a missing file is an empty
+// history.
+func OpenReadOnly(path string) *Store {
+	return &Store{
+		path:     path,
+		readOnly: true,
+	}
+}
+
+// Path returns the path of the history file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Add appends the record to the history file.
+func (s *Store) Add(r *Record) (err error) {
+	if s.readOnly {
+		return fmt.Errorf("add to %q: %w", s.path, ErrReadOnly)
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", s.path, err)
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write %q: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// Records returns all of the records in the order they were added.
+func (s *Store) Records() ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read()
+}
+
+// Update calls update with each record that match returns true for and
+// replaces the history file with the updated records. It returns ErrNotFound
+// if no record matches.
+func (s *Store) Update(match func(r *Record) bool, update func(r *Record)) error {
+	if s.readOnly {
+		return fmt.Errorf("update %q: %w", s.path, ErrReadOnly)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, r := range records {
+		if match(r) {
+			update(r)
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	var buf bytes.Buffer
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(b, '\n'))
+	}
+
+	// The file is replaced at once, so that it is never left half-written.
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return errors.Join(fmt.Errorf("write %q: %w", tmpPath, err), os.Remove(tmpPath))
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return errors.Join(fmt.Errorf("rename %q to %q: %w", tmpPath, s.path, err), os.Remove(tmpPath))
+	}
+
+	return nil
+}
+
+// read returns all of the records. The caller must hold s.mu.
+func (s *Store) read() ([]*Record, error) {
+	f, err := os.Open(s.path)
+	if s.readOnly && errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("open %q: %w", s.path, err)
+	}
+	defer f.Close()
+
+	var records []*Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		r := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			return nil, fmt.Errorf("parse %s:%d: %w", s.path, n, err)
+		}
+		records = append(records, r)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %q: %w", s.path, err)
+	}
+
+	return records, nil
+}
+
+// FindRipped returns the successful records of discs with the given
+// fingerprint in the order they were added.
+func (s *Store) FindRipped(fingerprint string) ([]*Record, error) {
+	records, err := s.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Record
+	for _, r := range records {
+		if r.Outcome == OutcomeSuccess && r.Fingerprint == fingerprint {
+			matches = append(matches, r)
+		}
+	}
+
+	return matches, nil
+}
+
+// LastEpisode returns the highest episode number of the given season of a TV
+// series that was backed up successfully, or 0 if there is none. The series is
+// identified by movieID if it is non-empty and by name otherwise.
+func (s *Store) LastEpisode(movieID, name string, season int) (int, error) {
+	records, err := s.Records()
+	if err != nil {
+		return 0, err
+	}
+
+	last := 0
+	for _, r := range records {
+		if r.Outcome != OutcomeSuccess || r.Season != season || r.Episode <= last {
+			continue
+		}
+
+		if (movieID != "" && r.MovieID == movieID) || (movieID == "" && r.MovieID == "" && r.Name == name) {
+			last = r.Episode
+		}
+	}
+
+	return last, nil
+}
+
+// WriteJSON writes the records to w as a JSON array.
+func WriteJSON(w io.Writer, records []*Record) error {
+	if records == nil {
+		records = []*Record{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// CSVHeader is the header row written by WriteCSV.
+var CSVHeader = []string{
+	"start_time",
+	"end_time",
+	"drive",
+	"disc_name",
+	"title_index",
+	"name",
+	"year",
+	"movie_id",
+	"edition",
+	"season",
+	"episode",
+	"output_path",
+	"outcome",
+	"error",
+}
+
+// WriteCSV writes the records to w as CSV with a header row. Scores are
+// omitted.
+func WriteCSV(w io.Writer, records []*Record) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if err := cw.Write([]string{
+			r.StartTime.Format(time.RFC3339),
+			r.EndTime.Format(time.RFC3339),
+			r.Drive,
+			r.DiscName,
+			strconv.Itoa(r.TitleIndex),
+			r.Name,
+			strconv.Itoa(r.Year),
+			r.MovieID,
+			r.Edition,
+			strconv.Itoa(r.Season),
+			strconv.Itoa(r.Episode),
+			r.OutputPath,
+			string(r.Outcome),
+			r.Error,
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
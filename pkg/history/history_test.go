@@ -0,0 +1,186 @@
+package history_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a", "history.jsonl")
+	store, err := history.Open(path)
+	require.NoError(t, err)
+
+	records, err := store.Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	added := []*history.Record{
+		{
+			StartTime:  start,
+			EndTime:    start.Add(time.Hour),
+			Drive:      "BD-RE Drive",
+			DiscName:   "A Movie",
+			TitleIndex: 1,
+			Scores:     []int64{1000, 1500},
+			Name:       "A Movie",
+			Year:       1999,
+			MovieID:    "imdb-tt0000001",
+			OutputPath: "/movies/A Movie (1999) {imdb-tt0000001}/A Movie (1999) {imdb-tt0000001}.mkv",
+			Outcome:    history.OutcomeSuccess,
+		},
+		{
+			StartTime:  start.Add(2 * time.Hour),
+			EndTime:    start.Add(2 * time.Hour),
+			Drive:      "BD-RE Drive",
+			DiscName:   "Another Movie",
+			TitleIndex: -1,
+			Outcome:    history.OutcomeFailure,
+			Error:      "scratched, with a comma",
+		},
+		{
+			StartTime:   start.Add(3 * time.Hour),
+			EndTime:     start.Add(4 * time.Hour),
+			Drive:       "BD-RE Drive",
+			DiscName:    "A Movie",
+			Fingerprint: "abc",
+			TitleIndex:  0,
+			Name:        "A Movie",
+			Year:        1999,
+			Edition:     "Edition 2",
+			Outcome:     history.OutcomeSuccess,
+		},
+	}
+	for _, r := range added {
+		require.NoError(t, store.Add(r))
+	}
+
+	// Re-open to make sure the records are persistent.
+	store, err = history.Open(path)
+	require.NoError(t, err)
+	records, err = store.Records()
+	require.NoError(t, err)
+	assert.Equal(t, added, records)
+
+	assert.True(t, records[0].Matches("a movie"))
+	assert.False(t, records[0].Matches("another"))
+	assert.True(t, records[1].Matches("SCRATCHED"))
+
+	var buf bytes.Buffer
+	require.NoError(t, history.WriteCSV(&buf, records))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, strings.Join(history.CSVHeader, ","), lines[0])
+	assert.Equal(t, `2025-01-02T05:04:05Z,2025-01-02T05:04:05Z,BD-RE Drive,Another Movie,-1,,0,,,0,0,,failure,"scratched, with a comma"`, lines[2])
+
+	ripped, err := store.FindRipped("abc")
+	require.NoError(t, err)
+	assert.Equal(t, added[2:], ripped)
+
+	ripped, err = store.FindRipped("")
+	require.NoError(t, err)
+	assert.Equal(t, added[:1], ripped)
+
+	buf.Reset()
+	require.NoError(t, history.WriteJSON(&buf, records))
+	var decoded []*history.Record
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, added, decoded)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	// A missing file is an empty history and is not created.
+	store := history.OpenReadOnly(path)
+	records, err := store.Records()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	assert.NoFileExists(t, path)
+
+	require.ErrorIs(t, store.Add(&history.Record{Outcome: history.OutcomeSuccess}), history.ErrReadOnly)
+	assert.NoFileExists(t, path)
+
+	writable, err := history.Open(path)
+	require.NoError(t, err)
+	require.NoError(t, writable.Add(&history.Record{DiscName: "A Movie", Outcome: history.OutcomeSuccess}))
+
+	records, err = store.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "A Movie", records[0].DiscName)
+}
+
+func TestLastEpisode(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	require.NoError(t, err)
+
+	for _, r := range []*history.Record{
+		{Name: "A Show", MovieID: "tmdb-1", Season: 1, Episode: 1, Outcome: history.OutcomeSuccess},
+		{Name: "A Show", MovieID: "tmdb-1", Season: 1, Episode: 3, Outcome: history.OutcomeSuccess},
+		{Name: "A Show", MovieID: "tmdb-1", Season: 1, Episode: 4, Outcome: history.OutcomeFailure},
+		{Name: "A Show", MovieID: "tmdb-1", Season: 2, Episode: 7, Outcome: history.OutcomeSuccess},
+		{Name: "A Show", Season: 1, Episode: 2, Outcome: history.OutcomeSuccess},
+	} {
+		require.NoError(t, store.Add(r))
+	}
+
+	for _, tc := range []struct {
+		movieID, name string
+		season        int
+		expected      int
+	}{
+		{"tmdb-1", "A Show", 1, 3},
+		{"tmdb-1", "A Show", 2, 7},
+		{"tmdb-1", "A Show", 3, 0},
+		{"", "A Show", 1, 2},
+		{"tmdb-2", "A Show", 1, 0},
+	} {
+		last, err := store.LastEpisode(tc.movieID, tc.name, tc.season)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, last, "%+v", tc)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := history.Open(path)
+	require.NoError(t, err)
+
+	for _, name := range []string{"A Movie", "Another Movie"} {
+		require.NoError(t, store.Add(&history.Record{Name: name, Outcome: history.OutcomeSuccess}))
+	}
+
+	hook := &history.HookResult{Command: "true", Attempts: 1}
+	require.NoError(t, store.Update(func(r *history.Record) bool {
+		return r.Name == "Another Movie"
+	}, func(r *history.Record) {
+		r.Hooks = append(r.Hooks, hook)
+	}))
+
+	records, err := store.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Empty(t, records[0].Hooks)
+	assert.Equal(t, []*history.HookResult{hook}, records[1].Hooks)
+	assert.NoFileExists(t, path+".tmp")
+
+	// Records are still appended after an update.
+	require.NoError(t, store.Add(&history.Record{Name: "A Third Movie", Outcome: history.OutcomeSuccess}))
+	records, err = store.Records()
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+
+	err = store.Update(func(*history.Record) bool { return false }, func(*history.Record) {})
+	require.ErrorIs(t, err, history.ErrNotFound)
+	require.ErrorIs(t, history.OpenReadOnly(path).Update(func(*history.Record) bool { return true }, func(*history.Record) {}), history.ErrReadOnly)
+}
@@ -0,0 +1,119 @@
+// Package hook runs user-supplied commands, e.g., after a title is ripped.
+//
+// A hook is a shell command. It receives information about the event through
+// environment variables and a JSON document on standard input. A hook that
+// fails or times out can be retried.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MaxOutputSize is the number of bytes of output kept from a hook.
+const MaxOutputSize = 4096
+
+// Config configures a hook.
+type Config struct {
+	// Command is a shell command. It is run by sh -c (cmd /C on Windows).
+	Command string
+
+	// Timeout limits each attempt. Zero means no limit.
+	Timeout time.Duration
+
+	// Retries is the number of times a failed command is run again.
+	Retries int
+
+	// RetryDelay is the delay before each retry.
+	RetryDelay time.Duration
+}
+
+// Result is the result of running a hook.
+type Result struct {
+	// Attempts is the number of times the command was run.
+	Attempts int
+
+	// Output is the end of the combined standard output and standard error of
+	// the last attempt, at most MaxOutputSize bytes.
+	Output string
+
+	// ExitCode is the exit code of the last attempt, or -1 if the command did
+	// not start or was killed, e.g., because it timed out.
+	ExitCode int
+
+	// Err is the error of the last attempt, if it failed.
+	Err error
+}
+
+// Run runs the hook until it succeeds or it has been retried cfg.Retries times.
+// env is added to the environment of the current process and input is the
+// standard input of the command.
+func Run(ctx context.Context, cfg *Config, env []string, input []byte) *Result {
+	res := &Result{}
+	for attempt := range cfg.Retries + 1 {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return res
+			case <-time.After(cfg.RetryDelay):
+			}
+		}
+
+		res.Attempts++
+		res.Output, res.ExitCode, res.Err = runOnce(ctx, cfg, env, input)
+		if res.Err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return res
+}
+
+func runOnce(ctx context.Context, cfg *Config, env []string, input []byte) (string, int, error) {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	var out tailBuffer
+	cmd := shellCommand(ctx, cfg.Command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout, cmd.Stderr = &out, &out
+
+	// Processes started by the command may keep its output open after it is
+	// killed.
+	cmd.WaitDelay = 5 * time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", cfg.Timeout, err)
+	}
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	return string(out.b), exitCode, err
+}
+
+// tailBuffer is an io.Writer that keeps the last MaxOutputSize bytes written
+// to it.
+type tailBuffer struct {
+	b []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.b = append(t.b, p...)
+	if len(t.b) > MaxOutputSize {
+		t.b = t.b[len(t.b)-MaxOutputSize:]
+	}
+
+	return len(p), nil
+}
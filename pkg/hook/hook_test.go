@@ -0,0 +1,81 @@
+package hook_test
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/hook"
+)
+
+func skipOnWindows(t *testing.T) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the tests use sh")
+	}
+}
+
+func TestRun(t *testing.T) {
+	skipOnWindows(t)
+
+	res := hook.Run(context.Background(), &hook.Config{
+		Command: `echo "$MKVBOT_TEST"; cat; echo oops >&2`,
+	}, []string{"MKVBOT_TEST=hello"}, []byte(`{"a":1}`))
+	require.NoError(t, res.Err)
+	assert.Equal(t, 1, res.Attempts)
+	assert.Equal(t, 0, res.ExitCode)
+	assert.Equal(t, "hello\n{\"a\":1}oops\n", res.Output)
+}
+
+func TestRunRetries(t *testing.T) {
+	skipOnWindows(t)
+
+	// The command succeeds on the third attempt.
+	counter := filepath.Join(t.TempDir(), "counter")
+	res := hook.Run(context.Background(), &hook.Config{
+		Command:    `echo x >> "$COUNTER"; test "$(wc -l < "$COUNTER")" -ge 3`,
+		Retries:    5,
+		RetryDelay: time.Millisecond,
+	}, []string{"COUNTER=" + counter}, nil)
+	require.NoError(t, res.Err)
+	assert.Equal(t, 3, res.Attempts)
+
+	res = hook.Run(context.Background(), &hook.Config{
+		Command: "exit 3",
+		Retries: 1,
+	}, nil, nil)
+	require.ErrorContains(t, res.Err, "exit status 3")
+	assert.Equal(t, 2, res.Attempts)
+	assert.Equal(t, 3, res.ExitCode)
+}
+
+func TestRunTimeout(t *testing.T) {
+	skipOnWindows(t)
+
+	start := time.Now()
+	res := hook.Run(context.Background(), &hook.Config{
+		Command: "sleep 10",
+		Timeout: 100 * time.Millisecond,
+	}, nil, nil)
+	require.ErrorContains(t, res.Err, "timed out after 100ms")
+	assert.Equal(t, -1, res.ExitCode)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRunOutputLimit(t *testing.T) {
+	skipOnWindows(t)
+
+	res := hook.Run(context.Background(), &hook.Config{
+		Command: "yes | head -n 5000; echo end",
+	}, nil, nil)
+	require.NoError(t, res.Err)
+	assert.Len(t, res.Output, hook.MaxOutputSize)
+	assert.True(t, strings.HasSuffix(res.Output, "y\nend\n"))
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package hook
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand returns a command that runs the shell command.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
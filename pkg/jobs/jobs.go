@@ -0,0 +1,461 @@
+// Package jobs implements a queue of background jobs, e.g., the work that
+// follows the rip of a title, that is run by a pool of workers.
+//
+// The queue is saved to a file whenever it changes, so that the jobs that were
+// queued, interrupted or failed are picked up again after a restart. A job can
+// depend on another job, which lets a sequence of jobs run in order.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// State is the state of a job.
+type State string
+
+const (
+	// StateQueued means that the job waits for a worker or for the job it
+	// depends on.
+	StateQueued State = "queued"
+
+	// StateRunning means that a worker runs the job.
+	StateRunning State = "running"
+
+	// StateSucceeded means that the job is done.
+	StateSucceeded State = "succeeded"
+
+	// StateFailed means that the job failed. It can be retried.
+	StateFailed State = "failed"
+
+	// StateCanceled means that the job was canceled. It can be retried.
+	StateCanceled State = "canceled"
+)
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("job not found")
+
+// Job is a unit of work. Its Kind selects the Handler that runs it.
+type Job struct {
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+
+	// Description describes the job for humans, e.g., the name of a file.
+	Description string `json:"description,omitempty"`
+
+	// Params are the parameters of the job. Their meaning depends on Kind.
+	Params map[string]string `json:"params,omitempty"`
+
+	// DependsOn is the ID of the job that must succeed before this job runs,
+	// or 0.
+	DependsOn int `json:"dependsOn,omitempty"`
+
+	State    State  `json:"state"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Progress is the progress of a running job from 0 to 1, if its handler
+	// reports it. It is not saved.
+	Progress float64 `json:"-"`
+}
+
+// Handler runs a job. It should report its progress, if it can, and return
+// when ctx is done.
+type Handler func(ctx context.Context, job *Job, progress func(float64)) error
+
+// Queue holds the jobs and runs them.
+type Queue struct {
+	path     string
+	handlers map[string]Handler
+	onChange func()
+	wake     chan struct{}
+
+	mu       sync.Mutex
+	jobs     []*Job
+	nextID   int
+	cancels  map[int]context.CancelFunc
+	canceled map[int]bool
+	closed   bool
+}
+
+// Open loads the queue from the file at path, which is created if it does
+// not exist. Jobs that were running when the queue was last saved are queued
+// again. Jobs that succeeded or were canceled are forgotten.
+func Open(path string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return nil, fmt.Errorf("make directory for %q: %w", path, err)
+	}
+
+	var jobs []*Job
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	case len(b) > 0:
+		if err := json.Unmarshal(b, &jobs); err != nil {
+			return nil, fmt.Errorf("decode %q: %w", path, err)
+		}
+	}
+
+	// IDs are not reused, since a job may depend on a job that was forgotten.
+	nextID := 1
+	for _, job := range jobs {
+		nextID = max(nextID, job.ID+1, job.DependsOn+1)
+	}
+
+	jobs = slices.DeleteFunc(jobs, func(job *Job) bool {
+		return job.State == StateSucceeded || job.State == StateCanceled
+	})
+	for _, job := range jobs {
+		if job.State == StateRunning {
+			job.State = StateQueued
+		}
+	}
+
+	q := &Queue{
+		path:     path,
+		handlers: make(map[string]Handler),
+		onChange: func() {},
+		wake:     make(chan struct{}, 1),
+		jobs:     jobs,
+		nextID:   nextID,
+		cancels:  make(map[int]context.CancelFunc),
+		canceled: make(map[int]bool),
+	}
+
+	if err := q.save(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Path returns the path of the queue file.
+func (q *Queue) Path() string {
+	return q.path
+}
+
+// Handle sets the handler of the jobs of the given kind. It must be called
+// before Run.
+func (q *Queue) Handle(kind string, h Handler) {
+	q.handlers[kind] = h
+}
+
+// OnChange sets a function that is called whenever a job is added or changes,
+// including its progress. It must be called before Run.
+func (q *Queue) OnChange(fn func()) {
+	q.onChange = fn
+}
+
+// Add queues a sequence of jobs. Each job depends on the job before it, so
+// they run in order and a job does not run if the one before it fails. Only
+// the Kind, Description and Params of the jobs are used.
+func (q *Queue) Add(jobs ...*Job) error {
+	q.mu.Lock()
+	now := time.Now()
+	var prevID int
+	for _, job := range jobs {
+		q.jobs = append(q.jobs, &Job{
+			ID:          q.nextID,
+			Kind:        job.Kind,
+			Description: job.Description,
+			Params:      job.Params,
+			DependsOn:   prevID,
+			State:       StateQueued,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		prevID = q.nextID
+		q.nextID++
+	}
+	err := q.save()
+	q.mu.Unlock()
+
+	q.changed()
+	q.signal()
+	return err
+}
+
+// Jobs returns a copy of the jobs, oldest first.
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, len(q.jobs))
+	for i, job := range q.jobs {
+		jobs[i] = *job
+	}
+
+	return jobs
+}
+
+// Retry queues a failed or canceled job again, along with the jobs that were
+// canceled with it.
+func (q *Queue) Retry(id int) error {
+	q.mu.Lock()
+	job := q.find(id)
+	switch {
+	case job == nil:
+		q.mu.Unlock()
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	case job.State != StateFailed && job.State != StateCanceled:
+		q.mu.Unlock()
+		return fmt.Errorf("job %d is %s", id, job.State)
+	}
+
+	for _, job := range q.chain(job, StateCanceled) {
+		q.setState(job, StateQueued, "")
+	}
+	err := q.save()
+	q.mu.Unlock()
+
+	q.changed()
+	q.signal()
+	return err
+}
+
+// Cancel cancels a queued or running job and the queued jobs that depend on
+// it.
+func (q *Queue) Cancel(id int) error {
+	q.mu.Lock()
+	job := q.find(id)
+	switch {
+	case job == nil:
+		q.mu.Unlock()
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	case job.State == StateRunning:
+		// The worker sets the state when the handler returns.
+		q.canceled[id] = true
+		q.cancels[id]()
+	case job.State == StateQueued:
+		q.setState(job, StateCanceled, "")
+	default:
+		q.mu.Unlock()
+		return fmt.Errorf("job %d is %s", id, job.State)
+	}
+
+	for _, dependent := range q.chain(job, StateQueued)[1:] {
+		q.setState(dependent, StateCanceled, "")
+	}
+	err := q.save()
+	q.mu.Unlock()
+
+	q.changed()
+	return err
+}
+
+// Close makes Run return once no job can run anymore. Jobs that wait for a
+// failed or canceled job stay queued.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// Run runs the queued jobs with the given number of workers until ctx is done
+// or the queue is closed and no job can run. Jobs that are interrupted because
+// ctx is done are queued again.
+func (q *Queue) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for {
+				job, jobCtx, ok := q.next(ctx)
+				if !ok {
+					// Let the other workers notice too.
+					q.signal()
+					return
+				}
+
+				q.run(ctx, jobCtx, job)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// next waits for a job that can run and marks it as running. It returns the
+// job, which is a copy, and its context.
+func (q *Queue) next(ctx context.Context) (*Job, context.Context, bool) {
+	for {
+		if ctx.Err() != nil {
+			return nil, nil, false
+		}
+
+		q.mu.Lock()
+		if job := q.runnable(); job != nil {
+			job.Attempts++
+			q.setState(job, StateRunning, "")
+			jobCtx, cancel := context.WithCancel(ctx)
+			q.cancels[job.ID] = cancel
+			if err := q.save(); err != nil {
+				slog.Error("failed to save job queue", "path", q.path, "err", err)
+			}
+			jobCopy := *job
+			q.mu.Unlock()
+
+			q.changed()
+			return &jobCopy, jobCtx, true
+		}
+		idle := q.closed && len(q.cancels) == 0
+		q.mu.Unlock()
+
+		if idle {
+			return nil, nil, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, false
+		case <-q.wake:
+		}
+	}
+}
+
+// run runs the job with its handler and records the outcome.
+func (q *Queue) run(ctx, jobCtx context.Context, job *Job) {
+	var err error
+	if h, ok := q.handlers[job.Kind]; ok {
+		err = h(jobCtx, job, func(progress float64) {
+			q.mu.Lock()
+			if j := q.find(job.ID); j != nil {
+				j.Progress = progress
+			}
+			q.mu.Unlock()
+
+			q.changed()
+		})
+	} else {
+		err = fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+
+	q.mu.Lock()
+	q.cancels[job.ID]()
+	delete(q.cancels, job.ID)
+	canceled := q.canceled[job.ID]
+	delete(q.canceled, job.ID)
+
+	j := q.find(job.ID)
+	j.Progress = 0
+	switch {
+	case err == nil:
+		q.setState(j, StateSucceeded, "")
+	case canceled:
+		q.setState(j, StateCanceled, "")
+	case ctx.Err() != nil:
+		// Interrupted by shutdown.
+		q.setState(j, StateQueued, "")
+	default:
+		q.setState(j, StateFailed, err.Error())
+	}
+	if err := q.save(); err != nil {
+		slog.Error("failed to save job queue", "path", q.path, "err", err)
+	}
+	q.mu.Unlock()
+
+	q.changed()
+	q.signal()
+}
+
+// runnable returns the oldest queued job whose dependency, if any, succeeded.
+// The caller must hold q.mu.
+func (q *Queue) runnable() *Job {
+	for _, job := range q.jobs {
+		if job.State != StateQueued {
+			continue
+		}
+
+		// A missing dependency succeeded and was forgotten by Open.
+		if dep := q.find(job.DependsOn); dep == nil || dep.State == StateSucceeded {
+			return job
+		}
+	}
+
+	return nil
+}
+
+// chain returns job followed by the jobs in the given state that depend on it,
+// directly or through each other. The caller must hold q.mu.
+func (q *Queue) chain(job *Job, state State) []*Job {
+	chain := []*Job{job}
+	for i := 0; i < len(chain); i++ {
+		for _, j := range q.jobs {
+			if j.DependsOn == chain[i].ID && j.State == state {
+				chain = append(chain, j)
+			}
+		}
+	}
+
+	return chain
+}
+
+// find returns the job with the given ID or nil. The caller must hold q.mu.
+func (q *Queue) find(id int) *Job {
+	if id == 0 {
+		return nil
+	}
+
+	for _, job := range q.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+
+	return nil
+}
+
+// setState changes the state of the job. The caller must hold q.mu.
+func (q *Queue) setState(job *Job, state State, errMsg string) {
+	job.State = state
+	job.Error = errMsg
+	job.UpdatedAt = time.Now()
+}
+
+// save writes the jobs to the queue file. The caller must hold q.mu.
+func (q *Queue) save() error {
+	jobs := q.jobs
+	if jobs == nil {
+		jobs = []*Job{}
+	}
+
+	b, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0644); err != nil {
+		return fmt.Errorf("write %q: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		return fmt.Errorf("rename %q to %q: %w", tmpPath, q.path, err)
+	}
+
+	return nil
+}
+
+func (q *Queue) changed() {
+	q.onChange()
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
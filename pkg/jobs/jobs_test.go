@@ -0,0 +1,194 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/jobs"
+)
+
+func openQueue(t *testing.T) *jobs.Queue {
+	t.Helper()
+
+	q, err := jobs.Open(filepath.Join(t.TempDir(), "jobs.json"))
+	require.NoError(t, err)
+
+	return q
+}
+
+func states(q *jobs.Queue) []jobs.State {
+	var states []jobs.State
+	for _, job := range q.Jobs() {
+		states = append(states, job.State)
+	}
+
+	return states
+}
+
+func TestRunInOrder(t *testing.T) {
+	q := openQueue(t)
+
+	var mu sync.Mutex
+	var ran []string
+	q.Handle("record", func(_ context.Context, job *jobs.Job, progress func(float64)) error {
+		progress(0.5)
+
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, job.Params["name"])
+		return nil
+	})
+
+	require.NoError(t, q.Add(
+		&jobs.Job{Kind: "record", Params: map[string]string{"name": "a"}},
+		&jobs.Job{Kind: "record", Params: map[string]string{"name": "b"}},
+		&jobs.Job{Kind: "record", Params: map[string]string{"name": "c"}},
+	))
+	q.Close()
+	q.Run(context.Background(), 3)
+
+	assert.Equal(t, []string{"a", "b", "c"}, ran)
+	assert.Equal(t, []jobs.State{jobs.StateSucceeded, jobs.StateSucceeded, jobs.StateSucceeded}, states(q))
+}
+
+func TestAddWhileRunning(t *testing.T) {
+	q := openQueue(t)
+
+	done := make(chan int, 1)
+	q.Handle("ok", func(_ context.Context, job *jobs.Job, _ func(float64)) error {
+		done <- job.ID
+		return nil
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(context.Background(), 2)
+		close(stopped)
+	}()
+
+	require.NoError(t, q.Add(&jobs.Job{Kind: "ok"}))
+	assert.Equal(t, 1, <-done)
+
+	q.Close()
+	<-stopped
+	assert.Equal(t, []jobs.State{jobs.StateSucceeded}, states(q))
+}
+
+func TestFailureAndRetry(t *testing.T) {
+	q := openQueue(t)
+
+	fail := true
+	q.Handle("flaky", func(context.Context, *jobs.Job, func(float64)) error {
+		if fail {
+			return errors.New("oops")
+		}
+		return nil
+	})
+	q.Handle("ok", func(context.Context, *jobs.Job, func(float64)) error {
+		return nil
+	})
+
+	require.NoError(t, q.Add(&jobs.Job{Kind: "flaky"}, &jobs.Job{Kind: "ok"}))
+	q.Close()
+	q.Run(context.Background(), 1)
+
+	// The second job waits for the first one.
+	all := q.Jobs()
+	require.Len(t, all, 2)
+	assert.Equal(t, jobs.StateFailed, all[0].State)
+	assert.Equal(t, "oops", all[0].Error)
+	assert.Equal(t, jobs.StateQueued, all[1].State)
+
+	require.ErrorIs(t, q.Retry(99), jobs.ErrNotFound)
+	require.Error(t, q.Retry(all[1].ID))
+
+	fail = false
+	require.NoError(t, q.Retry(all[0].ID))
+	q.Run(context.Background(), 1)
+
+	all = q.Jobs()
+	assert.Equal(t, []jobs.State{jobs.StateSucceeded, jobs.StateSucceeded}, states(q))
+	assert.Equal(t, 2, all[0].Attempts)
+	assert.Empty(t, all[0].Error)
+}
+
+func TestCancel(t *testing.T) {
+	q := openQueue(t)
+
+	started := make(chan int)
+	q.Handle("block", func(ctx context.Context, job *jobs.Job, _ func(float64)) error {
+		started <- job.ID
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	require.NoError(t, q.Add(&jobs.Job{Kind: "block"}, &jobs.Job{Kind: "block"}))
+	q.Close()
+
+	done := make(chan struct{})
+	go func() {
+		q.Run(context.Background(), 1)
+		close(done)
+	}()
+
+	// Canceling the running job also cancels the job that depends on it.
+	id := <-started
+	require.NoError(t, q.Cancel(id))
+	<-done
+	assert.Equal(t, []jobs.State{jobs.StateCanceled, jobs.StateCanceled}, states(q))
+	require.Error(t, q.Cancel(id))
+
+	// Retrying it queues both again.
+	require.NoError(t, q.Retry(id))
+	assert.Equal(t, []jobs.State{jobs.StateQueued, jobs.StateQueued}, states(q))
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	q, err := jobs.Open(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Handle("ok", func(context.Context, *jobs.Job, func(float64)) error {
+		return nil
+	})
+	q.Handle("block", func(ctx context.Context, _ *jobs.Job, _ func(float64)) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	require.NoError(t, q.Add(&jobs.Job{Kind: "ok"}, &jobs.Job{Kind: "block", Description: "interrupted"}))
+	q.Run(ctx, 1)
+	assert.Equal(t, []jobs.State{jobs.StateSucceeded, jobs.StateQueued}, states(q))
+
+	// The succeeded job is forgotten, the interrupted one is kept and new
+	// jobs get new IDs.
+	q, err = jobs.Open(path)
+	require.NoError(t, err)
+	all := q.Jobs()
+	require.Len(t, all, 1)
+	assert.Equal(t, 2, all[0].ID)
+	assert.Equal(t, 1, all[0].DependsOn)
+	assert.Equal(t, "interrupted", all[0].Description)
+	assert.Equal(t, jobs.StateQueued, all[0].State)
+
+	require.NoError(t, q.Add(&jobs.Job{Kind: "ok"}))
+	assert.Equal(t, 3, q.Jobs()[1].ID)
+
+	q.Handle("ok", func(context.Context, *jobs.Job, func(float64)) error {
+		return nil
+	})
+	q.Handle("block", func(context.Context, *jobs.Job, func(float64)) error {
+		return nil
+	})
+	q.Close()
+	q.Run(context.Background(), 2)
+	assert.Equal(t, []jobs.State{jobs.StateSucceeded, jobs.StateSucceeded}, states(q))
+}
@@ -0,0 +1,558 @@
+// Command fakemakemkvcon is a test double for makemkvcon. It replays scripted
+// output so that code built on makemkv.Con can be tested on a machine without
+// an optical drive (or makemkv).
+//
+// The script is a JSON file identified by the FAKEMAKEMKVCON_SCRIPT
+// environment variable. For example:
+//
+//	{
+//	  "drives": [
+//	    {"name": "BD-RE Fake Drive", "discTitle": "A Fake Movie", "volume": "/dev/sr0", "disc": "disc.txt"},
+//	    {"name": "DVD Fake Drive", "volume": "/dev/sr1"}
+//	  ],
+//	  "lineDelay": "10ms",
+//	  "failures": [
+//	    {"command": "mkv", "source": "disc:0", "afterLines": 3, "exitCode": 1, "message": "Read error"}
+//	  ]
+//	}
+//
+// A drive's disc is the path (relative to the script) of a file containing the
+// output of "makemkvcon -r info" for the disc in the drive. Drives without a
+// disc are reported as empty. Titles shorter than --minlength are removed from
+// the output and the remaining titles are renumbered, like makemkvcon does.
+//
+// ISO images and disc folders contain the "info" output themselves: an
+// iso:PATH source is read from the file at PATH and a file:PATH source from
+// PATH/BDMV/index.bdmv or PATH/VIDEO_TS/VIDEO_TS.IFO.
+//
+// The "mkv" command writes a Matroska file without media data, with the
+// title's streams and duration, named after the title's OutputFileName
+// attribute to the destination directory. If fullSize is true, the file is
+// extended to the size of the title (as a sparse file where the file system
+// supports it), so that it passes mkvbot's verification. The "backup" command
+// writes the "info" output to BDMV/index.bdmv (or VIDEO_TS/VIDEO_TS.IFO for
+// discs that are not Blu-ray discs) in the destination directory, so the
+// backup can be used as a file: source.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/matroska"
+)
+
+// scriptEnvVar is the name of the environment variable that identifies the
+// script file.
+const scriptEnvVar = "FAKEMAKEMKVCON_SCRIPT"
+
+type (
+	script struct {
+		// Drives are the drives reported by every "info" command.
+		Drives []*drive `json:"drives"`
+
+		// LineDelay is slept before each line of output to simulate a slow
+		// drive.
+		LineDelay duration `json:"lineDelay"`
+
+		// Failures simulate commands that terminate abnormally.
+		Failures []*failure `json:"failures"`
+
+		// FullSize makes the files written by "mkv" as large as the title.
+		FullSize bool `json:"fullSize"`
+
+		dir string
+	}
+
+	drive struct {
+		Name      string `json:"name"`
+		DiscTitle string `json:"discTitle"`
+		Volume    string `json:"volume"`
+
+		// Disc is the path of a file with "info" output for the disc in the
+		// drive. It is relative to the script file. Empty means no disc.
+		Disc string `json:"disc"`
+	}
+
+	failure struct {
+		// Command is the makemkvcon command that fails, e.g., "info" or "mkv".
+		Command string `json:"command"`
+
+		// Source is the source that fails, e.g., "disc:0". Empty matches any
+		// source.
+		Source string `json:"source"`
+
+		// AfterLines is the number of lines output before failing.
+		AfterLines int `json:"afterLines"`
+
+		// ExitCode is the exit code. It defaults to 1.
+		ExitCode int `json:"exitCode"`
+
+		// Message is output as a MSG line before exiting.
+		Message string `json:"message"`
+	}
+
+	duration time.Duration
+)
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = duration(v)
+	return nil
+}
+
+// args are the parsed command-line arguments.
+type args struct {
+	minLength  time.Duration
+	command    string
+	source     string
+	positional []string
+}
+
+// exitError terminates the program with the given code.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit code %d", e.code)
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+
+		fmt.Fprintf(os.Stderr, "fakemakemkvcon: %s\n", err)
+		os.Exit(2)
+	}
+}
+
+func run(argv []string) error {
+	s, err := loadScript(os.Getenv(scriptEnvVar))
+	if err != nil {
+		return err
+	}
+
+	a, err := parseArgs(argv)
+	if err != nil {
+		return err
+	}
+
+	out := &output{script: s, args: a}
+	for _, f := range s.Failures {
+		if f.Command == a.command && (f.Source == "" || f.Source == a.source) {
+			out.failure = f
+			break
+		}
+	}
+
+	switch a.command {
+	case "info":
+		return out.info()
+	case "mkv":
+		return out.mkv()
+	case "backup":
+		return out.backup()
+	default:
+		return fmt.Errorf("unsupported command %q", a.command)
+	}
+}
+
+func loadScript(path string) (*script, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s is not set", scriptEnvVar)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read script: %w", err)
+	}
+
+	s := &script{dir: filepath.Dir(path)}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("parse script %q: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func parseArgs(argv []string) (*args, error) {
+	a := &args{}
+	for _, arg := range argv {
+		if !strings.HasPrefix(arg, "-") {
+			a.positional = append(a.positional, arg)
+			continue
+		}
+
+		if v, ok := strings.CutPrefix(arg, "--minlength="); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("parse %q: %w", arg, err)
+			}
+			a.minLength = time.Duration(n) * time.Second
+		}
+	}
+
+	if len(a.positional) < 2 {
+		return nil, fmt.Errorf("expected a command and a source, got %q", argv)
+	}
+
+	a.command, a.source = a.positional[0], a.positional[1]
+	a.positional = a.positional[2:]
+	return a, nil
+}
+
+// output writes lines to stdout, honoring the script's line delay and
+// failures.
+type output struct {
+	script  *script
+	args    *args
+	failure *failure
+	lines   int
+}
+
+func (o *output) println(format string, v ...any) error {
+	if f := o.failure; f != nil && o.lines >= f.AfterLines {
+		if f.Message != "" {
+			fmt.Printf("MSG:5010,0,0,%s,%s\n", quote(f.Message), quote(f.Message))
+		}
+
+		code := f.ExitCode
+		if code == 0 {
+			code = 1
+		}
+		return &exitError{code: code}
+	}
+
+	time.Sleep(time.Duration(o.script.LineDelay))
+	fmt.Printf(format+"\n", v...)
+	o.lines++
+	return nil
+}
+
+func (o *output) message(s string) error {
+	return o.println("MSG:5000,0,0,%s,%s", quote(s), quote(s))
+}
+
+func (o *output) drives() error {
+	for i, d := range o.script.Drives {
+		flags := 256
+		if d.Disc != "" {
+			flags = 2
+		}
+
+		if err := o.println("DRV:%d,%d,999,0,%s,%s,%s", i, flags, quote(d.Name), quote(d.DiscTitle), quote(d.Volume)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discPath returns the path of the file with "info" output for the source or
+// "" if there is no disc.
+func (o *output) discPath() string {
+	typ, v, _ := strings.Cut(o.args.source, ":")
+	switch typ {
+	case "disc":
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 || i >= len(o.script.Drives) || o.script.Drives[i].Disc == "" {
+			return ""
+		}
+
+		path := o.script.Drives[i].Disc
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(o.script.dir, path)
+		}
+		return path
+	case "iso":
+		return v
+	case "file":
+		for _, name := range []string{filepath.Join("BDMV", "index.bdmv"), filepath.Join("VIDEO_TS", "VIDEO_TS.IFO")} {
+			if path := filepath.Join(v, name); fileExists(path) {
+				return path
+			}
+		}
+	}
+
+	return ""
+}
+
+func (o *output) info() error {
+	if err := o.drives(); err != nil {
+		return err
+	}
+
+	path := o.discPath()
+	if path == "" {
+		return o.message(fmt.Sprintf("Failed to open disc %s", o.args.source))
+	}
+
+	lines, _, err := o.readDisc(path)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if err := o.println("%s", line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *output) mkv() error {
+	if len(o.args.positional) != 2 {
+		return fmt.Errorf("expected a title and a directory, got %q", o.args.positional)
+	}
+
+	path := o.discPath()
+	if path == "" {
+		return o.message(fmt.Sprintf("Failed to open disc %s", o.args.source))
+	}
+
+	_, disc, err := o.readDisc(path)
+	if err != nil {
+		return err
+	}
+
+	titleIndex, err := strconv.Atoi(o.args.positional[0])
+	if err != nil || titleIndex < 0 || titleIndex >= disc.TitleCount() {
+		if err := o.message(fmt.Sprintf("Title %s not found", o.args.positional[0])); err != nil {
+			return err
+		}
+		return &exitError{code: 1}
+	}
+	title := disc.Titles[titleIndex]
+
+	if err := o.progress(`PRGT:5024,0,"Saving all titles to MKV files"`, `PRGC:5017,0,"Saving to MKV file"`); err != nil {
+		return err
+	}
+
+	name, err := title.GetAttr(defs.OutputFileName)
+	if err != nil {
+		return fmt.Errorf("title %d has no output file name", titleIndex)
+	}
+
+	if err := o.writeMKV(filepath.Join(o.args.positional[1], name), title); err != nil {
+		return err
+	}
+
+	return o.message("1 titles saved")
+}
+
+// writeMKV writes a Matroska file with the title's streams and duration.
+func (o *output) writeMKV(path string, title *makemkv.Title) (err error) {
+	duration, _ := title.GetAttrDuration(defs.Duration)
+	f := &matroska.File{
+		MuxingApp:  "fakemakemkvcon",
+		WritingApp: "fakemakemkvcon",
+		Duration:   duration,
+	}
+
+	trackTypes := map[defs.TypeCode]matroska.TrackType{
+		defs.TypeCodeVideo:     matroska.TrackTypeVideo,
+		defs.TypeCodeAudio:     matroska.TrackTypeAudio,
+		defs.TypeCodeSubtitles: matroska.TrackTypeSubtitle,
+	}
+	for _, stream := range title.Streams {
+		f.Tracks = append(f.Tracks, &matroska.Track{
+			Number:   uint64(len(f.Tracks) + 1),
+			Type:     trackTypes[stream.Type()],
+			CodecID:  stream.GetAttrDefault(defs.CodecID, ""),
+			Language: stream.GetAttrDefault(defs.LangCode, "und"),
+		})
+	}
+
+	fp, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %q: %w", path, err)
+	}
+	defer func() {
+		err = errors.Join(err, fp.Close())
+	}()
+
+	if err := matroska.Write(fp, f); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+
+	if o.script.FullSize {
+		size, err := title.GetAttrInt(defs.DiscSizeBytes)
+		if err != nil {
+			return fmt.Errorf("get title size: %w", err)
+		}
+
+		if err := fp.Truncate(int64(size)); err != nil {
+			return fmt.Errorf("truncate %q: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func (o *output) backup() error {
+	if len(o.args.positional) != 1 {
+		return fmt.Errorf("expected a directory, got %q", o.args.positional)
+	}
+
+	path := o.discPath()
+	if path == "" {
+		return o.message(fmt.Sprintf("Failed to open disc %s", o.args.source))
+	}
+
+	_, disc, err := o.readDisc(path)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read disc: %w", err)
+	}
+
+	if err := o.progress(`PRGT:5018,0,"Backing up disc"`, `PRGC:5018,0,"Decrypting and copying files"`); err != nil {
+		return err
+	}
+
+	path = filepath.Join(o.args.positional[0], "VIDEO_TS", "VIDEO_TS.IFO")
+	if strings.Contains(disc.GetAttrDefault(defs.Type, ""), "Blu-ray") {
+		path = filepath.Join(o.args.positional[0], "BDMV", "index.bdmv")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+
+	return o.message("Backup done")
+}
+
+// progress outputs the task lines followed by progress from 0 to 100%.
+func (o *output) progress(taskLines ...string) error {
+	for _, line := range taskLines {
+		if err := o.println("%s", line); err != nil {
+			return err
+		}
+	}
+
+	const steps, denominator = 8, 65536
+	for i := range steps + 1 {
+		v := i * denominator / steps
+		if err := o.println("PRGV:%d,%d,%d", v, v, denominator); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readDisc reads the disc file at path and returns its lines (less any titles
+// shorter than the minimum length) and the disc they describe.
+func (o *output) readDisc(path string) ([]string, *makemkv.Disc, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read disc: %w", err)
+	}
+
+	var (
+		raw    []string
+		parsed []*makemkv.Line
+		disc   = &makemkv.Disc{}
+	)
+	for s := range strings.Lines(string(b)) {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		line, err := makemkv.ParseLine(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse %q: %w", s, err)
+		}
+
+		raw = append(raw, s)
+		parsed = append(parsed, line)
+		if di := line.DiscInfo; di != nil {
+			disc.Info = append(disc.Info, di.Attribute)
+		}
+		if ti := line.TitleInfo; ti != nil {
+			t := disc.GetTitle(ti.TitleIndex)
+			t.Info = append(t.Info, ti.Attribute)
+		}
+		if si := line.StreamInfo; si != nil {
+			stream := disc.GetTitle(si.TitleIndex).GetStream(si.StreamIndex)
+			stream.Info = append(stream.Info, si.Attribute)
+		}
+	}
+
+	// Map original title indexes to renumbered indexes.
+	indexes := make(map[int]int)
+	filtered := &makemkv.Disc{Info: disc.Info}
+	for _, t := range disc.Titles {
+		if length, err := t.GetAttrDuration(defs.Duration); err == nil && length < o.args.minLength {
+			continue
+		}
+
+		indexes[t.Index] = len(indexes)
+		filtered.GetTitle(indexes[t.Index]).Info = t.Info
+		filtered.GetTitle(indexes[t.Index]).Streams = t.Streams
+	}
+
+	var lines []string
+	for i, line := range parsed {
+		switch {
+		case line.TitleCount != nil:
+			lines = append(lines, fmt.Sprintf("TCOUNT:%d", filtered.TitleCount()))
+		case line.TitleInfo != nil:
+			ti := line.TitleInfo
+			if index, ok := indexes[ti.TitleIndex]; ok {
+				lines = append(lines, fmt.Sprintf("TINFO:%d,%s", index, formatAttribute(ti.Attribute)))
+			}
+		case line.StreamInfo != nil:
+			si := line.StreamInfo
+			if index, ok := indexes[si.TitleIndex]; ok {
+				lines = append(lines, fmt.Sprintf("SINFO:%d,%d,%s", index, si.StreamIndex, formatAttribute(si.Attribute)))
+			}
+		default:
+			lines = append(lines, raw[i])
+		}
+	}
+
+	return lines, filtered, nil
+}
+
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
+
+func formatAttribute(a *makemkv.Attribute) string {
+	return fmt.Sprintf("%d,%d,%s", a.ID, a.Code, quote(string(a.Value)))
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -71,3 +71,14 @@ const (
 	TypeCodeAudio
 	TypeCodeSubtitles
 )
+
+// ParseAttr returns the attribute with the given name, e.g., "Duration".
+func ParseAttr(name string) (Attr, bool) {
+	for attr := Unknown; attr <= OffsetSequenceID; attr++ {
+		if attr.String() == name {
+			return attr, true
+		}
+	}
+
+	return Unknown, false
+}
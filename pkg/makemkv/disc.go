@@ -2,6 +2,10 @@ package makemkv
 
 import (
 	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
@@ -9,9 +13,9 @@ import (
 
 // Disc is a sequence of titles plus some metadata.
 type Disc struct {
-	Info
+	Info `json:"info"`
 
-	Titles []*Title
+	Titles []*Title `json:"titles"`
 }
 
 // GetTitle returns the title with the given index, creating it (and previous
@@ -66,6 +70,49 @@ func (d *Disc) TitlesWithMostStreams() []*Title {
 	})
 }
 
+// fingerprintMinLength is the length of the shortest title that contributes
+// to a fingerprint, unless every title is shorter.
+const fingerprintMinLength = 30 * time.Minute
+
+// Fingerprint returns a string that identifies the disc based on its volume
+// name and the duration, segment map, and size of each title. Inserting the
+// same disc again yields the same fingerprint.
+//
+// makemkv only reports (and numbers) the titles that are at least as long as
+// the --minlength argument, so the titles shorter than fingerprintMinLength
+// are ignored and the title numbers are not used. The fingerprint is the same
+// for any --minlength argument of at most 30 minutes, unless every title is
+// shorter, e.g., on a disc of TV episodes, in which case every title counts.
+func (d *Disc) Fingerprint() string {
+	titles := slices.DeleteFunc(slices.Clone(d.Titles), func(title *Title) bool {
+		duration, err := title.GetAttrDuration(defs.Duration)
+		return err != nil || duration < fingerprintMinLength
+	})
+	if len(titles) == 0 {
+		titles = d.Titles
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "volume=%q name=%q titles=%d\n", d.GetAttrDefault(defs.VolumeName, ""), d.GetAttrDefault(defs.Name, ""), len(titles))
+	for _, title := range titles {
+		size := title.GetAttrDefault(defs.DiscSizeBytes, "")
+		if size == "" {
+			size = title.GetAttrDefault(defs.DiskSize, "")
+		}
+
+		fmt.Fprintf(
+			h,
+			"duration=%q segments=%q size=%q streams=%d\n",
+			title.GetAttrDefault(defs.Duration, ""),
+			title.GetAttrDefault(defs.SegmentsMap, ""),
+			size,
+			len(title.Streams),
+		)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Maximums returns all elements of the slice that maximize the given function,
 // i.e., where f(e) = max(f(e0), f(e1), ..., f(eN)).
 func Maximums[S []E, E any, V cmp.Ordered](s S, f func(E) (V, error)) S {
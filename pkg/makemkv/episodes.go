@@ -0,0 +1,174 @@
+package makemkv
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+)
+
+// EpisodeDurationTolerance is how much longer than the shortest episode of a
+// disc the other episodes may be, as a fraction of its duration.
+const EpisodeDurationTolerance = 0.25
+
+// EpisodeTitles returns the titles that appear to be the episodes of a TV
+// series, in disc order.
+//
+// Episodes are the largest group of titles that have the same stream layout
+// and durations within EpisodeDurationTolerance of each other. Titles that
+// play several other titles in a row ("play all") and titles that repeat the
+// segments of an earlier title (e.g., other angles) are ignored.
+func (d *Disc) EpisodeTitles() []*Title {
+	segments := make(map[*Title][]int, len(d.Titles))
+	for _, title := range d.Titles {
+		segments[title] = title.Segments()
+	}
+
+	var (
+		seen    = map[string]bool{}
+		layouts []string
+		groups  = map[string][]*Title{}
+	)
+	for _, title := range d.Titles {
+		if duration, err := title.GetAttrDuration(defs.Duration); err != nil || duration <= 0 {
+			continue
+		}
+
+		if s := segments[title]; len(s) > 0 {
+			key := fmt.Sprint(s)
+			if seen[key] || playsOthers(title, segments) {
+				continue
+			}
+			seen[key] = true
+		}
+
+		layout := title.StreamLayout()
+		if _, ok := groups[layout]; !ok {
+			layouts = append(layouts, layout)
+		}
+		groups[layout] = append(groups[layout], title)
+	}
+
+	var (
+		best          []*Title
+		bestTotalTime time.Duration
+	)
+	for _, layout := range layouts {
+		cluster, totalTime := clusterDurations(groups[layout])
+		if len(cluster) > len(best) || (len(cluster) == len(best) && totalTime > bestTotalTime) {
+			best, bestTotalTime = cluster, totalTime
+		}
+	}
+
+	slices.SortFunc(best, func(a, b *Title) int {
+		return a.Index - b.Index
+	})
+
+	return best
+}
+
+// clusterDurations returns the largest subset of titles whose durations are
+// within EpisodeDurationTolerance of each other, preferring longer titles, and
+// its total duration.
+func clusterDurations(titles []*Title) ([]*Title, time.Duration) {
+	durations := make(map[*Title]time.Duration, len(titles))
+	for _, title := range titles {
+		durations[title], _ = title.GetAttrDuration(defs.Duration)
+	}
+
+	sorted := slices.Clone(titles)
+	slices.SortStableFunc(sorted, func(a, b *Title) int {
+		return int(durations[a] - durations[b])
+	})
+
+	var (
+		best          []*Title
+		bestTotalTime time.Duration
+	)
+	for i, shortest := range sorted {
+		limit := durations[shortest] + time.Duration(float64(durations[shortest])*EpisodeDurationTolerance)
+
+		var totalTime time.Duration
+		j := i
+		for ; j < len(sorted) && durations[sorted[j]] <= limit; j++ {
+			totalTime += durations[sorted[j]]
+		}
+
+		if j-i > len(best) || (j-i == len(best) && totalTime > bestTotalTime) {
+			best, bestTotalTime = sorted[i:j], totalTime
+		}
+	}
+
+	return slices.Clone(best), bestTotalTime
+}
+
+// playsOthers returns true if the segments of title include all of the
+// segments of at least two other titles.
+func playsOthers(title *Title, segments map[*Title][]int) bool {
+	n := 0
+	for other, s := range segments {
+		if other == title || len(s) == 0 || len(s) >= len(segments[title]) {
+			continue
+		}
+
+		if !slices.ContainsFunc(s, func(segment int) bool {
+			return !slices.Contains(segments[title], segment)
+		}) {
+			n++
+		}
+	}
+
+	return n >= 2
+}
+
+// Segments returns the segment numbers of the SegmentsMap attribute, e.g.,
+// "1,3-5" yields [1 3 4 5]. It returns nil if the attribute is missing or
+// malformed.
+func (t *Title) Segments() []int {
+	v, err := t.GetAttr(defs.SegmentsMap)
+	if err != nil {
+		return nil
+	}
+
+	var segments []int
+	for _, part := range strings.Split(v, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		a, err := strconv.Atoi(first)
+		if err != nil {
+			return nil
+		}
+
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(last); err != nil || b < a {
+				return nil
+			}
+		}
+
+		for i := a; i <= b; i++ {
+			segments = append(segments, i)
+		}
+	}
+
+	return segments
+}
+
+// StreamLayout returns a string that describes the type, codec and language
+// of each stream of the title. Titles from the same series usually share a
+// layout.
+func (t *Title) StreamLayout() string {
+	layout := make([]string, len(t.Streams))
+	for i, stream := range t.Streams {
+		layout[i] = fmt.Sprintf(
+			"%d/%s/%s",
+			stream.Type(),
+			stream.GetAttrDefault(defs.CodecID, ""),
+			stream.GetAttrDefault(defs.LangCode, ""),
+		)
+	}
+
+	return strings.Join(layout, ",")
+}
@@ -0,0 +1,109 @@
+package makemkv_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+)
+
+// newTitle returns a title with the given duration and segments map and a
+// video stream plus an audio stream in the given language.
+func newTitle(index int, duration, segments, lang string) *makemkv.Title {
+	title := &makemkv.Title{
+		Index: index,
+		Info: makemkv.Info{
+			{ID: int(defs.Duration), Value: makemkv.Str(duration)},
+			{ID: int(defs.SegmentsMap), Value: makemkv.Str(segments)},
+		},
+	}
+	title.GetStream(0).Info = makemkv.Info{
+		{ID: int(defs.Type), Code: int(defs.TypeCodeVideo), Value: "Video"},
+		{ID: int(defs.CodecID), Value: "V_MPEG4/ISO/AVC"},
+	}
+	title.GetStream(1).Info = makemkv.Info{
+		{ID: int(defs.Type), Code: int(defs.TypeCodeAudio), Value: "Audio"},
+		{ID: int(defs.CodecID), Value: "A_AC3"},
+		{ID: int(defs.LangCode), Value: makemkv.Str(lang)},
+	}
+
+	return title
+}
+
+func indexes(titles []*makemkv.Title) []int {
+	var s []int
+	for _, title := range titles {
+		s = append(s, title.Index)
+	}
+
+	return s
+}
+
+func TestEpisodeTitles(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		titles   []*makemkv.Title
+		expected []int
+	}{
+		{
+			name: "play all and extras",
+			titles: []*makemkv.Title{
+				newTitle(0, "1:28:40", "1-4", "eng"),
+				newTitle(1, "0:22:10", "1", "eng"),
+				newTitle(2, "0:21:55", "2", "eng"),
+				newTitle(3, "0:23:40", "3", "eng"),
+				newTitle(4, "0:20:55", "4", "eng"),
+				newTitle(5, "0:05:00", "9", "eng"),
+				newTitle(6, "0:06:30", "10", "eng"),
+			},
+			expected: []int{1, 2, 3, 4},
+		},
+		{
+			name: "other angle",
+			titles: []*makemkv.Title{
+				newTitle(0, "0:44:00", "1", "eng"),
+				newTitle(1, "0:44:00", "1", "eng"),
+				newTitle(2, "0:45:10", "2", "eng"),
+			},
+			expected: []int{0, 2},
+		},
+		{
+			name: "stream layout",
+			titles: []*makemkv.Title{
+				newTitle(0, "0:44:00", "1", "eng"),
+				newTitle(1, "0:43:00", "2", "fra"),
+				newTitle(2, "0:42:00", "3", "fra"),
+			},
+			expected: []int{1, 2},
+		},
+		{
+			name: "longer episodes win ties",
+			titles: []*makemkv.Title{
+				newTitle(0, "0:10:00", "1", "eng"),
+				newTitle(1, "0:11:00", "2", "eng"),
+				newTitle(2, "0:44:00", "3", "eng"),
+				newTitle(3, "0:43:00", "4", "eng"),
+			},
+			expected: []int{2, 3},
+		},
+		{
+			name:     "no titles",
+			expected: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			disc := &makemkv.Disc{Titles: tc.titles}
+			assert.Equal(t, tc.expected, indexes(disc.EpisodeTitles()))
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	title := newTitle(0, "0:22:00", "1,3-5", "eng")
+	assert.Equal(t, []int{1, 3, 4, 5}, title.Segments())
+
+	title = newTitle(0, "0:22:00", "1,x", "eng")
+	assert.Nil(t, title.Segments())
+}
@@ -5,4 +5,12 @@ import "fmt"
 var (
 	// ErrNotFound is returned when something is not found.
 	ErrNotFound = fmt.Errorf("not found")
+
+	// ErrNoTranscript is returned when replaying a command for which there is
+	// no unused transcript, e.g., because every transcript was replayed.
+	ErrNoTranscript = fmt.Errorf("transcript %w", ErrNotFound)
+
+	// errStopped is the result of a command whose output was not read to the
+	// end.
+	errStopped = fmt.Errorf("stopped reading output")
 )
@@ -91,7 +91,7 @@ type (
 	// makemkvcon output lines, which describe an attribute of a disc, title, or
 	// stream.
 	Attribute struct {
-		Pos lexer.Position
+		Pos lexer.Position `parser:"" json:"-"`
 
 		// ID is an integer that identifies the attribute.
 		ID int `@Int`
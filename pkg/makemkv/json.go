@@ -0,0 +1,79 @@
+package makemkv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+)
+
+// attributeJSON is the JSON form of an Attribute. The name is the name of the
+// defs.Attr, which is easier to read than the ID.
+type attributeJSON struct {
+	ID    *int   `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Code  int    `json:"code,omitempty"`
+	Value string `json:"value"`
+}
+
+// MarshalJSON encodes the attribute as an object with its ID, name, code (if
+// any) and value, e.g., {"id":9,"name":"Duration","value":"1:52:10"}.
+func (a *Attribute) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&attributeJSON{
+		ID:    &a.ID,
+		Name:  defs.Attr(a.ID).String(),
+		Code:  a.Code,
+		Value: string(a.Value),
+	})
+}
+
+// UnmarshalJSON decodes an attribute encoded by MarshalJSON. The ID may be
+// omitted if the name is known.
+func (a *Attribute) UnmarshalJSON(b []byte) error {
+	var v attributeJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch attr, ok := defs.ParseAttr(v.Name); {
+	case v.ID != nil:
+		a.ID = *v.ID
+	case ok:
+		a.ID = int(attr)
+	default:
+		return fmt.Errorf("attribute has no ID and an unknown name %q", v.Name)
+	}
+
+	a.Code, a.Value = v.Code, Str(v.Value)
+	return nil
+}
+
+// LoadDisc loads a disc that was saved as JSON, e.g., with json.Marshal, from
+// the file at path. The file may also be an mkvbot.json sidecar file, which
+// has the disc in its "disc" field.
+func LoadDisc(path string) (*Disc, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+
+	var v struct {
+		Disc
+
+		Sidecar *Disc `json:"disc"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("decode %q: %w", path, err)
+	}
+
+	disc := &v.Disc
+	if v.Sidecar != nil {
+		disc = v.Sidecar
+	}
+	if len(disc.Info) == 0 && len(disc.Titles) == 0 {
+		return nil, fmt.Errorf("%q does not contain a disc", path)
+	}
+
+	return disc, nil
+}
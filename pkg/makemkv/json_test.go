@@ -0,0 +1,74 @@
+package makemkv_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+)
+
+func TestDiscJSON(t *testing.T) {
+	disc, errs := scanDisc(t, newFakeCon(t, defaultFakeScript(), 120))
+	require.Empty(t, errs)
+
+	b, err := json.Marshal(disc)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `{"id":9,"name":"Duration","value":"1:52:10"}`)
+	assert.Contains(t, string(b), `"name":"Type","code":6201,"value":"Video"`)
+
+	path := filepath.Join(t.TempDir(), "disc.json")
+	require.NoError(t, os.WriteFile(path, b, 0600))
+
+	loaded, err := makemkv.LoadDisc(path)
+	require.NoError(t, err)
+	require.Len(t, loaded.Titles, len(disc.Titles))
+	assert.Equal(t, disc.Fingerprint(), loaded.Fingerprint())
+	assert.Equal(t, disc.GetAttrDefault(defs.Name, ""), loaded.GetAttrDefault(defs.Name, ""))
+	for i, title := range loaded.Titles {
+		assert.Equal(t, i, title.Index)
+		assert.Len(t, title.Streams, len(disc.Titles[i].Streams))
+		assert.Equal(t, disc.Titles[i].Streams[0].Type(), title.Streams[0].Type())
+	}
+
+	// Encoding the loaded disc gives the same JSON.
+	b2, err := json.Marshal(loaded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(b), string(b2))
+}
+
+func TestLoadDisc(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, s string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(s), 0600))
+		return path
+	}
+
+	// Attributes may be given by name only, and the disc may be in a sidecar.
+	disc, err := makemkv.LoadDisc(write("mkvbot.json", `{
+		"drive": "BD-RE Fake Drive",
+		"disc": {
+			"info": [{"name": "Name", "value": "A Fake Movie"}],
+			"titles": [{"index": 0, "info": [{"name": "Duration", "value": "0:42:00"}]}]
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "A Fake Movie", disc.GetAttrDefault(defs.Name, ""))
+	require.Len(t, disc.Titles, 1)
+	assert.Equal(t, "0:42:00", disc.Titles[0].GetAttrDefault(defs.Duration, ""))
+
+	_, err = makemkv.LoadDisc(write("unknown.json", `{"info": [{"name": "Nonsense", "value": "x"}]}`))
+	require.ErrorContains(t, err, "Nonsense")
+
+	_, err = makemkv.LoadDisc(write("empty.json", `{}`))
+	require.Error(t, err)
+
+	_, err = makemkv.LoadDisc(filepath.Join(dir, "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -2,7 +2,9 @@ package makemkv
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"iter"
 	"log/slog"
 	"os"
@@ -37,11 +39,21 @@ type Config struct {
 	// It filters out titles with video streams less than the given length, which
 	// is very useful for weeding out unimportant streams.
 	MinLengthSeconds int64 `validate:"min=1"`
+
+	// RecordDir is the path to a directory where a Transcript of every
+	// makemkvcon invocation is written. It is created if necessary. Recording
+	// is disabled if empty.
+	RecordDir string
+
+	// ReplayDir is the path to a directory of transcripts written due to
+	// RecordDir. If non-empty, makemkvcon is not run. Instead, each command is
+	// answered with the first unused transcript of a matching invocation.
+	ReplayDir string `validate:"excluded_with=RecordDir"`
 }
 
 // Validate returns an error if the configuration is invalid.
 func (cfg *Config) Validate() error {
-	if !fileExists(cfg.ExePath) {
+	if cfg.ReplayDir == "" && !fileExists(cfg.ExePath) {
 		return fmt.Errorf("file %q not found", cfg.ExePath)
 	}
 
@@ -57,14 +69,17 @@ type Con struct {
 	cfg *Config
 
 	defaultArgs []string
+
+	recorder *recorder
+	replayer *replayer
 }
 
 // New returns a new Con.
 //
 // If cfg.ExePath is empty, it will attempt to locate the executable
-// automatically.
+// automatically, unless cfg.ReplayDir is set.
 func New(cfg *Config) (*Con, error) {
-	if cfg.ExePath == "" {
+	if cfg.ExePath == "" && cfg.ReplayDir == "" {
 		var err error
 		if cfg.ExePath, err = FindExe(); err != nil {
 			return nil, fmt.Errorf("find makemkvcon executable")
@@ -84,10 +99,31 @@ func New(cfg *Config) (*Con, error) {
 		defaultArgs = append(defaultArgs, fmt.Sprintf("--profile=%s", cfg.ProfilePath))
 	}
 
-	return &Con{
+	con := &Con{
 		cfg:         cfg,
 		defaultArgs: defaultArgs,
-	}, nil
+	}
+
+	var err error
+	if cfg.RecordDir != "" {
+		if con.recorder, err = newRecorder(cfg.RecordDir); err != nil {
+			return nil, fmt.Errorf("initialize recorder: %w", err)
+		}
+	}
+
+	if cfg.ReplayDir != "" {
+		if con.replayer, err = newReplayer(cfg.ReplayDir); err != nil {
+			return nil, fmt.Errorf("initialize replayer: %w", err)
+		}
+	}
+
+	return con, nil
+}
+
+// Replaying reports whether commands are replayed from transcripts instead of
+// running makemkvcon.
+func (c *Con) Replaying() bool {
+	return c.replayer != nil
 }
 
 // ListDrives returns the list of drives detected by makemkvcon.
@@ -123,7 +159,12 @@ func (c *Con) ListDrives(ctx context.Context) (*LineIterator[[]*DriveScan], erro
 // ScanDrive returns information about the disc in the given drive. The
 // driveIndex should be obtained from ListDrives.
 func (c *Con) ScanDrive(ctx context.Context, driveIndex int) (*LineIterator[*Disc], error) {
-	seq, err := c.RunDefaultCmd(ctx, "info", fmt.Sprintf("disc:%d", driveIndex))
+	return c.Scan(ctx, DriveSource(driveIndex))
+}
+
+// Scan returns information about the disc of the given source.
+func (c *Con) Scan(ctx context.Context, src Source) (*LineIterator[*Disc], error) {
+	seq, err := c.RunDefaultCmd(ctx, "info", src.String())
 	if err != nil {
 		return nil, err
 	}
@@ -161,9 +202,9 @@ func (c *Con) ScanDrive(ctx context.Context, driveIndex int) (*LineIterator[*Dis
 	return iter, nil
 }
 
-// BackupTitle creates a backup of title titleIndex of drive driveIndex in
+// BackupTitle creates a backup of title titleIndex of the disc of src in
 // dstDir. The directory is created automatically if necessary.
-func (c *Con) BackupTitle(ctx context.Context, driveIndex, titleIndex int, dstDir string) (iter.Seq2[*Line, error], error) {
+func (c *Con) BackupTitle(ctx context.Context, src Source, titleIndex int, dstDir string) (iter.Seq2[*Line, error], error) {
 	if err := os.MkdirAll(dstDir, 0775); err != nil {
 		return nil, fmt.Errorf("make directory %q: %w", dstDir, err)
 	}
@@ -175,12 +216,32 @@ func (c *Con) BackupTitle(ctx context.Context, driveIndex, titleIndex int, dstDi
 		fmt.Sprintf("--cache=%d", c.cfg.ReadCacheSizeMB),
 		"--noscan",
 		"--progress=-same",
-		fmt.Sprintf("disc:%d", driveIndex),
+		src.String(),
 		strconv.Itoa(titleIndex),
 		dstDir,
 	)
 }
 
+// BackupDisc creates a decrypted backup of the disc of src in dstDir, i.e., a
+// copy of the BDMV or VIDEO_TS directory structure. The directory is created
+// automatically if necessary.
+func (c *Con) BackupDisc(ctx context.Context, src Source, dstDir string) (iter.Seq2[*Line, error], error) {
+	if err := os.MkdirAll(dstDir, 0775); err != nil {
+		return nil, fmt.Errorf("make directory %q: %w", dstDir, err)
+	}
+
+	return c.RunDefaultCmd(
+		ctx,
+		"backup",
+		"--decrypt",
+		fmt.Sprintf("--cache=%d", c.cfg.ReadCacheSizeMB),
+		"--noscan",
+		"--progress=-same",
+		src.String(),
+		dstDir,
+	)
+}
+
 // RunDefaultCmd calls RunCmd with default args in addition to the specified
 // args. Default args include -r (machine-readable output), --minlength, and
 // --profile.
@@ -190,7 +251,15 @@ func (c *Con) RunDefaultCmd(ctx context.Context, args ...string) (iter.Seq2[*Lin
 
 // RunCmd runs an arbitrary makemkvcon command with the given args. It
 // terminates when the context is canceled or the command terminates.
+//
+// The invocation is recorded if Config.RecordDir is set. If Config.ReplayDir
+// is set, a recorded transcript is replayed instead.
 func (c *Con) RunCmd(ctx context.Context, args ...string) (iter.Seq2[*Line, error], error) {
+	if c.replayer != nil {
+		slog.Debug("replaying command", "args", args)
+		return c.replayer.replay(args)
+	}
+
 	cmd := exec.CommandContext(ctx, c.cfg.ExePath, args...)
 	cmd.WaitDelay = time.Second
 
@@ -199,20 +268,45 @@ func (c *Con) RunCmd(ctx context.Context, args ...string) (iter.Seq2[*Line, erro
 		return nil, err
 	}
 
+	var (
+		r   io.Reader = stdout
+		rec *recording
+	)
+	if c.recorder != nil {
+		if rec, err = c.recorder.start(args); err != nil {
+			return nil, err
+		}
+		r = io.TeeReader(stdout, rec)
+	}
+
 	slog.Debug("running command", "cmd", cmd.String())
 	if err := cmd.Start(); err != nil {
+		if rec != nil {
+			err = errors.Join(err, rec.finish(err))
+		}
 		return nil, err
 	}
 
 	return func(yield func(*Line, error) bool) {
-		for line, err := range ParseLines(stdout) {
+		// The transcript is also written if the consumer stops early, in which
+		// case makemkvcon did not finish.
+		waitErr := errStopped
+		if rec != nil {
+			defer func() {
+				if err := rec.finish(waitErr); err != nil {
+					slog.Error("failed to record transcript", "err", err)
+				}
+			}()
+		}
+
+		for line, err := range ParseLines(r) {
 			if !yield(line, err) {
 				return
 			}
 		}
 
-		if err := cmd.Wait(); err != nil {
-			yield(nil, err)
+		if waitErr = cmd.Wait(); waitErr != nil {
+			yield(nil, waitErr)
 		}
 	}, nil
 }
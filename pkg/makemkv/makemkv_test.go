@@ -0,0 +1,300 @@
+package makemkv_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+)
+
+// fakeExePath is the path of the fakemakemkvcon test double built by TestMain.
+var fakeExePath string
+
+func TestMain(m *testing.M) {
+	os.Exit(func() int {
+		dir, err := os.MkdirTemp("", "fakemakemkvcon")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer os.RemoveAll(dir)
+
+		fakeExePath = filepath.Join(dir, "fakemakemkvcon")
+		if runtime.GOOS == "windows" {
+			fakeExePath += ".exe"
+		}
+
+		cmd := exec.Command("go", "build", "-o", fakeExePath, "./cmd/fakemakemkvcon")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "build fakemakemkvcon: %s\n%s", err, out)
+			return 1
+		}
+
+		return m.Run()
+	}())
+}
+
+// fakeScript mirrors the script format of fakemakemkvcon.
+type fakeScript struct {
+	Drives    []map[string]string `json:"drives"`
+	LineDelay string              `json:"lineDelay,omitempty"`
+	Failures  []map[string]any    `json:"failures,omitempty"`
+}
+
+// writeFakeScript writes the script used by fakemakemkvcon for the duration
+// of the test.
+func writeFakeScript(t *testing.T, s *fakeScript) {
+	t.Helper()
+
+	disc, err := filepath.Abs(filepath.Join("testdata", "fake", "disc.txt"))
+	require.NoError(t, err)
+	for _, d := range s.Drives {
+		if d["disc"] == "disc.txt" {
+			d["disc"] = disc
+		}
+	}
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "script.json")
+	require.NoError(t, os.WriteFile(path, b, 0600))
+	t.Setenv("FAKEMAKEMKVCON_SCRIPT", path)
+}
+
+// newFakeCon writes the script and returns a Con that runs fakemakemkvcon.
+func newFakeCon(t *testing.T, s *fakeScript, minLengthSeconds int64) *makemkv.Con {
+	t.Helper()
+
+	writeFakeScript(t, s)
+	con, err := makemkv.New(&makemkv.Config{
+		ExePath:          fakeExePath,
+		ReadCacheSizeMB:  1,
+		MinLengthSeconds: minLengthSeconds,
+	})
+	require.NoError(t, err)
+
+	return con
+}
+
+func defaultFakeScript() *fakeScript {
+	return &fakeScript{
+		Drives: []map[string]string{
+			{"name": "BD-RE Fake Drive", "discTitle": "A Fake Movie", "volume": "/dev/sr0", "disc": "disc.txt"},
+			{"name": "DVD Fake Drive", "volume": "/dev/sr1"},
+		},
+	}
+}
+
+func TestListDrives(t *testing.T) {
+	con := newFakeCon(t, defaultFakeScript(), 120)
+
+	iter, err := con.ListDrives(context.Background())
+	require.NoError(t, err)
+	for _, err := range iter.Seq {
+		require.NoError(t, err)
+	}
+
+	drives, err := iter.GetResult()
+	require.NoError(t, err)
+	require.Len(t, drives, 2)
+	assert.Equal(t, "BD-RE Fake Drive", drives[0].DriveName.String())
+	assert.Equal(t, "/dev/sr0", drives[0].VolumeName.String())
+	assert.Equal(t, 1, drives[1].Index)
+}
+
+func TestScanDrive(t *testing.T) {
+	for _, tc := range []struct {
+		minLength     int64
+		expectedNames []string
+	}{
+		{120, []string{"A_Fake_Movie_t00.mkv", "A_Fake_Movie_t01.mkv", "A_Fake_Movie_t02.mkv", "A_Fake_Movie_t03.mkv"}},
+		{1200, []string{"A_Fake_Movie_t00.mkv", "A_Fake_Movie_t01.mkv", "A_Fake_Movie_t03.mkv"}},
+		{1800, []string{"A_Fake_Movie_t00.mkv", "A_Fake_Movie_t01.mkv"}},
+	} {
+		t.Run(fmt.Sprintf("minlength=%d", tc.minLength), func(t *testing.T) {
+			con := newFakeCon(t, defaultFakeScript(), tc.minLength)
+
+			iter, err := con.ScanDrive(context.Background(), 0)
+			require.NoError(t, err)
+			for _, err := range iter.Seq {
+				require.NoError(t, err)
+			}
+
+			disc, err := iter.GetResult()
+			require.NoError(t, err)
+			assert.Equal(t, "A Fake Movie", disc.GetAttrDefault(defs.Name, ""))
+
+			var names []string
+			for i, title := range disc.Titles {
+				assert.Equal(t, i, title.Index)
+				names = append(names, title.GetAttrDefault(defs.OutputFileName, ""))
+			}
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestScanEmptyDrive(t *testing.T) {
+	con := newFakeCon(t, defaultFakeScript(), 120)
+
+	iter, err := con.ScanDrive(context.Background(), 1)
+	require.NoError(t, err)
+	for _, err := range iter.Seq {
+		require.NoError(t, err)
+	}
+
+	disc, err := iter.GetResult()
+	require.NoError(t, err)
+	assert.Equal(t, 0, disc.TitleCount())
+}
+
+func TestBackupTitle(t *testing.T) {
+	s := defaultFakeScript()
+	s.LineDelay = "1ms"
+	con := newFakeCon(t, s, 1200)
+
+	dir := filepath.Join(t.TempDir(), "out")
+	seq, err := con.BackupTitle(context.Background(), makemkv.DriveSource(0), 2, dir)
+	require.NoError(t, err)
+
+	var progress []float64
+	for line, err := range seq {
+		require.NoError(t, err)
+		if line.Progress != nil {
+			progress = append(progress, line.Progress.TaskProgress())
+		}
+	}
+
+	assert.NotEmpty(t, progress)
+	assert.InDelta(t, 1.0, progress[len(progress)-1], 0.0001)
+	assert.FileExists(t, filepath.Join(dir, "A_Fake_Movie_t03.mkv"))
+}
+
+func TestBackupDisc(t *testing.T) {
+	con := newFakeCon(t, defaultFakeScript(), 1200)
+
+	dir := filepath.Join(t.TempDir(), "out")
+	seq, err := con.BackupDisc(context.Background(), makemkv.DriveSource(0), dir)
+	require.NoError(t, err)
+
+	var (
+		progress []float64
+		messages []string
+	)
+	for line, err := range seq {
+		require.NoError(t, err)
+		switch {
+		case line.Progress != nil:
+			progress = append(progress, line.Progress.TaskProgress())
+		case line.Message != nil:
+			messages = append(messages, line.Message.Message.String())
+		}
+	}
+
+	assert.NotEmpty(t, progress)
+	assert.InDelta(t, 1.0, progress[len(progress)-1], 0.0001)
+	assert.Equal(t, []string{"Backup done"}, messages)
+	assert.FileExists(t, filepath.Join(dir, "BDMV", "index.bdmv"))
+}
+
+func TestScanFiles(t *testing.T) {
+	con := newFakeCon(t, defaultFakeScript(), 1200)
+
+	// A disc backup is a folder source.
+	dir := filepath.Join(t.TempDir(), "A Fake Movie")
+	seq, err := con.BackupDisc(context.Background(), makemkv.DriveSource(0), dir)
+	require.NoError(t, err)
+	for _, err := range seq {
+		require.NoError(t, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join("testdata", "fake", "disc.txt"))
+	require.NoError(t, err)
+	isoPath := filepath.Join(t.TempDir(), "A Fake Movie.iso")
+	require.NoError(t, os.WriteFile(isoPath, b, 0600))
+
+	for _, src := range []makemkv.Source{makemkv.FolderSource(dir), makemkv.ISOSource(isoPath)} {
+		t.Run(src.String(), func(t *testing.T) {
+			iter, err := con.Scan(context.Background(), src)
+			require.NoError(t, err)
+			for _, err := range iter.Seq {
+				require.NoError(t, err)
+			}
+
+			disc, err := iter.GetResult()
+			require.NoError(t, err)
+			assert.Equal(t, "A Fake Movie", disc.GetAttrDefault(defs.Name, ""))
+			assert.Equal(t, 3, disc.TitleCount())
+		})
+	}
+}
+
+func TestBackupTitleFailure(t *testing.T) {
+	s := defaultFakeScript()
+	s.Failures = []map[string]any{
+		{"command": "mkv", "source": "disc:0", "afterLines": 3, "exitCode": 2, "message": "Read error"},
+	}
+	con := newFakeCon(t, s, 120)
+
+	dir := t.TempDir()
+	seq, err := con.BackupTitle(context.Background(), makemkv.DriveSource(0), 0, dir)
+	require.NoError(t, err)
+
+	var (
+		messages []string
+		errs     []error
+	)
+	for line, err := range seq {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if line.Message != nil {
+			messages = append(messages, line.Message.Message.String())
+		}
+	}
+
+	assert.Equal(t, []string{"Read error"}, messages)
+	require.Len(t, errs, 1)
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, errs[0], &exitErr)
+	assert.Equal(t, 2, exitErr.ExitCode())
+	assert.NoFileExists(t, filepath.Join(dir, "A_Fake_Movie_t00.mkv"))
+}
+
+func TestFingerprint(t *testing.T) {
+	scan := func(minLength int64) *makemkv.Disc {
+		disc, errs := scanDisc(t, newFakeCon(t, defaultFakeScript(), minLength))
+		require.Empty(t, errs)
+		return disc
+	}
+
+	// The fingerprint does not depend on --minlength, e.g., in TV mode.
+	a := scan(1800)
+	assert.Len(t, a.Fingerprint(), 64)
+	for _, minLength := range []int64{1800, 1200, 600, 120} {
+		assert.Equal(t, a.Fingerprint(), scan(minLength).Fingerprint(), "minlength=%d", minLength)
+	}
+
+	// Discs with only short titles are told apart by them.
+	short := func(durations ...string) *makemkv.Disc {
+		disc := &makemkv.Disc{}
+		for i, duration := range durations {
+			disc.GetTitle(i).Info = makemkv.Info{{ID: int(defs.Duration), Value: makemkv.Str(duration)}}
+		}
+		return disc
+	}
+	assert.Equal(t, short("0:22:00", "0:23:00").Fingerprint(), short("0:22:00", "0:23:00").Fingerprint())
+	assert.NotEqual(t, short("0:22:00", "0:23:00").Fingerprint(), short("0:22:00", "0:24:00").Fingerprint())
+	assert.NotEqual(t, a.Fingerprint(), short().Fingerprint())
+}
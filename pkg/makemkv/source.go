@@ -0,0 +1,115 @@
+package makemkv
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SourceType is the type of a Source. Its value is the makemkvcon source
+// prefix.
+type SourceType string
+
+const (
+	SourceTypeDrive  SourceType = "disc"
+	SourceTypeISO    SourceType = "iso"
+	SourceTypeFolder SourceType = "file"
+)
+
+// DiscFolderNames are the top-level folders of Blu-ray and DVD file
+// structures. A disc folder contains one of them.
+var DiscFolderNames = []string{"BDMV", "VIDEO_TS"}
+
+// Source identifies a disc for makemkvcon: the disc in a drive, an ISO image or
+// a folder that contains the file structure of a disc, e.g., a backup made by
+// BackupDisc.
+type Source struct {
+	Type SourceType
+
+	// DriveIndex is the index of the drive, as reported by ListDrives, if
+	// Type is SourceTypeDrive.
+	DriveIndex int
+
+	// Path is the path of the ISO image or folder otherwise.
+	Path string
+}
+
+// DriveSource returns the source for the disc in the drive with the given
+// index.
+func DriveSource(driveIndex int) Source {
+	return Source{Type: SourceTypeDrive, DriveIndex: driveIndex}
+}
+
+// ISOSource returns the source for the ISO image at path.
+func ISOSource(path string) Source {
+	return Source{Type: SourceTypeISO, Path: path}
+}
+
+// FolderSource returns the source for the disc folder at path, which contains
+// a BDMV or VIDEO_TS folder.
+func FolderSource(path string) Source {
+	return Source{Type: SourceTypeFolder, Path: path}
+}
+
+// String returns the source as a makemkvcon argument, e.g., "disc:0" or
+// "iso:/path/to/movie.iso".
+func (s Source) String() string {
+	if s.Type == SourceTypeDrive {
+		return fmt.Sprintf("%s:%d", s.Type, s.DriveIndex)
+	}
+
+	return fmt.Sprintf("%s:%s", s.Type, s.Path)
+}
+
+// FindSources returns the ISO images and disc folders at or below root. The
+// contents of disc folders are not searched.
+func FindSources(root string) ([]Source, error) {
+	var sources []Source
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			if strings.EqualFold(filepath.Ext(path), ".iso") {
+				sources = append(sources, ISOSource(path))
+			}
+			return nil
+		}
+
+		// A disc structure folder itself stands for its parent.
+		if slices.Contains(DiscFolderNames, d.Name()) {
+			if path == root {
+				sources = append(sources, FolderSource(filepath.Dir(path)))
+			}
+			return filepath.SkipDir
+		}
+
+		if IsDiscFolder(path) {
+			sources = append(sources, FolderSource(path))
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %q: %w", root, err)
+	}
+
+	return sources, nil
+}
+
+// IsDiscFolder returns true if the folder at path contains a BDMV or VIDEO_TS
+// folder.
+func IsDiscFolder(path string) bool {
+	for _, name := range DiscFolderNames {
+		if fi, err := os.Stat(filepath.Join(path, name)); err == nil && fi.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
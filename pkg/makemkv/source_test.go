@@ -0,0 +1,54 @@
+package makemkv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+)
+
+func TestSourceString(t *testing.T) {
+	assert.Equal(t, "disc:1", makemkv.DriveSource(1).String())
+	assert.Equal(t, "iso:/movies/A.iso", makemkv.ISOSource("/movies/A.iso").String())
+	assert.Equal(t, "file:/movies/B", makemkv.FolderSource("/movies/B").String())
+}
+
+func TestFindSources(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{
+		"A.iso",
+		"nested/B.ISO",
+		"nested/notes.txt",
+		"C/BDMV/index.bdmv",
+		"C/BDMV/STREAM/00001.m2ts",
+		"D/VIDEO_TS/VIDEO_TS.IFO",
+		"E/extra.txt",
+	} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0600))
+	}
+
+	sources, err := makemkv.FindSources(root)
+	require.NoError(t, err)
+	assert.Equal(t, []makemkv.Source{
+		makemkv.ISOSource(filepath.Join(root, "A.iso")),
+		makemkv.FolderSource(filepath.Join(root, "C")),
+		makemkv.FolderSource(filepath.Join(root, "D")),
+		makemkv.ISOSource(filepath.Join(root, "nested", "B.ISO")),
+	}, sources)
+
+	// The path of a single disc may be given, too.
+	for _, path := range []string{"C", "C/BDMV", "A.iso"} {
+		sources, err := makemkv.FindSources(filepath.Join(root, filepath.FromSlash(path)))
+		require.NoError(t, err)
+		assert.Len(t, sources, 1, path)
+	}
+
+	_, err = makemkv.FindSources(filepath.Join(root, "missing"))
+	require.Error(t, err)
+}
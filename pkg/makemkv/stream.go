@@ -8,9 +8,9 @@ import (
 // Streams.
 type Stream struct {
 	// Index is the index given by makemkv.
-	Index int
+	Index int `json:"index"`
 
-	Info
+	Info `json:"info"`
 }
 
 // Type returns the type code of the stream.
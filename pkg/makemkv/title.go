@@ -7,11 +7,11 @@ package makemkv
 type Title struct {
 	// Index is the index given by makemkv. Title numbers appear to be
 	// deterministic if makemkv is run with the same --minlength argument.
-	Index int
+	Index int `json:"index"`
 
-	Info
+	Info `json:"info"`
 
-	Streams []*Stream
+	Streams []*Stream `json:"streams"`
 }
 
 // GetStream returns the stream with the given index, creating it (and all
@@ -0,0 +1,314 @@
+package makemkv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcript describes a recorded makemkvcon invocation. It is stored as a
+// JSON file next to a text file containing the raw standard output, which can
+// be parsed with ParseFile.
+type Transcript struct {
+	// Args are the arguments that were passed to makemkvcon.
+	Args []string `json:"args"`
+
+	// StdoutFileName is the name of the file containing the standard output.
+	// It is relative to the directory of the transcript.
+	StdoutFileName string `json:"stdoutFileName"`
+
+	// ExitCode is the exit code of makemkvcon, or -1 if it did not exit
+	// normally.
+	ExitCode int `json:"exitCode"`
+
+	// StartTime is the time at which makemkvcon was started.
+	StartTime time.Time `json:"startTime"`
+
+	// Duration is how long makemkvcon ran.
+	Duration time.Duration `json:"duration"`
+
+	// OutputFiles are the paths of the files that makemkvcon wrote to the
+	// destination directory of an "mkv" or "backup" command, relative to it.
+	// They are replayed as empty placeholder files.
+	OutputFiles []string `json:"outputFiles,omitempty"`
+}
+
+// positionalArgs returns the args that are not options.
+func positionalArgs(args []string) []string {
+	var positional []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+		}
+	}
+
+	return positional
+}
+
+// outputDir returns the destination directory of an "mkv" or "backup"
+// command, which is the last positional argument, or "" for other commands.
+func outputDir(args []string) string {
+	positional := positionalArgs(args)
+	if len(positional) > 2 {
+		switch positional[0] {
+		case "mkv", "backup":
+			return positional[len(positional)-1]
+		}
+	}
+
+	return ""
+}
+
+// transcriptKey returns the part of args that identifies an invocation for
+// replay purposes: the command, source, and title, but not options or the
+// destination directory, which may differ between machines.
+func transcriptKey(args []string) string {
+	positional := positionalArgs(args)
+	if outputDir(args) != "" {
+		positional = positional[:len(positional)-1]
+	}
+
+	return strings.Join(positional, " ")
+}
+
+// recorder writes transcripts to a directory.
+type recorder struct {
+	dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func newRecorder(dir string) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, fmt.Errorf("make directory %q: %w", dir, err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &recorder{
+		dir: dir,
+		seq: len(paths),
+	}, nil
+}
+
+// start begins recording an invocation with the given args. Standard output
+// must be written to the returned recording, which must be finished when the
+// command terminates.
+func (r *recorder) start(args []string) (*recording, error) {
+	// The key may contain a long path, so it is hashed. The transcript has
+	// the args anyway.
+	key := transcriptKey(args)
+	command, _, _ := strings.Cut(key, " ")
+	h := sha256.Sum256([]byte(key))
+
+	r.mu.Lock()
+	r.seq++
+	name := fmt.Sprintf("%06d-%s-%s", r.seq, command, hex.EncodeToString(h[:4]))
+	r.mu.Unlock()
+
+	f, err := os.Create(filepath.Join(r.dir, name+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("create transcript: %w", err)
+	}
+
+	return &recording{
+		File: f,
+		path: filepath.Join(r.dir, name+".json"),
+		transcript: &Transcript{
+			Args:           args,
+			StdoutFileName: name + ".txt",
+			StartTime:      time.Now(),
+		},
+	}, nil
+}
+
+// recording is an in-progress transcript.
+type recording struct {
+	*os.File
+
+	path       string
+	transcript *Transcript
+}
+
+// finish closes the standard output file and writes the transcript. waitErr
+// is the result of waiting for the command.
+func (r *recording) finish(waitErr error) error {
+	t := r.transcript
+	t.Duration = time.Since(t.StartTime)
+
+	var exitErr *exec.ExitError
+	switch {
+	case waitErr == nil:
+	case errors.As(waitErr, &exitErr):
+		t.ExitCode = exitErr.ExitCode()
+	default:
+		t.ExitCode = -1
+	}
+
+	if dir := outputDir(t.Args); dir != "" {
+		var err error
+		if t.OutputFiles, err = findOutputFiles(dir, t.StartTime); err != nil {
+			return errors.Join(err, r.Close())
+		}
+	}
+
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return errors.Join(err, r.Close())
+	}
+
+	return errors.Join(r.Close(), os.WriteFile(r.path, b, 0644))
+}
+
+// findOutputFiles returns the paths, relative to dir, of the files in dir that
+// were modified since start.
+func findOutputFiles(dir string, start time.Time) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(start) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find output files in %q: %w", dir, err)
+	}
+
+	return paths, nil
+}
+
+// createPlaceholders creates empty files at the given paths relative to dir,
+// in place of the files written by a replayed command.
+func createPlaceholders(dir string, paths []string) error {
+	for _, path := range paths {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+			return fmt.Errorf("make directory for %q: %w", path, err)
+		}
+
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			return fmt.Errorf("create placeholder %q: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// replayer serves transcripts from a directory instead of running makemkvcon.
+type replayer struct {
+	dir string
+
+	mu          sync.Mutex
+	transcripts []*Transcript
+}
+
+func newReplayer(dir string) (*replayer, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no transcripts found in %q", dir)
+	}
+
+	slices.Sort(paths)
+	transcripts := make([]*Transcript, len(paths))
+	for i, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %q: %w", path, err)
+		}
+
+		transcripts[i] = &Transcript{}
+		if err := json.Unmarshal(b, transcripts[i]); err != nil {
+			return nil, fmt.Errorf("parse %q: %w", path, err)
+		}
+	}
+
+	return &replayer{
+		dir:         dir,
+		transcripts: transcripts,
+	}, nil
+}
+
+// next removes and returns the first transcript that matches args.
+func (r *replayer) next(args []string) (*Transcript, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := transcriptKey(args)
+	for i, t := range r.transcripts {
+		if transcriptKey(t.Args) == key {
+			r.transcripts = slices.Delete(r.transcripts, i, i+1)
+			return t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w for %q", ErrNoTranscript, key)
+}
+
+// replay returns the lines of the next transcript that matches args, followed
+// by an error if makemkvcon exited abnormally. The files that the command wrote
+// are created as empty placeholders in the destination directory of args.
+func (r *replayer) replay(args []string) (iter.Seq2[*Line, error], error) {
+	t, err := r.next(args)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(r.dir, t.StdoutFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(*Line, error) bool) {
+		defer f.Close()
+
+		for line, err := range ParseLines(f) {
+			if !yield(line, err) {
+				return
+			}
+		}
+
+		if err := createPlaceholders(outputDir(args), t.OutputFiles); err != nil {
+			if !yield(nil, err) {
+				return
+			}
+		}
+
+		if t.ExitCode != 0 {
+			yield(nil, fmt.Errorf("exit status %d", t.ExitCode))
+		}
+	}, nil
+}
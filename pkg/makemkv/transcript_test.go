@@ -0,0 +1,204 @@
+package makemkv_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+)
+
+func scanDisc(t *testing.T, con *makemkv.Con) (*makemkv.Disc, []error) {
+	t.Helper()
+
+	iter, err := con.ScanDrive(context.Background(), 0)
+	require.NoError(t, err)
+
+	var errs []error
+	for _, err := range iter.Seq {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	disc, err := iter.GetResult()
+	require.NoError(t, err)
+
+	return disc, errs
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	s := defaultFakeScript()
+	s.Failures = []map[string]any{
+		{"command": "mkv", "afterLines": 2, "exitCode": 3},
+	}
+	writeFakeScript(t, s)
+
+	dir := t.TempDir()
+	recordCon, err := makemkv.New(&makemkv.Config{
+		ExePath:          fakeExePath,
+		ReadCacheSizeMB:  1,
+		MinLengthSeconds: 1200,
+		RecordDir:        dir,
+	})
+	require.NoError(t, err)
+
+	recorded, errs := scanDisc(t, recordCon)
+	require.Empty(t, errs)
+
+	seq, err := recordCon.BackupTitle(context.Background(), makemkv.DriveSource(0), 1, t.TempDir())
+	require.NoError(t, err)
+	for range seq {
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+
+	// The standard output of each invocation is parseable on its own. The
+	// files are named after the sequence number and the command.
+	paths, err = filepath.Glob(filepath.Join(dir, "000001-info-*.txt"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	assert.Len(t, filepath.Base(paths[0]), len("000001-info-01234567.txt"))
+	output, err := makemkv.ParseFile(paths[0])
+	require.NoError(t, err)
+	assert.NotEmpty(t, output.Lines)
+
+	replayCon, err := makemkv.New(&makemkv.Config{
+		ExePath:          filepath.Join(t.TempDir(), "does-not-exist"),
+		ReadCacheSizeMB:  1,
+		MinLengthSeconds: 1,
+		ReplayDir:        dir,
+	})
+	require.NoError(t, err)
+
+	// The backup is replayed regardless of the destination directory.
+	seq, err = replayCon.BackupTitle(context.Background(), makemkv.DriveSource(0), 1, t.TempDir())
+	require.NoError(t, err)
+	var lastErr error
+	for _, err := range seq {
+		if err != nil {
+			lastErr = err
+		}
+	}
+	assert.EqualError(t, lastErr, "exit status 3")
+
+	replayed, errs := scanDisc(t, replayCon)
+	require.Empty(t, errs)
+	require.Equal(t, recorded.TitleCount(), replayed.TitleCount())
+	for i := range recorded.Titles {
+		assert.Len(t, replayed.Titles[i].Info, len(recorded.Titles[i].Info))
+		assert.Len(t, replayed.Titles[i].Streams, len(recorded.Titles[i].Streams))
+	}
+
+	// Each transcript is replayed once.
+	_, err = replayCon.ScanDrive(context.Background(), 0)
+	require.ErrorIs(t, err, makemkv.ErrNoTranscript)
+	require.ErrorIs(t, err, makemkv.ErrNotFound)
+}
+
+func TestReplayCreatesPlaceholders(t *testing.T) {
+	writeFakeScript(t, defaultFakeScript())
+
+	dir := t.TempDir()
+	recordCon, err := makemkv.New(&makemkv.Config{
+		ExePath:          fakeExePath,
+		ReadCacheSizeMB:  1,
+		MinLengthSeconds: 1200,
+		RecordDir:        dir,
+	})
+	require.NoError(t, err)
+
+	seq, err := recordCon.BackupTitle(context.Background(), makemkv.DriveSource(0), 1, t.TempDir())
+	require.NoError(t, err)
+	for _, err := range seq {
+		require.NoError(t, err)
+	}
+
+	replayCon, err := makemkv.New(&makemkv.Config{
+		ReadCacheSizeMB:  1,
+		MinLengthSeconds: 1200,
+		ReplayDir:        dir,
+	})
+	require.NoError(t, err)
+	assert.True(t, replayCon.Replaying())
+	assert.False(t, recordCon.Replaying())
+
+	dstDir := t.TempDir()
+	seq, err = replayCon.BackupTitle(context.Background(), makemkv.DriveSource(0), 1, dstDir)
+	require.NoError(t, err)
+	for _, err := range seq {
+		require.NoError(t, err)
+	}
+
+	fi, err := os.Stat(filepath.Join(dstDir, "A_Fake_Movie_t01.mkv"))
+	require.NoError(t, err)
+	assert.Zero(t, fi.Size())
+}
+
+func TestReplayDirWithoutTranscripts(t *testing.T) {
+	_, err := makemkv.New(&makemkv.Config{
+		ReadCacheSizeMB:  1,
+		MinLengthSeconds: 1,
+		ReplayDir:        t.TempDir(),
+	})
+	require.Error(t, err)
+}
+
+func TestRecordDirIsCreated(t *testing.T) {
+	writeFakeScript(t, defaultFakeScript())
+
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	con, err := makemkv.New(&makemkv.Config{
+		ExePath:          fakeExePath,
+		ReadCacheSizeMB:  1,
+		MinLengthSeconds: 1,
+		RecordDir:        dir,
+	})
+	require.NoError(t, err)
+	assert.DirExists(t, dir)
+
+	_, errs := scanDisc(t, con)
+	require.Empty(t, errs)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "000001-info-*.json"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	b, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"exitCode": 0`)
+}
+
+func TestRecordStoppedEarly(t *testing.T) {
+	writeFakeScript(t, defaultFakeScript())
+
+	dir := t.TempDir()
+	con, err := makemkv.New(&makemkv.Config{
+		ExePath:          fakeExePath,
+		ReadCacheSizeMB:  1,
+		MinLengthSeconds: 1,
+		RecordDir:        dir,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	iter, err := con.ScanDrive(ctx, 0)
+	require.NoError(t, err)
+	for range iter.Seq {
+		break
+	}
+
+	// The transcript is written although makemkvcon did not finish.
+	paths, err := filepath.Glob(filepath.Join(dir, "000001-info-*.json"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	b, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"exitCode": -1`)
+}
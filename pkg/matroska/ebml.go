@@ -0,0 +1,271 @@
+package matroska
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// The IDs of the elements that are read or written.
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+
+	idSegment = 0x18538067
+	idCluster = 0x1F43B675
+	idVoid    = 0xEC
+
+	idSeekHead     = 0x114D9B74
+	idSeek         = 0x4DBB
+	idSeekID       = 0x53AB
+	idSeekPosition = 0x53AC
+
+	idInfo           = 0x1549A966
+	idTimestampScale = 0x2AD7B1
+	idDuration       = 0x4489
+	idTitle          = 0x7BA9
+	idMuxingApp      = 0x4D80
+	idWritingApp     = 0x5741
+
+	idTracks      = 0x1654AE6B
+	idTrackEntry  = 0xAE
+	idTrackNumber = 0xD7
+	idTrackUID    = 0x73C5
+	idTrackType   = 0x83
+	idCodecID     = 0x86
+	idName        = 0x536E
+	idLanguage    = 0x22B59C
+
+	idTags             = 0x1254C367
+	idTag              = 0x7373
+	idTargets          = 0x63C0
+	idTargetTypeValue  = 0x68CA
+	idTagTrackUID      = 0x63C5
+	idTagEditionUID    = 0x63C9
+	idTagChapterUID    = 0x63C4
+	idTagAttachmentUID = 0x63C6
+	idSimpleTag        = 0x67C8
+	idTagName          = 0x45A3
+	idTagString        = 0x4487
+)
+
+// unknownSize is the size of an element whose size is not known, e.g., a
+// Segment that is still being written.
+const unknownSize = -1
+
+// elementHeader is the ID and the size of the data of an element.
+type elementHeader struct {
+	id   uint32
+	size int64
+
+	// len is the length of the header in bytes.
+	len int64
+}
+
+// readElementHeader reads the header of the next element.
+func readElementHeader(r io.Reader) (*elementHeader, error) {
+	id, idLen, err := readVint(r, 4, true)
+	if err != nil {
+		return nil, fmt.Errorf("read element ID: %w", err)
+	}
+
+	size, sizeLen, err := readVint(r, 8, false)
+	if err != nil {
+		return nil, fmt.Errorf("read size of element %#x: %w", id, err)
+	}
+
+	h := &elementHeader{
+		id:   uint32(id),
+		size: int64(size),
+		len:  int64(idLen + sizeLen),
+	}
+
+	// A size with all value bits set means unknown.
+	if size == 1<<(7*sizeLen)-1 {
+		h.size = unknownSize
+	}
+
+	return h, nil
+}
+
+// readVint reads a variable-length integer of at most maxLen bytes. The
+// length marker is kept for IDs and removed for sizes.
+func readVint(r io.Reader, maxLen int, keepMarker bool) (uint64, int, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return 0, 0, err
+	}
+
+	n := bits.LeadingZeros8(b[0]) + 1
+	if n > maxLen {
+		return 0, 0, fmt.Errorf("invalid variable-length integer starting with %#x", b[0])
+	}
+
+	if _, err := io.ReadFull(r, b[1:n]); err != nil {
+		return 0, 0, noEOF(err)
+	}
+
+	v := uint64(b[0])
+	if !keepMarker {
+		v &= 0xFF >> n
+	}
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+
+	return v, n, nil
+}
+
+// readData reads the data of an element that is not a master element.
+func readData(r io.Reader, h *elementHeader) ([]byte, error) {
+	// The values of interest are small. Anything bigger is not a valid file.
+	if h.size < 0 || h.size > 1<<20 {
+		return nil, fmt.Errorf("invalid size %d of element %#x", h.size, h.id)
+	}
+
+	b := make([]byte, h.size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("read element %#x: %w", h.id, noEOF(err))
+	}
+
+	return b, nil
+}
+
+func decodeUint(b []byte) (uint64, error) {
+	if len(b) > 8 {
+		return 0, fmt.Errorf("invalid unsigned integer length %d", len(b))
+	}
+
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return v, nil
+}
+
+func decodeFloat(b []byte) (float64, error) {
+	switch len(b) {
+	case 0:
+		return 0, nil
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	default:
+		return 0, fmt.Errorf("invalid float length %d", len(b))
+	}
+}
+
+// decodeString decodes a string, which may be padded with zeros.
+func decodeString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+
+	return string(b)
+}
+
+// noEOF turns io.EOF into io.ErrUnexpectedEOF, for reads that cannot end the
+// file.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// encodeID encodes an element ID, which includes its length marker.
+func encodeID(id uint32) []byte {
+	n := 4 - bits.LeadingZeros32(id)/8
+	b := make([]byte, n)
+	for i := range n {
+		b[i] = byte(id >> (8 * (n - 1 - i)))
+	}
+
+	return b
+}
+
+// encodeSize encodes the size of an element in n bytes, or in as few bytes as
+// possible if n is 0.
+func encodeSize(size uint64, n int) []byte {
+	if n == 0 {
+		n = 1
+		// All value bits set would mean unknown size.
+		for size >= 1<<(7*n)-1 {
+			n++
+		}
+	}
+
+	b := make([]byte, n)
+	for i := range n {
+		b[i] = byte(size >> (8 * (n - 1 - i)))
+	}
+	b[0] |= 0x80 >> (n - 1)
+
+	return b
+}
+
+// element encodes an element with the given data.
+func element(id uint32, data []byte) []byte {
+	b := encodeID(id)
+	b = append(b, encodeSize(uint64(len(data)), 0)...)
+	return append(b, data...)
+}
+
+// master encodes a master element with the given children.
+func master(id uint32, children ...[]byte) []byte {
+	var data []byte
+	for _, child := range children {
+		data = append(data, child...)
+	}
+
+	return element(id, data)
+}
+
+func uintElement(id uint32, v uint64) []byte {
+	n := max(1, 8-bits.LeadingZeros64(v)/8)
+	b := make([]byte, n)
+	for i := range n {
+		b[i] = byte(v >> (8 * (n - 1 - i)))
+	}
+
+	return element(id, b)
+}
+
+func floatElement(id uint32, v float64) []byte {
+	return element(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+func stringElement(id uint32, s string) []byte {
+	return element(id, []byte(s))
+}
+
+// voidElement encodes a Void element that is n bytes long in total, to fill
+// the space left by an element that was moved or shrunk. n must be at least
+// 2.
+func voidElement(n int64) []byte {
+	sizeLen := int64(1)
+	// All value bits set would mean unknown size.
+	for n-1-sizeLen >= 1<<(7*sizeLen)-1 {
+		sizeLen++
+	}
+
+	b := make([]byte, n)
+	b[0] = idVoid
+	copy(b[1:], encodeSize(uint64(n-1-sizeLen), int(sizeLen)))
+
+	return b
+}
@@ -0,0 +1,338 @@
+// Package matroska reads the headers of Matroska (MKV) files, e.g., to check
+// that a ripped title has the expected tracks and duration, and writes small
+// Matroska files for tests.
+//
+// Only the EBML header, the segment information and the tracks are read. The
+// media data in the clusters is skipped.
+package matroska
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// TrackType is the type of a track.
+type TrackType int
+
+const (
+	TrackTypeVideo    TrackType = 1
+	TrackTypeAudio    TrackType = 2
+	TrackTypeComplex  TrackType = 3
+	TrackTypeLogo     TrackType = 0x10
+	TrackTypeSubtitle TrackType = 0x11
+	TrackTypeButtons  TrackType = 0x12
+	TrackTypeControl  TrackType = 0x20
+	TrackTypeMetadata TrackType = 0x21
+)
+
+func (t TrackType) String() string {
+	switch t {
+	case TrackTypeVideo:
+		return "video"
+	case TrackTypeAudio:
+		return "audio"
+	case TrackTypeComplex:
+		return "complex"
+	case TrackTypeLogo:
+		return "logo"
+	case TrackTypeSubtitle:
+		return "subtitle"
+	case TrackTypeButtons:
+		return "buttons"
+	case TrackTypeControl:
+		return "control"
+	case TrackTypeMetadata:
+		return "metadata"
+	default:
+		return fmt.Sprintf("TrackType(%d)", int(t))
+	}
+}
+
+// ErrNotMatroska is returned for files that do not start with an EBML header
+// of a Matroska or WebM document.
+var ErrNotMatroska = errors.New("not a Matroska file")
+
+// defaultTimestampScale is the number of nanoseconds of a timestamp unless the
+// segment information says otherwise.
+const defaultTimestampScale = 1000000
+
+// File is the header of a Matroska file.
+type File struct {
+	// DocType is "matroska" or "webm".
+	DocType string
+
+	// Title is the title of the segment, if any.
+	Title string
+
+	// MuxingApp and WritingApp name the programs that wrote the file.
+	MuxingApp  string
+	WritingApp string
+
+	Duration time.Duration
+
+	Tracks []*Track
+
+	// Tags are the global tags, which describe the whole file.
+	Tags []*Tag
+}
+
+// Track is an entry of the Tracks element.
+type Track struct {
+	Number   uint64
+	Type     TrackType
+	CodecID  string
+	Name     string
+	Language string
+}
+
+// CountTracks returns the number of tracks of the given type.
+func (f *File) CountTracks(t TrackType) int {
+	var n int
+	for _, track := range f.Tracks {
+		if track.Type == t {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ReadFile reads the header of the Matroska file at path.
+func ReadFile(path string) (*File, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", path, err)
+	}
+	defer fp.Close()
+
+	f, err := Read(fp)
+	if err != nil {
+		return nil, fmt.Errorf("read %q: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// Read reads the header of a Matroska file: the EBML header and the segment
+// information and tracks, which must precede the first cluster, and the
+// global tags.
+func Read(r io.ReadSeeker) (*File, error) {
+	s, err := readSegment(r)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{DocType: s.docType}
+	var haveInfo, haveTracks bool
+	for _, c := range s.children {
+		if c.id != idInfo && c.id != idTracks {
+			continue
+		}
+
+		if err := seekTo(r, c); err != nil {
+			return nil, err
+		}
+
+		if c.id == idInfo {
+			haveInfo = true
+			err = readInfo(r, c.elementHeader, f)
+		} else {
+			haveTracks = true
+			err = readTracks(r, c.elementHeader, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case !haveInfo:
+		return nil, errors.New("segment information not found")
+	case !haveTracks:
+		return nil, errors.New("tracks not found")
+	}
+
+	for _, c := range s.tags {
+		if err := seekTo(r, c); err != nil {
+			return nil, err
+		}
+
+		tags, _, err := readTags(r, c.elementHeader)
+		if err != nil {
+			return nil, err
+		}
+		f.Tags = mergeTags(f.Tags, tags)
+	}
+
+	return f, nil
+}
+
+func readInfo(r io.ReadSeeker, h *elementHeader, f *File) error {
+	scale := uint64(defaultTimestampScale)
+	var duration float64
+	err := readChildren(r, h, 0, func(h *elementHeader) error {
+		var err error
+		switch h.id {
+		case idTimestampScale:
+			scale, err = readUint(r, h)
+		case idDuration:
+			duration, err = readFloat(r, h)
+		case idTitle:
+			f.Title, err = readString(r, h)
+		case idMuxingApp:
+			f.MuxingApp, err = readString(r, h)
+		case idWritingApp:
+			f.WritingApp, err = readString(r, h)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("read segment information: %w", err)
+	}
+
+	f.Duration = time.Duration(duration * float64(scale))
+	return nil
+}
+
+func readTracks(r io.ReadSeeker, h *elementHeader, f *File) error {
+	err := readChildren(r, h, 0, func(h *elementHeader) error {
+		if h.id != idTrackEntry {
+			return nil
+		}
+
+		track := &Track{Language: "eng"}
+		f.Tracks = append(f.Tracks, track)
+		return readChildren(r, h, 0, func(h *elementHeader) error {
+			var (
+				v   uint64
+				err error
+			)
+			switch h.id {
+			case idTrackNumber:
+				track.Number, err = readUint(r, h)
+			case idTrackType:
+				v, err = readUint(r, h)
+				track.Type = TrackType(v)
+			case idCodecID:
+				track.CodecID, err = readString(r, h)
+			case idName:
+				track.Name, err = readString(r, h)
+			case idLanguage:
+				track.Language, err = readString(r, h)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("read tracks: %w", err)
+	}
+
+	return nil
+}
+
+func readUint(r io.Reader, h *elementHeader) (uint64, error) {
+	b, err := readData(r, h)
+	if err != nil {
+		return 0, err
+	}
+
+	return decodeUint(b)
+}
+
+func readFloat(r io.Reader, h *elementHeader) (float64, error) {
+	b, err := readData(r, h)
+	if err != nil {
+		return 0, err
+	}
+
+	return decodeFloat(b)
+}
+
+func readString(r io.Reader, h *elementHeader) (string, error) {
+	b, err := readData(r, h)
+	return decodeString(b), err
+}
+
+// writePadding is the size of the Void element that Write puts after the seek
+// head.
+const writePadding = 1024
+
+// Write writes a Matroska file that consists of the header described by f
+// and no media data. The track numbers are used as their UIDs.
+func Write(w io.Writer, f *File) error {
+	docType := f.DocType
+	if docType == "" {
+		docType = "matroska"
+	}
+
+	header := master(idEBML,
+		uintElement(idEBMLVersion, 1),
+		uintElement(idEBMLReadVersion, 1),
+		uintElement(idEBMLMaxIDLength, 4),
+		uintElement(idEBMLMaxSizeLength, 8),
+		stringElement(idDocType, docType),
+		uintElement(idDocTypeVersion, 4),
+		uintElement(idDocTypeReadVersion, 2),
+	)
+
+	info := [][]byte{
+		uintElement(idTimestampScale, defaultTimestampScale),
+		floatElement(idDuration, float64(f.Duration)/defaultTimestampScale),
+		stringElement(idMuxingApp, f.MuxingApp),
+		stringElement(idWritingApp, f.WritingApp),
+	}
+	if f.Title != "" {
+		info = append(info, stringElement(idTitle, f.Title))
+	}
+
+	var entries [][]byte
+	for _, track := range f.Tracks {
+		entry := [][]byte{
+			uintElement(idTrackNumber, track.Number),
+			uintElement(idTrackUID, track.Number),
+			uintElement(idTrackType, uint64(track.Type)),
+			stringElement(idCodecID, track.CodecID),
+		}
+		if track.Name != "" {
+			entry = append(entry, stringElement(idName, track.Name))
+		}
+		if track.Language != "" {
+			entry = append(entry, stringElement(idLanguage, track.Language))
+		}
+		entries = append(entries, master(idTrackEntry, entry...))
+	}
+
+	elements := [][]byte{
+		master(idInfo, info...),
+		master(idTracks, entries...),
+	}
+	seeks := []*seekEntry{{id: idInfo}, {id: idTracks}}
+	if tags := encodeTags(f.Tags, nil); tags != nil {
+		elements = append(elements, tags)
+		seeks = append(seeks, &seekEntry{id: idTags})
+	}
+
+	// Like mkvmerge, leave room after the seek head for elements that are
+	// added later, e.g., tags.
+	pos := uint64(len(encodeSeekHead(seeks)) + writePadding)
+	for i, seek := range seeks {
+		seek.pos = pos
+		pos += uint64(len(elements[i]))
+	}
+	segment := bytes.Join(append([][]byte{encodeSeekHead(seeks), voidElement(writePadding)}, elements...), nil)
+
+	// Like most muxers, use 8 bytes for the size of the segment, which can then
+	// be updated in place.
+	b := bytes.Join([][]byte{
+		header,
+		encodeID(idSegment),
+		encodeSize(uint64(len(segment)), 8),
+		segment,
+	}, nil)
+
+	_, err := w.Write(b)
+	return err
+}
@@ -0,0 +1,155 @@
+package matroska_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/matroska"
+)
+
+var testFile = &matroska.File{
+	DocType:    "matroska",
+	Title:      "A Fake Movie",
+	MuxingApp:  "libmakemkv v1.17.7",
+	WritingApp: "MakeMKV v1.17.7",
+	Duration:   time.Hour + 52*time.Minute + 10*time.Second,
+	Tracks: []*matroska.Track{
+		{Number: 1, Type: matroska.TrackTypeVideo, CodecID: "V_MPEG4/ISO/AVC", Language: "eng"},
+		{Number: 2, Type: matroska.TrackTypeAudio, CodecID: "A_TRUEHD", Name: "Surround 5.1", Language: "eng"},
+		{Number: 3, Type: matroska.TrackTypeSubtitle, CodecID: "S_HDMV/PGS", Language: "fre"},
+	},
+	Tags: []*matroska.Tag{
+		{Name: "TITLE", Value: "A Fake Movie"},
+		{Name: "ENCODER", Value: "MakeMKV v1.17.7"},
+	},
+}
+
+func writeTestFile(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, matroska.Write(&buf, testFile))
+
+	return buf.Bytes()
+}
+
+func TestWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mkv")
+	require.NoError(t, os.WriteFile(path, writeTestFile(t), 0600))
+
+	f, err := matroska.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, testFile, f)
+	assert.Equal(t, 1, f.CountTracks(matroska.TrackTypeAudio))
+	assert.Equal(t, 0, f.CountTracks(matroska.TrackTypeButtons))
+}
+
+func TestReadUnknownSize(t *testing.T) {
+	b := writeTestFile(t)
+
+	// Mark the segment size as unknown, like a live stream, and add a cluster
+	// of unknown size that must not be read.
+	i := bytes.Index(b, []byte{0x18, 0x53, 0x80, 0x67})
+	require.Positive(t, i)
+	copy(b[i+4:], []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+	b = append(b, 0x1F, 0x43, 0xB6, 0x75, 0xFF, 0xE7, 0x81, 0x00)
+
+	f, err := matroska.Read(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, testFile, f)
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := matroska.Read(bytes.NewReader([]byte("fake mkv\n")))
+	require.ErrorIs(t, err, matroska.ErrNotMatroska)
+
+	_, err = matroska.Read(bytes.NewReader(nil))
+	require.ErrorIs(t, err, matroska.ErrNotMatroska)
+
+	b := writeTestFile(t)
+	for _, n := range []int{len(b) - 1, len(b) / 2, 50} {
+		_, err = matroska.Read(bytes.NewReader(b[:n]))
+		assert.Error(t, err, "truncated to %d bytes", n)
+	}
+}
+
+func TestWriteTags(t *testing.T) {
+	update := []*matroska.Tag{
+		{Name: "TITLE", Value: "The Fake Movie"},
+		{Name: "IMDB", Value: "tt9999999"},
+	}
+	merged := []*matroska.Tag{update[0], testFile.Tags[1], update[1]}
+
+	// The segment size is either known or unknown, and a cluster either follows
+	// the header or not.
+	for _, tc := range []struct {
+		name        string
+		unknownSize bool
+		cluster     bool
+		longValue   bool
+	}{
+		{name: "in place"},
+		{name: "in place before cluster", unknownSize: true, cluster: true},
+		{name: "append", longValue: true},
+		{name: "append after cluster", unknownSize: true, cluster: true, longValue: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := writeTestFile(t)
+			if tc.unknownSize {
+				i := bytes.Index(b, []byte{0x18, 0x53, 0x80, 0x67})
+				require.Positive(t, i)
+				copy(b[i+4:], []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+			}
+			if tc.cluster {
+				b = append(b, 0x1F, 0x43, 0xB6, 0x75, 0x81, 0x00)
+			}
+
+			path := filepath.Join(t.TempDir(), "test.mkv")
+			require.NoError(t, os.WriteFile(path, b, 0600))
+
+			// Too long for the padding that Write leaves.
+			tags, want := update, merged
+			if tc.longValue {
+				long := &matroska.Tag{Name: "SUMMARY", Value: strings.Repeat("A fake summary. ", 100)}
+				tags, want = append(tags, long), append(want, long)
+			}
+			require.NoError(t, matroska.WriteTags(path, tags))
+
+			f, err := matroska.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, want, f.Tags)
+			assert.Equal(t, testFile.Tracks, f.Tracks)
+
+			fi, err := os.Stat(path)
+			require.NoError(t, err)
+			if tc.longValue {
+				assert.Greater(t, fi.Size(), int64(len(b)))
+			} else {
+				assert.Equal(t, int64(len(b)), fi.Size())
+			}
+
+			// Writing the same tags again reuses their place.
+			require.NoError(t, matroska.WriteTags(path, tags))
+			f, err = matroska.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, want, f.Tags)
+
+			fi2, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, fi.Size(), fi2.Size())
+		})
+	}
+}
+
+func TestWriteTagsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mkv")
+	require.NoError(t, os.WriteFile(path, []byte("fake mkv\n"), 0600))
+	require.ErrorIs(t, matroska.WriteTags(path, nil), matroska.ErrNotMatroska)
+}
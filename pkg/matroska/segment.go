@@ -0,0 +1,272 @@
+package matroska
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// segment describes the elements of a segment that are of interest: the
+// top-level elements that precede the first cluster, and the tags, which may
+// also follow the clusters.
+type segment struct {
+	docType string
+
+	// sizeOffset and sizeLen locate the encoded size of the segment, which is
+	// updated when elements are appended.
+	sizeOffset int64
+	sizeLen    int
+
+	// start is the offset of the data of the segment, to which seek positions
+	// are relative, and size is the size of the data or unknownSize.
+	start int64
+	size  int64
+
+	// fileSize is the size of the file.
+	fileSize int64
+
+	// children are the top-level elements before the first cluster.
+	children []*child
+
+	// seekHead is the first seek head, if any, and seeks are its entries.
+	seekHead *child
+	seeks    []*seekEntry
+
+	// tags are the Tags elements, which are children or are found through
+	// the seek head.
+	tags []*child
+}
+
+// child is a top-level element of a segment.
+type child struct {
+	*elementHeader
+
+	// offset is the offset of the element's header.
+	offset int64
+}
+
+// dataOffset returns the offset of the element's data.
+func (c *child) dataOffset() int64 {
+	return c.offset + c.len
+}
+
+// end returns the offset of the end of the element.
+func (c *child) end() int64 {
+	return c.offset + c.len + c.size
+}
+
+// seekEntry is an entry of a seek head: the position of a top-level element
+// relative to the data of the segment.
+type seekEntry struct {
+	id  uint32
+	pos uint64
+}
+
+// end returns the offset of the end of the segment.
+func (s *segment) end() int64 {
+	if s.size == unknownSize {
+		return s.fileSize
+	}
+
+	return s.start + s.size
+}
+
+// readSegment reads the EBML header and the top-level elements of the
+// segment.
+func readSegment(r io.ReadSeeker) (*segment, error) {
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	h, err := readElementHeader(r)
+	if err != nil || h.id != idEBML {
+		return nil, ErrNotMatroska
+	}
+
+	s := &segment{fileSize: fileSize}
+	err = readChildren(r, h, 0, func(h *elementHeader) error {
+		if h.id == idDocType {
+			b, err := readData(r, h)
+			s.docType = decodeString(b)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read EBML header: %w", err)
+	}
+	if s.docType != "matroska" && s.docType != "webm" {
+		return nil, fmt.Errorf("%w: document type %q", ErrNotMatroska, s.docType)
+	}
+
+	offset, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	if h, err = readElementHeader(r); err != nil {
+		return nil, fmt.Errorf("read segment: %w", noEOF(err))
+	} else if h.id != idSegment {
+		return nil, fmt.Errorf("expected segment, found element %#x", h.id)
+	}
+
+	idLen := int64(len(encodeID(idSegment)))
+	s.sizeOffset, s.sizeLen = offset+idLen, int(h.len-idLen)
+	s.start, s.size = offset+h.len, h.size
+
+	offset = s.start
+	err = readChildren(r, h, fileSize, func(h *elementHeader) error {
+		if h.id == idCluster || h.size == unknownSize {
+			return errStop
+		}
+
+		c := &child{elementHeader: h, offset: offset}
+		s.children = append(s.children, c)
+		offset = c.end()
+
+		switch h.id {
+		case idSeekHead:
+			if s.seekHead == nil {
+				s.seekHead = c
+				return readSeekHead(r, h, s)
+			}
+		case idTags:
+			s.tags = append(s.tags, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read segment: %w", err)
+	}
+
+	// The tags are often written after the clusters, when they are known.
+	for _, seek := range s.seeks {
+		offset := s.start + int64(seek.pos)
+		if seek.id != idTags || slices.ContainsFunc(s.tags, func(c *child) bool { return c.offset == offset }) {
+			continue
+		}
+
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		h, err := readElementHeader(r)
+		if err != nil {
+			return nil, fmt.Errorf("read tags at %d: %w", offset, noEOF(err))
+		}
+
+		// Tags that were removed may leave a stale entry.
+		if h.id == idTags {
+			s.tags = append(s.tags, &child{elementHeader: h, offset: offset})
+		}
+	}
+
+	return s, nil
+}
+
+// errStop stops readChildren.
+var errStop = errors.New("stop")
+
+// readChildren calls fn for each child of the master element whose header was
+// just read. fn may read the child's data; the rest is skipped. end is the end
+// of the file, which is the end of the element if its size is unknown.
+func readChildren(r io.ReadSeeker, parent *elementHeader, end int64, fn func(*elementHeader) error) error {
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if parent.size != unknownSize {
+		end = pos + parent.size
+	}
+
+	for pos < end {
+		h, err := readElementHeader(r)
+		if err != nil {
+			return noEOF(err)
+		}
+
+		if err := fn(h); errors.Is(err, errStop) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if h.size == unknownSize {
+			return fmt.Errorf("element %#x has unknown size", h.id)
+		}
+
+		pos += h.len + h.size
+		if pos > end {
+			return fmt.Errorf("element %#x exceeds its parent: %w", h.id, io.ErrUnexpectedEOF)
+		}
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readSeekHead(r io.ReadSeeker, h *elementHeader, s *segment) error {
+	err := readChildren(r, h, 0, func(h *elementHeader) error {
+		if h.id != idSeek {
+			return nil
+		}
+
+		seek := &seekEntry{}
+		err := readChildren(r, h, 0, func(h *elementHeader) error {
+			var (
+				id  uint64
+				err error
+			)
+			switch h.id {
+			case idSeekID:
+				id, err = readUint(r, h)
+				seek.id = uint32(id)
+			case idSeekPosition:
+				seek.pos, err = readUint(r, h)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if seek.id != 0 {
+			s.seeks = append(s.seeks, seek)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read seek head: %w", err)
+	}
+
+	return nil
+}
+
+// encodeSeekHead encodes a seek head with the given entries. The positions
+// take 8 bytes, so the size of the seek head does not depend on them.
+func encodeSeekHead(seeks []*seekEntry) []byte {
+	var entries [][]byte
+	for _, seek := range seeks {
+		entries = append(entries, master(idSeek,
+			element(idSeekID, encodeID(seek.id)),
+			element(idSeekPosition, binary.BigEndian.AppendUint64(nil, seek.pos)),
+		))
+	}
+
+	return master(idSeekHead, entries...)
+}
+
+// seekTo seeks to the data of c.
+func seekTo(r io.Seeker, c *child) error {
+	_, err := r.Seek(c.dataOffset(), io.SeekStart)
+	return err
+}
@@ -0,0 +1,320 @@
+package matroska
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// targetTypeMovie is the target type value of tags that describe a movie or
+// an episode, the default.
+const targetTypeMovie = 50
+
+// Tag is a simple tag, e.g., TITLE or IMDB. See
+// https://www.matroska.org/technical/tagging.html for the official names.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// readTags reads the Tags element whose header was just read. It returns the
+// global tags, which describe the whole file, and the encoded Tag elements
+// that describe something else, e.g., a track.
+func readTags(r io.ReadSeeker, h *elementHeader) ([]*Tag, [][]byte, error) {
+	var (
+		global []*Tag
+		other  [][]byte
+	)
+	err := readChildren(r, h, 0, func(h *elementHeader) error {
+		if h.id != idTag {
+			return nil
+		}
+
+		b, err := readData(r, h)
+		if err != nil {
+			return err
+		}
+
+		tags, ok, err := decodeGlobalTag(b)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			global = mergeTags(global, tags)
+		} else {
+			other = append(other, element(idTag, b))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("read tags: %w", err)
+	}
+
+	return global, other, nil
+}
+
+// decodeGlobalTag decodes the data of a Tag element. It reports whether the
+// tag is global, i.e., its target is the whole file, and if so returns its
+// simple tags.
+func decodeGlobalTag(b []byte) ([]*Tag, bool, error) {
+	var (
+		r      = bytes.NewReader(b)
+		global = true
+		tags   []*Tag
+	)
+	err := readChildren(r, &elementHeader{size: int64(len(b))}, 0, func(h *elementHeader) error {
+		switch h.id {
+		case idTargets:
+			return readChildren(r, h, 0, func(h *elementHeader) error {
+				switch h.id {
+				case idTargetTypeValue:
+					v, err := readUint(r, h)
+					global = global && v == targetTypeMovie
+					return err
+				case idTagTrackUID, idTagEditionUID, idTagChapterUID, idTagAttachmentUID:
+					uid, err := readUint(r, h)
+					global = global && uid == 0
+					return err
+				}
+				return nil
+			})
+		case idSimpleTag:
+			tag := &Tag{}
+			tags = append(tags, tag)
+			return readChildren(r, h, 0, func(h *elementHeader) error {
+				var err error
+				switch h.id {
+				case idTagName:
+					tag.Name, err = readString(r, h)
+				case idTagString:
+					tag.Value, err = readString(r, h)
+				}
+				return err
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return tags, global, nil
+}
+
+// mergeTags returns the tags with the values of the updates, which replace
+// the tags of the same name or are appended.
+func mergeTags(tags, updates []*Tag) []*Tag {
+	for _, update := range updates {
+		i := slices.IndexFunc(tags, func(tag *Tag) bool { return tag.Name == update.Name })
+		if i < 0 {
+			tags = append(tags, &Tag{Name: update.Name, Value: update.Value})
+		} else {
+			tags[i] = &Tag{Name: update.Name, Value: update.Value}
+		}
+	}
+
+	return tags
+}
+
+// encodeTags encodes a Tags element with the global tags and the other,
+// already encoded, Tag elements. It returns nil if there are no tags.
+func encodeTags(global []*Tag, other [][]byte) []byte {
+	var children [][]byte
+	if len(global) > 0 {
+		tag := [][]byte{master(idTargets, uintElement(idTargetTypeValue, targetTypeMovie))}
+		for _, t := range global {
+			tag = append(tag, master(idSimpleTag,
+				stringElement(idTagName, t.Name),
+				stringElement(idTagString, t.Value),
+			))
+		}
+		children = append(children, master(idTag, tag...))
+	}
+	children = append(children, other...)
+
+	if len(children) == 0 {
+		return nil
+	}
+
+	return master(idTags, children...)
+}
+
+// WriteTags sets the global tags of the Matroska file at path. Other tags are
+// kept. Like mkvpropedit, it does not rewrite the file: the tags take the
+// place of the old tags or of padding (Void elements) before the first
+// cluster, if there is room, or else are appended to the file, in which case
+// the seek head must have room for their position.
+func WriteTags(path string, tags []*Tag) (err error) {
+	fp, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", path, err)
+	}
+	defer func() {
+		err = errors.Join(err, fp.Close())
+	}()
+
+	if err := writeTags(fp, tags); err != nil {
+		return fmt.Errorf("write tags to %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// fits reports whether n bytes fit in room bytes, with the rest, if any,
+// filled by a Void element.
+func fits(n, room int64) bool {
+	return n == room || n+2 <= room
+}
+
+// fill writes b at offset and fills the rest of room bytes with a Void
+// element.
+func fill(w io.WriterAt, offset int64, b []byte, room int64) error {
+	if n := int64(len(b)); n < room {
+		b = append(b, voidElement(room-n)...)
+	}
+
+	_, err := w.WriteAt(b, offset)
+	return err
+}
+
+func writeTags(fp *os.File, tags []*Tag) error {
+	s, err := readSegment(fp)
+	if err != nil {
+		return err
+	}
+
+	var (
+		global []*Tag
+		other  [][]byte
+	)
+	for _, c := range s.tags {
+		if err := seekTo(fp, c); err != nil {
+			return err
+		}
+
+		g, o, err := readTags(fp, c.elementHeader)
+		if err != nil {
+			return err
+		}
+		global = mergeTags(global, g)
+		other = append(other, o...)
+	}
+	data := encodeTags(mergeTags(global, tags), other)
+	n := int64(len(data))
+
+	// The old tags and padding before the first cluster may be overwritten.
+	free := func(c *child) bool {
+		return c.id == idVoid || c.id == idTags
+	}
+
+	// The seek head grows by the position of the tags, into the free space
+	// that follows it. The tags go there too if there is room for both, else
+	// in the first run of free elements that is large enough, or else at the
+	// end of the segment, in place of old tags that end it.
+	var (
+		seeks        = []*seekEntry{{id: idTags}}
+		seekHeadRoom int64
+		next         int
+		offset       = s.end()
+		room         int64
+	)
+	if i := slices.Index(s.children, s.seekHead); i >= 0 {
+		for _, seek := range s.seeks {
+			if seek.id != idTags {
+				seeks = append(seeks, seek)
+			}
+		}
+		seekHeadLen := int64(len(encodeSeekHead(seeks)))
+
+		next = i + 1
+		for next < len(s.children) && free(s.children[next]) {
+			next++
+		}
+		run := s.children[next-1].end() - s.seekHead.offset
+
+		switch {
+		case n > 0 && fits(seekHeadLen+n, run):
+			seekHeadRoom = seekHeadLen
+			offset, room = s.seekHead.offset+seekHeadLen, run-seekHeadLen
+		case fits(seekHeadLen, run):
+			seekHeadRoom = run
+		default:
+			next = i + 1
+		}
+	}
+
+	for i := next; i < len(s.children) && room == 0 && n > 0; i++ {
+		for j := i; j < len(s.children) && free(s.children[j]); j++ {
+			if end := s.children[j].end(); fits(n, end-s.children[i].offset) {
+				offset, room = s.children[i].offset, end-s.children[i].offset
+				break
+			}
+		}
+	}
+	if room == 0 {
+		for _, c := range s.tags {
+			if c.end() == s.end() {
+				offset = c.offset
+			}
+		}
+	}
+	end := offset + max(n, room)
+
+	if n > 0 && room == 0 {
+		if s.end() != s.fileSize {
+			return errors.New("the segment does not end at the end of the file")
+		}
+		if seekHeadRoom == 0 {
+			return errors.New("no room for the tags before the first cluster or for their position in the seek head")
+		}
+		if s.size != unknownSize && end-s.start >= 1<<(7*s.sizeLen)-1 {
+			return errors.New("the segment size is too large")
+		}
+	}
+
+	if n > 0 {
+		if room == 0 {
+			if _, err := fp.WriteAt(data, offset); err != nil {
+				return err
+			}
+
+			if end < s.fileSize {
+				if err := fp.Truncate(end); err != nil {
+					return err
+				}
+			}
+
+			if s.size != unknownSize {
+				if _, err := fp.WriteAt(encodeSize(uint64(end-s.start), s.sizeLen), s.sizeOffset); err != nil {
+					return err
+				}
+			}
+		} else if err := fill(fp, offset, data, room); err != nil {
+			return err
+		}
+
+		if seekHeadRoom > 0 {
+			seeks[0].pos = uint64(offset - s.start)
+			if err := fill(fp, s.seekHead.offset, encodeSeekHead(seeks), seekHeadRoom); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Replace the old tags that were not overwritten with padding.
+	for _, c := range s.tags {
+		overwritten := n > 0 && c.offset >= offset && c.offset < end ||
+			seekHeadRoom > 0 && c.offset >= s.seekHead.offset && c.offset < s.seekHead.offset+seekHeadRoom
+		if !overwritten {
+			if _, err := fp.WriteAt(voidElement(c.end()-c.offset), c.offset); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package moviedb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheConfig configures a Cache.
+type CacheConfig struct {
+	// Dir is the directory in which results are stored. It is created if
+	// necessary.
+	Dir string
+
+	// Namespace distinguishes the results of different movie databases that
+	// share Dir, e.g., "imdb".
+	Namespace string
+
+	// TTL is how long results are reused.
+	TTL time.Duration
+}
+
+// Cache is a MovieDB that stores the results of another MovieDB on disk, so
+// that repeating a query does not hit the network. Failed searches and empty
+// results, which may be due to a temporary problem or a typo, are not cached.
+//
+// Cache implements SeriesDB by delegating to the other MovieDB, which returns
+// ErrNotSupported if it does not implement SeriesDB.
+type Cache struct {
+	MovieDB
+
+	cfg CacheConfig
+}
+
+var (
+	_ MovieDB  = (*Cache)(nil)
+	_ SeriesDB = (*Cache)(nil)
+)
+
+// NewCache returns a Cache of db.
+func NewCache(db MovieDB, cfg *CacheConfig) (*Cache, error) {
+	if cfg.TTL <= 0 {
+		return nil, fmt.Errorf("invalid cache TTL %s", cfg.TTL)
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0775); err != nil {
+		return nil, fmt.Errorf("make directory %q: %w", cfg.Dir, err)
+	}
+
+	return &Cache{
+		MovieDB: db,
+		cfg:     *cfg,
+	}, nil
+}
+
+// cacheEntry is the content of a cache file.
+type cacheEntry struct {
+	Namespace string          `json:"namespace"`
+	Query     string          `json:"query"`
+	Time      time.Time       `json:"time"`
+	Results   json.RawMessage `json:"results"`
+}
+
+// SearchMovies implements MovieDB.
+func (c *Cache) SearchMovies(ctx context.Context, q string) ([]*MovieMetadata, error) {
+	return cached(c, q, func() ([]*MovieMetadata, error) {
+		return c.MovieDB.SearchMovies(ctx, q)
+	})
+}
+
+// SearchSeries implements SeriesDB.
+func (c *Cache) SearchSeries(ctx context.Context, q string) ([]*MovieMetadata, error) {
+	db, ok := c.MovieDB.(SeriesDB)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	return cached(c, "series\x00"+q, func() ([]*MovieMetadata, error) {
+		return db.SearchSeries(ctx, q)
+	})
+}
+
+// GetSeason implements SeriesDB.
+func (c *Cache) GetSeason(ctx context.Context, series *MovieMetadata, season int) ([]*EpisodeMetadata, error) {
+	db, ok := c.MovieDB.(SeriesDB)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	return cached(c, fmt.Sprintf("season\x00%s\x00%d", series.ID, season), func() ([]*EpisodeMetadata, error) {
+		return db.GetSeason(ctx, series, season)
+	})
+}
+
+// cached returns the results stored under the key q if they are younger than
+// the TTL. Otherwise, it stores and returns the results of search, unless
+// there are none.
+func cached[T any](c *Cache, q string, search func() ([]T, error)) ([]T, error) {
+	path := c.path(q)
+	if entry, err := c.read(path); err == nil {
+		if time.Since(entry.Time) < c.cfg.TTL {
+			// An entry without results is a miss, like a missing entry.
+			var results []T
+			if len(entry.Results) > 0 {
+				if err := json.Unmarshal(entry.Results, &results); err != nil {
+					slog.Warn("ignoring unreadable movie database cache entry", "path", path, "err", err)
+					results = nil
+				}
+			}
+			if len(results) > 0 {
+				slog.Debug("using cached movie database results", "namespace", c.cfg.Namespace, "query", q, "time", entry.Time)
+				return results, nil
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("ignoring unreadable movie database cache entry", "path", path, "err", err)
+	}
+
+	results, err := search()
+	if err != nil || len(results) == 0 {
+		return results, err
+	}
+
+	b, err := json.Marshal(results)
+	if err == nil {
+		err = c.write(path, &cacheEntry{
+			Namespace: c.cfg.Namespace,
+			Query:     q,
+			Time:      time.Now(),
+			Results:   b,
+		})
+	}
+	if err != nil {
+		slog.Warn("failed to cache movie database results", "path", path, "err", err)
+	}
+
+	return results, nil
+}
+
+// path returns the path of the cache file of query q.
+func (c *Cache) path(q string) string {
+	h := sha256.Sum256([]byte(c.cfg.Namespace + "\x00" + q))
+	return filepath.Join(c.cfg.Dir, hex.EncodeToString(h[:])+".json")
+}
+
+func (c *Cache) read(path string) (*cacheEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// write writes the entry to a temporary file and renames it, so that
+// concurrent searches never read a partial entry.
+func (c *Cache) write(path string, entry *cacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(c.cfg.Dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		return errors.Join(err, f.Close(), os.Remove(f.Name()))
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,201 @@
+package moviedb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+// countingDB returns one result per query, except for "nothing", and counts
+// the searches.
+type countingDB struct {
+	searches int
+	err      error
+}
+
+func (db *countingDB) SearchMovies(_ context.Context, q string) ([]*moviedb.MovieMetadata, error) {
+	db.searches++
+	if db.err != nil || q == "nothing" {
+		return nil, db.err
+	}
+
+	return []*moviedb.MovieMetadata{{Name: q, Year: 2000, ID: "imdb-tt0000001", Kind: "movie"}}, nil
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	db := &countingDB{}
+	cache, err := moviedb.NewCache(db, &moviedb.CacheConfig{
+		Dir:       dir,
+		Namespace: "a",
+		TTL:       time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for range 2 {
+		results, err := cache.SearchMovies(ctx, "heat")
+		require.NoError(t, err)
+		assert.Equal(t, []*moviedb.MovieMetadata{{Name: "heat", Year: 2000, ID: "imdb-tt0000001", Kind: "movie"}}, results)
+	}
+	assert.Equal(t, 1, db.searches)
+
+	_, err = cache.SearchMovies(ctx, "alien")
+	require.NoError(t, err)
+	assert.Equal(t, 2, db.searches)
+
+	// Another namespace in the same directory does not share results.
+	other, err := moviedb.NewCache(db, &moviedb.CacheConfig{
+		Dir:       dir,
+		Namespace: "b",
+		TTL:       time.Hour,
+	})
+	require.NoError(t, err)
+	_, err = other.SearchMovies(ctx, "heat")
+	require.NoError(t, err)
+	assert.Equal(t, 3, db.searches)
+
+	// Failures are not cached.
+	db.err = errors.New("offline")
+	_, err = cache.SearchMovies(ctx, "jaws")
+	require.Error(t, err)
+	_, err = cache.SearchMovies(ctx, "jaws")
+	require.Error(t, err)
+	assert.Equal(t, 5, db.searches)
+
+	// Cached results survive failures.
+	_, err = cache.SearchMovies(ctx, "heat")
+	require.NoError(t, err)
+	assert.Equal(t, 5, db.searches)
+
+	// Empty results are not cached.
+	db.err = nil
+	for range 2 {
+		results, err := cache.SearchMovies(ctx, "nothing")
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	}
+	assert.Equal(t, 7, db.searches)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	db := &countingDB{}
+	cache, err := moviedb.NewCache(db, &moviedb.CacheConfig{
+		Dir: t.TempDir(),
+		TTL: time.Nanosecond,
+	})
+	require.NoError(t, err)
+
+	for range 2 {
+		_, err := cache.SearchMovies(context.Background(), "heat")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, db.searches)
+}
+
+func TestCacheBadEntries(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	dir := t.TempDir()
+	db := &countingDB{}
+	cache, err := moviedb.NewCache(db, &moviedb.CacheConfig{
+		Dir:       dir,
+		Namespace: "a",
+		TTL:       time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = cache.SearchMovies(ctx, "heat")
+	require.NoError(t, err)
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	now, err := time.Now().MarshalText()
+	require.NoError(t, err)
+
+	for i, tc := range []struct {
+		results string
+		warns   bool
+	}{
+		{``, false},
+		{`,"results":null`, false},
+		{`,"results":[]`, false},
+		{`,"results":{"name":"heat"}`, true},
+		{`,"results":[1]`, true},
+	} {
+		logs.Reset()
+		entry := `{"namespace":"a","query":"heat","time":"` + string(now) + `"` + tc.results + `}`
+		require.NoError(t, os.WriteFile(paths[0], []byte(entry), 0600))
+
+		// The entry is a miss, so the movie database is searched again.
+		results, err := cache.SearchMovies(ctx, "heat")
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, i+2, db.searches)
+		assert.Equal(t, tc.warns, strings.Contains(logs.String(), "unreadable"), tc.results)
+	}
+}
+
+// seriesDB is a countingDB that also knows about TV series.
+type seriesDB struct {
+	countingDB
+}
+
+func (db *seriesDB) SearchSeries(_ context.Context, q string) ([]*moviedb.MovieMetadata, error) {
+	db.searches++
+	return []*moviedb.MovieMetadata{{Name: q, ID: "tmdb-1", TMDbID: "1", Kind: "tvSeries"}}, nil
+}
+
+func (db *seriesDB) GetSeason(_ context.Context, _ *moviedb.MovieMetadata, season int) ([]*moviedb.EpisodeMetadata, error) {
+	db.searches++
+	return []*moviedb.EpisodeMetadata{{Season: season, Episode: 1, Name: "Pilot"}}, nil
+}
+
+func TestCacheSeries(t *testing.T) {
+	ctx := context.Background()
+	cfg := &moviedb.CacheConfig{
+		Dir: t.TempDir(),
+		TTL: time.Hour,
+	}
+
+	db := &seriesDB{}
+	cache, err := moviedb.NewCache(db, cfg)
+	require.NoError(t, err)
+
+	for range 2 {
+		series, err := cache.SearchSeries(ctx, "heat")
+		require.NoError(t, err)
+		assert.Equal(t, "tvSeries", series[0].Kind)
+
+		episodes, err := cache.GetSeason(ctx, series[0], 2)
+		require.NoError(t, err)
+		assert.Equal(t, []*moviedb.EpisodeMetadata{{Season: 2, Episode: 1, Name: "Pilot"}}, episodes)
+	}
+	assert.Equal(t, 2, db.searches)
+
+	// Series results do not collide with movie results.
+	movies, err := cache.SearchMovies(ctx, "heat")
+	require.NoError(t, err)
+	assert.Equal(t, "movie", movies[0].Kind)
+
+	// A movie database without series support.
+	cache, err = moviedb.NewCache(&countingDB{}, cfg)
+	require.NoError(t, err)
+	_, err = cache.SearchSeries(ctx, "heat")
+	require.ErrorIs(t, err, moviedb.ErrNotSupported)
+}
@@ -1,6 +1,7 @@
 package moviedb
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -24,8 +25,15 @@ func NewIMDb() *IMDb {
 }
 
 // SearchMovies implements MovieDB.
-func (s *IMDb) SearchMovies(q string) ([]*MovieMetadata, error) {
-	titles, err := imdb.SearchTitle(s.client, q)
+func (s *IMDb) SearchMovies(ctx context.Context, q string) ([]*MovieMetadata, error) {
+	// imdb.SearchTitle does not take a context, so make the client's requests
+	// use ctx.
+	client := *s.client
+	client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return s.client.Transport.RoundTrip(req.Clone(ctx))
+	})
+
+	titles, err := imdb.SearchTitle(&client, q)
 	if err != nil {
 		return nil, fmt.Errorf("search imdb: %w", err)
 	}
@@ -33,15 +41,24 @@ func (s *IMDb) SearchMovies(q string) ([]*MovieMetadata, error) {
 	results := make([]*MovieMetadata, len(titles))
 	for i, title := range titles {
 		results[i] = &MovieMetadata{
-			Name: title.Name,
-			Year: title.Year,
-			ID:   fmt.Sprintf("imdb-%s", title.ID),
+			Name:   title.Name,
+			Year:   title.Year,
+			ID:     fmt.Sprintf("imdb-%s", title.ID),
+			IMDbID: title.ID,
+			Kind:   title.Type,
 		}
 	}
 
 	return results, nil
 }
 
+// roundTripperFunc is an http.RoundTripper that calls itself.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 type customTransport struct {
 	http.RoundTripper
 }
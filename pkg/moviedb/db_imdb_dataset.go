@@ -0,0 +1,409 @@
+package moviedb
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// DefaultIMDbDatasetMaxResults is the default value of
+	// IMDbDatasetConfig.MaxResults.
+	DefaultIMDbDatasetMaxResults = 10
+
+	// imdbDatasetMinScore is the minimum similarity of a search result.
+	imdbDatasetMinScore = 0.3
+)
+
+// DefaultIMDbDatasetTypes are the title types that are searched by default.
+var DefaultIMDbDatasetTypes = []string{"movie", "tvMovie", "video"}
+
+// IMDbDatasetSeriesTypes are the title types searched by SearchSeries.
+var IMDbDatasetSeriesTypes = []string{"tvSeries", "tvMiniSeries"}
+
+// IMDbDatasetConfig configures an IMDbDataset.
+type IMDbDatasetConfig struct {
+	// IndexPath is the path of the index created by ImportIMDbDataset.
+	IndexPath string
+
+	// Types are the title types to search, e.g., "movie" or "tvSeries". They
+	// default to DefaultIMDbDatasetTypes.
+	Types []string
+
+	// MaxResults limits the number of search results. It defaults to
+	// DefaultIMDbDatasetMaxResults.
+	MaxResults int
+}
+
+// IMDbDataset searches a local index of the public IMDb title.basics dataset
+// (https://developer.imdb.com/non-commercial-datasets/) without network
+// access. Titles are matched by trigram similarity, so typos and extra words
+// are tolerated.
+type IMDbDataset struct {
+	cfg IMDbDatasetConfig
+}
+
+var (
+	_ MovieDB  = (*IMDbDataset)(nil)
+	_ SeriesDB = (*IMDbDataset)(nil)
+)
+
+// NewIMDbDataset returns a new IMDbDataset. The index must exist.
+func NewIMDbDataset(cfg *IMDbDatasetConfig) (*IMDbDataset, error) {
+	if _, err := os.Stat(cfg.IndexPath); err != nil {
+		return nil, fmt.Errorf("imdb dataset index (see ImportIMDbDataset): %w", err)
+	}
+
+	c := *cfg
+	if len(c.Types) == 0 {
+		c.Types = DefaultIMDbDatasetTypes
+	}
+	if c.MaxResults <= 0 {
+		c.MaxResults = DefaultIMDbDatasetMaxResults
+	}
+
+	return &IMDbDataset{
+		cfg: c,
+	}, nil
+}
+
+// ImportIMDbDataset reads the title.basics dataset from r, which may be
+// gzip-compressed, and writes the index to path. Episodes and adult titles
+// are omitted. It returns the number of indexed titles.
+func ImportIMDbDataset(path string, r io.Reader) (n int, err error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, fmt.Errorf("decompress dataset: %w", err)
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return 0, fmt.Errorf("make directory for %q: %w", path, err)
+	}
+
+	// Write to a temporary file so that a failed import does not clobber an
+	// existing index.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("create index: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		// tconst titleType primaryTitle originalTitle isAdult startYear
+		// endYear runtimeMinutes genres
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 6 {
+			return 0, fmt.Errorf("parse dataset line %d: expected at least 6 fields, got %d", line, len(fields))
+		}
+
+		if line == 1 && fields[0] == "tconst" {
+			continue
+		}
+
+		if fields[1] == "tvEpisode" || fields[4] == "1" {
+			continue
+		}
+
+		year := fields[5]
+		if year == `\N` {
+			year = ""
+		}
+
+		original := fields[3]
+		if original == fields[2] {
+			original = ""
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", fields[0], fields[1], year, fields[2], original, normalizeTitle(fields[2]), normalizeTitle(original)); err != nil {
+			return 0, fmt.Errorf("write index: %w", err)
+		}
+		n++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("read dataset: %w", err)
+	}
+
+	if err := errors.Join(w.Flush(), f.Close()); err != nil {
+		return 0, fmt.Errorf("write index: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// yearPattern matches a parenthesized year at the end of a query, e.g.,
+// "Heat (1995)". A bare number is not a year, since it may be part of the
+// title, e.g., "Blade Runner 2049".
+var yearPattern = regexp.MustCompile(`\s*\(((?:18|19|20)\d\d)\)\s*$`)
+
+// SearchMovies implements MovieDB. A year at the end of q, e.g., "Heat (1995)",
+// restricts the results to titles released within a year of it.
+func (s *IMDbDataset) SearchMovies(ctx context.Context, q string) ([]*MovieMetadata, error) {
+	return s.searchWithYear(ctx, q, s.cfg.Types)
+}
+
+// SearchSeries implements SeriesDB. A year at the end of q, e.g.,
+// "Breaking Bad (2008)", restricts the results to series that started within
+// a year of it.
+func (s *IMDbDataset) SearchSeries(ctx context.Context, q string) ([]*MovieMetadata, error) {
+	return s.searchWithYear(ctx, q, IMDbDatasetSeriesTypes)
+}
+
+// searchWithYear searches for q, restricted to the year at its end, if any. If
+// nothing was released within a year of it, q is searched for as a whole,
+// since the year may be wrong or part of the title.
+func (s *IMDbDataset) searchWithYear(ctx context.Context, q string, types []string) ([]*MovieMetadata, error) {
+	title, year := cutYear(q)
+	if year == 0 {
+		return s.Search(ctx, q, 0, types)
+	}
+
+	results, err := s.Search(ctx, title, year, types)
+	if err != nil || len(results) > 0 {
+		return results, err
+	}
+
+	return s.Search(ctx, q, 0, types)
+}
+
+// GetSeason implements SeriesDB. It always returns ErrNotSupported because
+// episodes are not imported.
+func (s *IMDbDataset) GetSeason(context.Context, *MovieMetadata, int) ([]*EpisodeMetadata, error) {
+	return nil, ErrNotSupported
+}
+
+// cutYear removes the year matched by yearPattern from the end of q.
+func cutYear(q string) (string, int) {
+	m := yearPattern.FindStringSubmatchIndex(q)
+	if m == nil || m[0] == 0 {
+		return q, 0
+	}
+
+	year, _ := strconv.Atoi(q[m[2]:m[3]])
+	return q[:m[0]], year
+}
+
+type imdbDatasetMatch struct {
+	metadata *MovieMetadata
+	score    float64
+	rank     int
+}
+
+// compareIMDbDatasetMatches orders matches by descending score and then by
+// rank, the index of the title type in the searched types.
+func compareIMDbDatasetMatches(a, b *imdbDatasetMatch) int {
+	if c := cmp.Compare(b.score, a.score); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.rank, b.rank)
+}
+
+// Search returns the titles of the given types that are most similar to q
+// ordered by similarity. Ties are broken by the order of types and then by
+// the order of the index. If year is non-zero, only titles released within a
+// year of it are returned.
+func (s *IMDbDataset) Search(ctx context.Context, q string, year int, types []string) ([]*MovieMetadata, error) {
+	f, err := os.Open(s.cfg.IndexPath)
+	if err != nil {
+		return nil, fmt.Errorf("open imdb dataset index: %w", err)
+	}
+	defer f.Close()
+
+	query := newTrigramMatcher(normalizeTitle(q))
+	if len(query.query) == 0 {
+		return nil, nil
+	}
+
+	// The best matches so far, in order. Only the metadata of titles that
+	// make the cut is materialized.
+	matches := make([]*imdbDatasetMatch, 0, s.cfg.MaxResults+1)
+
+	scanner := bufio.NewScanner(f)
+	for n := 0; scanner.Scan(); n++ {
+		if n%65536 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// tconst titleType startYear primaryTitle originalTitle
+		// normalizedPrimaryTitle normalizedOriginalTitle
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 7 {
+			continue
+		}
+
+		rank := slices.Index(types, fields[1])
+		if rank < 0 {
+			continue
+		}
+
+		titleYear, _ := strconv.Atoi(fields[2])
+		if year != 0 && (titleYear < year-1 || titleYear > year+1) {
+			continue
+		}
+
+		score := query.similarity(fields[5])
+		if fields[6] != "" {
+			score = max(score, query.similarity(fields[6]))
+		}
+		if score < imdbDatasetMinScore {
+			continue
+		}
+
+		m := &imdbDatasetMatch{
+			score: score,
+			rank:  rank,
+		}
+		i, _ := slices.BinarySearchFunc(matches, m, func(a, b *imdbDatasetMatch) int {
+			// Insert after equal matches to keep the index order.
+			if c := compareIMDbDatasetMatches(a, b); c != 0 {
+				return c
+			}
+			return -1
+		})
+		if i >= s.cfg.MaxResults {
+			continue
+		}
+
+		m.metadata = &MovieMetadata{
+			Name:         fields[3],
+			Year:         titleYear,
+			ID:           fmt.Sprintf("imdb-%s", fields[0]),
+			OriginalName: fields[4],
+			IMDbID:       fields[0],
+			Kind:         fields[1],
+		}
+		matches = slices.Insert(matches, i, m)
+		if len(matches) > s.cfg.MaxResults {
+			matches = matches[:s.cfg.MaxResults]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read imdb dataset index: %w", err)
+	}
+
+	results := make([]*MovieMetadata, len(matches))
+	for i, m := range matches {
+		results[i] = m.metadata
+	}
+
+	return results, nil
+}
+
+// normalizeTitle lowercases s, strips diacritics, and replaces punctuation
+// with spaces.
+func normalizeTitle(s string) string {
+	var b strings.Builder
+	space := true
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+			space = false
+		case !space:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// trigrams returns the distinct trigrams of s, padded so that short words have
+// trigrams, as sorted integers. The result reuses buf.
+func trigrams(buf []uint64, s string) []uint64 {
+	buf = buf[:0]
+	if s == "" {
+		return buf
+	}
+
+	a, b := uint64(' '), uint64(' ')
+	for _, r := range s {
+		c := uint64(r)
+		buf = append(buf, a<<42|b<<21|c)
+		a, b = b, c
+	}
+	buf = append(buf, a<<42|b<<21|uint64(' '))
+
+	slices.Sort(buf)
+	return slices.Compact(buf)
+}
+
+// trigramMatcher computes the similarity of strings to a query. It is not
+// safe for concurrent use.
+type trigramMatcher struct {
+	query []uint64
+	buf   []uint64
+}
+
+func newTrigramMatcher(q string) *trigramMatcher {
+	return &trigramMatcher{
+		query: trigrams(nil, q),
+	}
+}
+
+// similarity returns the Jaccard similarity of the trigrams of s and the
+// query.
+func (m *trigramMatcher) similarity(s string) float64 {
+	// A string has at most one trigram per rune plus one. Skip computing them
+	// if that alone rules out a good match.
+	if float64(utf8.RuneCountInString(s)+1)/float64(len(m.query)) < imdbDatasetMinScore {
+		return 0
+	}
+
+	m.buf = trigrams(m.buf, s)
+	lo, hi := min(len(m.query), len(m.buf)), max(len(m.query), len(m.buf))
+	if hi == 0 || float64(lo)/float64(hi) < imdbDatasetMinScore {
+		return 0
+	}
+
+	common := 0
+	for i, j := 0, 0; i < len(m.query) && j < len(m.buf); {
+		switch {
+		case m.query[i] < m.buf[j]:
+			i++
+		case m.query[i] > m.buf[j]:
+			j++
+		default:
+			common++
+			i++
+			j++
+		}
+	}
+
+	return float64(common) / float64(len(m.query)+len(m.buf)-common)
+}
@@ -0,0 +1,140 @@
+package moviedb_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+// imdbDataset is a few lines in the format of title.basics.tsv.gz.
+var imdbDataset = []string{
+	"tconst\ttitleType\tprimaryTitle\toriginalTitle\tisAdult\tstartYear\tendYear\truntimeMinutes\tgenres",
+	"tt0113277\tmovie\tHeat\tHeat\t0\t1995\t\\N\t170\tAction,Crime,Drama",
+	"tt0095294\tmovie\tHeat\tHeat\t0\t1986\t\\N\t101\tAction,Crime,Thriller",
+	"tt0133093\tmovie\tThe Matrix\tThe Matrix\t0\t1999\t\\N\t136\tAction,Sci-Fi",
+	"tt0274166\tvideo\tThe Matrix Revisited\tThe Matrix Revisited\t0\t2001\t\\N\t123\tDocumentary",
+	"tt0211915\tmovie\tAmélie\tLe fabuleux destin d'Amélie Poulain\t0\t2001\t\\N\t122\tComedy,Romance",
+	"tt0903747\ttvSeries\tBreaking Bad\tBreaking Bad\t0\t2008\t2013\t49\tCrime,Drama",
+	"tt0083658\tmovie\tBlade Runner\tBlade Runner\t0\t1982\t\\N\t117\tAction,Drama,Sci-Fi",
+	"tt1856101\tmovie\tBlade Runner 2049\tBlade Runner 2049\t0\t2017\t\\N\t164\tAction,Drama,Mystery",
+	"tt0451279\tmovie\tWonder Woman\tWonder Woman\t0\t2017\t\\N\t141\tAction,Adventure,Fantasy",
+	"tt7126948\tmovie\tWonder Woman 1984\tWonder Woman 1984\t0\t2020\t\\N\t151\tAction,Adventure,Fantasy",
+	"tt0072856\tmovie\tDeath Race 2000\tDeath Race 2000\t0\t1975\t\\N\t80\tAction,Comedy,Sci-Fi",
+	"tt0452608\tmovie\tDeath Race\tDeath Race\t0\t2008\t\\N\t105\tAction,Sci-Fi,Thriller",
+	"tt0959621\ttvEpisode\tPilot\tPilot\t0\t2008\t\\N\t58\tCrime,Drama",
+}
+
+func newIMDbDataset(t *testing.T, cfg *moviedb.IMDbDatasetConfig) *moviedb.IMDbDataset {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(strings.Join(imdbDataset, "\n") + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	cfg.IndexPath = filepath.Join(t.TempDir(), "index", "imdb.tsv")
+	n, err := moviedb.ImportIMDbDataset(cfg.IndexPath, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, 12, n)
+
+	db, err := moviedb.NewIMDbDataset(cfg)
+	require.NoError(t, err)
+
+	return db
+}
+
+func ids(results []*moviedb.MovieMetadata) []string {
+	var s []string
+	for _, r := range results {
+		s = append(s, r.ID)
+	}
+
+	return s
+}
+
+func TestIMDbDatasetSearchMovies(t *testing.T) {
+	db := newIMDbDataset(t, &moviedb.IMDbDatasetConfig{})
+
+	for _, tc := range []struct {
+		q        string
+		expected []string
+	}{
+		{"The Matrix", []string{"imdb-tt0133093", "imdb-tt0274166"}},
+		{"the matrx", []string{"imdb-tt0133093", "imdb-tt0274166"}},
+		{"Heat", []string{"imdb-tt0113277", "imdb-tt0095294"}},
+		{"Heat (1986)", []string{"imdb-tt0095294"}},
+		{"Heat 1995", []string{"imdb-tt0113277", "imdb-tt0095294"}},
+		{"Heat (2020)", []string{"imdb-tt0113277", "imdb-tt0095294"}},
+		{"Blade Runner 2049", []string{"imdb-tt1856101", "imdb-tt0083658"}},
+		{"Blade Runner 2049 (2017)", []string{"imdb-tt1856101"}},
+		{"Blade Runner", []string{"imdb-tt0083658", "imdb-tt1856101"}},
+		{"WONDER WOMAN 1984", []string{"imdb-tt7126948", "imdb-tt0451279"}},
+		{"Wonder Woman (2017)", []string{"imdb-tt0451279"}},
+		{"Death Race 2000", []string{"imdb-tt0072856", "imdb-tt0452608"}},
+		{"Death Race", []string{"imdb-tt0452608", "imdb-tt0072856"}},
+		{"amelie", []string{"imdb-tt0211915"}},
+		{"Le Fabuleux Destin d'Amelie Poulain", []string{"imdb-tt0211915"}},
+		{"Breaking Bad", nil},
+		{"Pilot", nil},
+		{"", nil},
+	} {
+		t.Run(tc.q, func(t *testing.T) {
+			results, err := db.SearchMovies(context.Background(), tc.q)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, ids(results))
+		})
+	}
+
+	results, err := db.SearchMovies(context.Background(), "The Matrix")
+	require.NoError(t, err)
+	assert.Equal(t, &moviedb.MovieMetadata{
+		Name:   "The Matrix",
+		Year:   1999,
+		ID:     "imdb-tt0133093",
+		IMDbID: "tt0133093",
+		Kind:   "movie",
+	}, results[0])
+}
+
+func TestIMDbDatasetTypes(t *testing.T) {
+	db := newIMDbDataset(t, &moviedb.IMDbDatasetConfig{
+		Types:      []string{"tvSeries"},
+		MaxResults: 1,
+	})
+
+	results, err := db.SearchMovies(context.Background(), "breaking bad")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"imdb-tt0903747"}, ids(results))
+
+	results, err = db.Search(context.Background(), "the matrix revisited", 0, []string{"movie", "video"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"imdb-tt0274166"}, ids(results))
+}
+
+func TestIMDbDatasetSeries(t *testing.T) {
+	db := newIMDbDataset(t, &moviedb.IMDbDatasetConfig{})
+
+	results, err := db.SearchSeries(context.Background(), "breaking bad (2008)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"imdb-tt0903747"}, ids(results))
+	assert.Equal(t, "tvSeries", results[0].Kind)
+
+	_, err = db.GetSeason(context.Background(), results[0], 1)
+	require.ErrorIs(t, err, moviedb.ErrNotSupported)
+}
+
+func TestIMDbDatasetMissingIndex(t *testing.T) {
+	_, err := moviedb.NewIMDbDataset(&moviedb.IMDbDatasetConfig{
+		IndexPath: filepath.Join(t.TempDir(), "missing.tsv"),
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,262 @@
+package moviedb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultTMDbBaseURL is the base URL of version 3 of the TMDb API.
+	DefaultTMDbBaseURL = "https://api.themoviedb.org/3"
+
+	// DefaultTMDbMaxResults is the default value of TMDbConfig.MaxResults.
+	DefaultTMDbMaxResults = 5
+)
+
+// TMDbConfig configures a TMDb.
+type TMDbConfig struct {
+	// APIKey is the TMDb API key (v3 auth).
+	APIKey string
+
+	// BaseURL is the base URL of the API. It defaults to DefaultTMDbBaseURL.
+	BaseURL string
+
+	// MaxResults limits the number of search results, since looking up the
+	// IMDb ID requires an additional request per result. It defaults to
+	// DefaultTMDbMaxResults.
+	MaxResults int
+
+	// Client is the HTTP client. It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// TMDb interfaces with the themoviedb.org movie database using the official
+// JSON API: https://developer.themoviedb.org/docs
+type TMDb struct {
+	cfg TMDbConfig
+}
+
+var (
+	_ MovieDB  = (*TMDb)(nil)
+	_ SeriesDB = (*TMDb)(nil)
+)
+
+// NewTMDb returns a new TMDb.
+func NewTMDb(cfg *TMDbConfig) (*TMDb, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("tmdb: missing API key")
+	}
+
+	c := *cfg
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultTMDbBaseURL
+	}
+	c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+	if c.MaxResults <= 0 {
+		c.MaxResults = DefaultTMDbMaxResults
+	}
+	if c.Client == nil {
+		c.Client = http.DefaultClient
+	}
+
+	return &TMDb{
+		cfg: c,
+	}, nil
+}
+
+type tmdbSearchResponse struct {
+	Results []struct {
+		ID            int    `json:"id"`
+		Title         string `json:"title"`
+		OriginalTitle string `json:"original_title"`
+		ReleaseDate   string `json:"release_date"`
+	} `json:"results"`
+}
+
+type tmdbSearchTVResponse struct {
+	Results []struct {
+		ID           int    `json:"id"`
+		Name         string `json:"name"`
+		OriginalName string `json:"original_name"`
+		FirstAirDate string `json:"first_air_date"`
+	} `json:"results"`
+}
+
+type tmdbSeasonResponse struct {
+	Episodes []struct {
+		SeasonNumber  int    `json:"season_number"`
+		EpisodeNumber int    `json:"episode_number"`
+		Name          string `json:"name"`
+	} `json:"episodes"`
+}
+
+type tmdbExternalIDsResponse struct {
+	IMDbID string `json:"imdb_id"`
+}
+
+type tmdbErrorResponse struct {
+	StatusMessage string `json:"status_message"`
+}
+
+// SearchMovies implements MovieDB.
+func (s *TMDb) SearchMovies(ctx context.Context, q string) ([]*MovieMetadata, error) {
+	var resp tmdbSearchResponse
+	if err := s.get(ctx, "/search/movie", url.Values{"query": {q}}, &resp); err != nil {
+		return nil, fmt.Errorf("search tmdb: %w", err)
+	}
+
+	results := resp.Results
+	if len(results) > s.cfg.MaxResults {
+		results = results[:s.cfg.MaxResults]
+	}
+
+	metadata := make([]*MovieMetadata, len(results))
+	for i, result := range results {
+		// The release date is formatted like "1999-03-30", but may be empty.
+		year, _ := strconv.Atoi(strings.SplitN(result.ReleaseDate, "-", 2)[0])
+
+		id := strconv.Itoa(result.ID)
+		imdbID, err := s.getIMDbID(ctx, "movie", id)
+		if err != nil {
+			return nil, err
+		}
+
+		metadata[i] = &MovieMetadata{
+			Name:         result.Title,
+			Year:         year,
+			ID:           fmt.Sprintf("tmdb-%s", id),
+			OriginalName: result.OriginalTitle,
+			IMDbID:       imdbID,
+			TMDbID:       id,
+			Kind:         "movie",
+		}
+	}
+
+	return metadata, nil
+}
+
+// SearchSeries implements SeriesDB.
+func (s *TMDb) SearchSeries(ctx context.Context, q string) ([]*MovieMetadata, error) {
+	var resp tmdbSearchTVResponse
+	if err := s.get(ctx, "/search/tv", url.Values{"query": {q}}, &resp); err != nil {
+		return nil, fmt.Errorf("search tmdb: %w", err)
+	}
+
+	results := resp.Results
+	if len(results) > s.cfg.MaxResults {
+		results = results[:s.cfg.MaxResults]
+	}
+
+	metadata := make([]*MovieMetadata, len(results))
+	for i, result := range results {
+		year, _ := strconv.Atoi(strings.SplitN(result.FirstAirDate, "-", 2)[0])
+
+		id := strconv.Itoa(result.ID)
+		imdbID, err := s.getIMDbID(ctx, "tv", id)
+		if err != nil {
+			return nil, err
+		}
+
+		metadata[i] = &MovieMetadata{
+			Name:         result.Name,
+			Year:         year,
+			ID:           fmt.Sprintf("tmdb-%s", id),
+			OriginalName: result.OriginalName,
+			IMDbID:       imdbID,
+			TMDbID:       id,
+			Kind:         "tvSeries",
+		}
+	}
+
+	return metadata, nil
+}
+
+// GetSeason implements SeriesDB. The series must have a TMDbID or an ID like
+// "tmdb-1396".
+func (s *TMDb) GetSeason(ctx context.Context, series *MovieMetadata, season int) ([]*EpisodeMetadata, error) {
+	id := series.TMDbID
+	if v, ok := strings.CutPrefix(series.ID, "tmdb-"); ok && id == "" {
+		id = v
+	}
+	if id == "" {
+		return nil, fmt.Errorf("series %q has no tmdb id", series.Name)
+	}
+
+	var resp tmdbSeasonResponse
+	if err := s.get(ctx, fmt.Sprintf("/tv/%s/season/%d", id, season), nil, &resp); err != nil {
+		return nil, fmt.Errorf("get tmdb season %d of %s: %w", season, id, err)
+	}
+
+	episodes := make([]*EpisodeMetadata, len(resp.Episodes))
+	for i, episode := range resp.Episodes {
+		episodes[i] = &EpisodeMetadata{
+			Season:  episode.SeasonNumber,
+			Episode: episode.EpisodeNumber,
+			Name:    episode.Name,
+		}
+	}
+
+	return episodes, nil
+}
+
+// getIMDbID returns the IMDb ID of the movie or TV series (kind "movie" or
+// "tv") with the given TMDb ID. The ID is merely nice to have, so a failure
+// other than cancellation is logged and yields an empty ID.
+func (s *TMDb) getIMDbID(ctx context.Context, kind, id string) (string, error) {
+	var ids tmdbExternalIDsResponse
+	if err := s.get(ctx, fmt.Sprintf("/%s/%s/external_ids", kind, id), nil, &ids); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		slog.Warn("failed to get tmdb external ids", "kind", kind, "id", id, "err", err)
+		return "", nil
+	}
+
+	return ids.IMDbID, nil
+}
+
+// get decodes the JSON response to a GET request of the API at path.
+func (s *TMDb) get(ctx context.Context, path string, query url.Values, v any) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api_key", s.cfg.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.BaseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		// The error includes the URL and therefore the API key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e tmdbErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.StatusMessage != "" {
+			return fmt.Errorf("%s: %s", resp.Status, e.StatusMessage)
+		}
+		return errors.New(resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package moviedb_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+func newTMDbServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search/movie", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api_key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]any{"status_message": "Invalid API key: You must be granted a valid key."})
+			return
+		}
+
+		assert.Equal(t, "the matrix", r.URL.Query().Get("query"))
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"results": []map[string]any{
+				{"id": 603, "title": "The Matrix", "original_title": "The Matrix", "release_date": "1999-03-30"},
+				{"id": 14543, "title": "Matrix", "original_title": "Матрица", "release_date": ""},
+				{"id": 1, "title": "Truncated", "release_date": "2000-01-01"},
+			},
+		})
+	})
+	mux.HandleFunc("GET /movie/{id}/external_ids", func(w http.ResponseWriter, r *http.Request) {
+		// A failure to get the IMDb ID does not fail the search.
+		if r.PathValue("id") == "14543" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		imdbID := map[string]string{"603": "tt0133093"}[r.PathValue("id")]
+		_ = json.NewEncoder(w).Encode(map[string]any{"imdb_id": imdbID})
+	})
+	mux.HandleFunc("GET /search/tv", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "breaking bad", r.URL.Query().Get("query"))
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"results": []map[string]any{
+				{"id": 1396, "name": "Breaking Bad", "original_name": "Breaking Bad", "first_air_date": "2008-01-20"},
+			},
+		})
+	})
+	mux.HandleFunc("GET /tv/{id}/external_ids", func(w http.ResponseWriter, r *http.Request) {
+		imdbID := map[string]string{"1396": "tt0903747"}[r.PathValue("id")]
+		_ = json.NewEncoder(w).Encode(map[string]any{"imdb_id": imdbID})
+	})
+	mux.HandleFunc("GET /tv/1396/season/{season}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("season") != "1" {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]any{"status_message": "The resource you requested could not be found."})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"episodes": []map[string]any{
+				{"season_number": 1, "episode_number": 1, "name": "Pilot"},
+				{"season_number": 1, "episode_number": 2, "name": "Cat's in the Bag..."},
+			},
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestTMDbSearchMovies(t *testing.T) {
+	srv := newTMDbServer(t)
+	db, err := moviedb.NewTMDb(&moviedb.TMDbConfig{
+		APIKey:     "secret",
+		BaseURL:    srv.URL,
+		MaxResults: 2,
+		Client:     srv.Client(),
+	})
+	require.NoError(t, err)
+
+	results, err := db.SearchMovies(context.Background(), "the matrix")
+	require.NoError(t, err)
+	assert.Equal(t, []*moviedb.MovieMetadata{
+		{Name: "The Matrix", Year: 1999, ID: "tmdb-603", OriginalName: "The Matrix", IMDbID: "tt0133093", TMDbID: "603", Kind: "movie"},
+		{Name: "Matrix", ID: "tmdb-14543", OriginalName: "Матрица", TMDbID: "14543", Kind: "movie"},
+	}, results)
+}
+
+func TestTMDbInvalidAPIKey(t *testing.T) {
+	srv := newTMDbServer(t)
+	db, err := moviedb.NewTMDb(&moviedb.TMDbConfig{
+		APIKey:  "wrong",
+		BaseURL: srv.URL,
+		Client:  srv.Client(),
+	})
+	require.NoError(t, err)
+
+	_, err = db.SearchMovies(context.Background(), "the matrix")
+	require.ErrorContains(t, err, "401 Unauthorized: Invalid API key")
+	assert.NotContains(t, err.Error(), "wrong")
+}
+
+func TestTMDbMissingAPIKey(t *testing.T) {
+	_, err := moviedb.NewTMDb(&moviedb.TMDbConfig{})
+	require.Error(t, err)
+}
+
+func TestTMDbCanceled(t *testing.T) {
+	srv := newTMDbServer(t)
+	db, err := moviedb.NewTMDb(&moviedb.TMDbConfig{
+		APIKey:  "secret",
+		BaseURL: srv.URL,
+		Client:  srv.Client(),
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.SearchMovies(ctx, "the matrix")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTMDbSeries(t *testing.T) {
+	srv := newTMDbServer(t)
+	db, err := moviedb.NewTMDb(&moviedb.TMDbConfig{
+		APIKey:  "secret",
+		BaseURL: srv.URL,
+		Client:  srv.Client(),
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	results, err := db.SearchSeries(ctx, "breaking bad")
+	require.NoError(t, err)
+	require.Equal(t, []*moviedb.MovieMetadata{
+		{Name: "Breaking Bad", Year: 2008, ID: "tmdb-1396", OriginalName: "Breaking Bad", IMDbID: "tt0903747", TMDbID: "1396", Kind: "tvSeries"},
+	}, results)
+
+	episodes, err := db.GetSeason(ctx, results[0], 1)
+	require.NoError(t, err)
+	assert.Equal(t, []*moviedb.EpisodeMetadata{
+		{Season: 1, Episode: 1, Name: "Pilot"},
+		{Season: 1, Episode: 2, Name: "Cat's in the Bag..."},
+	}, episodes)
+
+	// Metadata from the history only has the ID.
+	episodes, err = db.GetSeason(ctx, &moviedb.MovieMetadata{ID: "tmdb-1396"}, 1)
+	require.NoError(t, err)
+	assert.Len(t, episodes, 2)
+
+	_, err = db.GetSeason(ctx, results[0], 9)
+	require.ErrorContains(t, err, "404 Not Found")
+
+	_, err = db.GetSeason(ctx, &moviedb.MovieMetadata{Name: "No ID"}, 1)
+	require.Error(t, err)
+}
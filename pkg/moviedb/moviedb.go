@@ -1,5 +1,10 @@
 package moviedb
 
+import (
+	"context"
+	"errors"
+)
+
 // MovieMetadata is metadata about a movie.
 //
 // It contains the fields necessary for Plex's file naming scheme:
@@ -8,13 +13,54 @@ type MovieMetadata struct {
 	Name string
 	Year int
 
-	// ID is the movie database identifier, e.g., "imdb-tt0118715".
+	// ID is the movie database identifier, e.g., "imdb-tt0118715" or
+	// "tmdb-603".
 	ID string
+
+	// OriginalName is the name in the original language, if known.
+	OriginalName string
+
+	// IMDbID is the IMDb identifier, e.g., "tt0118715", if known.
+	IMDbID string
+
+	// TMDbID is the TMDb identifier, e.g., "603", if known.
+	TMDbID string
+
+	// Kind is the kind of title, e.g., "movie" or "tvSeries", if known.
+	Kind string
+
+	// Source names where the metadata came from, e.g., the movie database
+	// "imdb", if known.
+	Source string
 }
 
 // MovieDB is the interface implemented by movie databases such as IMDb.
 type MovieDB interface {
 	// SearchMovies returns a list of results matching query q (typically the
 	// movie title) ordered by relevance.
-	SearchMovies(q string) ([]*MovieMetadata, error)
+	SearchMovies(ctx context.Context, q string) ([]*MovieMetadata, error)
+}
+
+// ErrNotSupported is returned by databases that lack the requested kind of
+// metadata.
+var ErrNotSupported = errors.New("not supported")
+
+// EpisodeMetadata is metadata about an episode of a TV series.
+type EpisodeMetadata struct {
+	Season  int
+	Episode int
+	Name    string
+}
+
+// SeriesDB is the interface implemented by movie databases that also know
+// about TV series, such as TMDb.
+type SeriesDB interface {
+	// SearchSeries returns a list of TV series matching query q ordered by
+	// relevance. Kind is "tvSeries".
+	SearchSeries(ctx context.Context, q string) ([]*MovieMetadata, error)
+
+	// GetSeason returns the episodes of a season of a TV series found by
+	// SearchSeries, or ErrNotSupported if the database does not know the
+	// names of episodes.
+	GetSeason(ctx context.Context, series *MovieMetadata, season int) ([]*EpisodeMetadata, error)
 }
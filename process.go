@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+)
+
+// processPaths backs up the discs of the ISO images and disc folders at or
+// below paths, one at a time, as if they had been inserted into a drive.
+func (app *application) processPaths(ctx context.Context, paths []string) error {
+	app.ui.setStatus("Finding ISO images and disc folders")
+
+	var sources []*discSource
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("get absolute path: %w", err)
+		}
+
+		found, err := makemkv.FindSources(path)
+		if err != nil {
+			return fmt.Errorf("find discs: %w", err)
+		}
+
+		for _, src := range found {
+			sources = append(sources, &discSource{Source: src, name: src.Path})
+		}
+	}
+
+	if len(sources) == 0 {
+		return errors.New("no ISO images or disc folders found")
+	}
+
+	view := app.ui.addView("Files")
+	var failed int
+	for i, src := range sources {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		slog.Info("processing disc", "disc", src, "number", i+1, "count", len(sources))
+		view.setSource(src)
+		if err := app.tryBackup(ctx, src, view); err != nil {
+			slog.Error(err.Error(), "disc", src)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d discs failed", failed, len(sources))
+	}
+
+	return nil
+}
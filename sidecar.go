@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+const (
+	// movieNFOFileName and sidecarFileName are the names of the sidecar files
+	// of a movie in its own directory.
+	movieNFOFileName = "movie.nfo"
+	sidecarFileName  = "mkvbot.json"
+
+	// The sources of movie metadata that did not come from a movie database.
+	metadataSourceHistory = "history"
+	metadataSourceQuery   = "query"
+)
+
+type (
+	// sidecar is the content of the JSON sidecar file, which records how a
+	// title was chosen and named, so the decisions can be reviewed or made
+	// again without the disc. makemkv.LoadDisc loads the disc from it.
+	sidecar struct {
+		MkvbotVersion string           `json:"mkvbotVersion,omitempty"`
+		Time          time.Time        `json:"time"`
+		Drive         string           `json:"drive"`
+		Fingerprint   string           `json:"fingerprint,omitempty"`
+		Disc          *makemkv.Disc    `json:"disc"`
+		Scores        []int64          `json:"scores,omitempty"`
+		TitleIndex    int              `json:"titleIndex"`
+		Metadata      *sidecarMetadata `json:"metadata"`
+		Edition       string           `json:"edition,omitempty"`
+		Season        int              `json:"season,omitempty"`
+		Episode       int              `json:"episode,omitempty"`
+		EpisodeName   string           `json:"episodeName,omitempty"`
+	}
+
+	sidecarMetadata struct {
+		Name         string `json:"name"`
+		Year         int    `json:"year,omitempty"`
+		ID           string `json:"id,omitempty"`
+		OriginalName string `json:"originalName,omitempty"`
+		IMDbID       string `json:"imdbId,omitempty"`
+		TMDbID       string `json:"tmdbId,omitempty"`
+		Kind         string `json:"kind,omitempty"`
+
+		// Source is the name of the movie database, metadataSourceHistory or
+		// metadataSourceQuery.
+		Source string `json:"source,omitempty"`
+	}
+
+	// movieNFO and episodeNFO are NFO files as read by Kodi and Jellyfin.
+	movieNFO struct {
+		XMLName       xml.Name       `xml:"movie"`
+		Title         string         `xml:"title"`
+		OriginalTitle string         `xml:"originaltitle,omitempty"`
+		Year          int            `xml:"year,omitempty"`
+		Runtime       int            `xml:"runtime,omitempty"`
+		UniqueIDs     []*nfoUniqueID `xml:"uniqueid"`
+	}
+
+	episodeNFO struct {
+		XMLName   xml.Name `xml:"episodedetails"`
+		Title     string   `xml:"title"`
+		ShowTitle string   `xml:"showtitle"`
+		Season    int      `xml:"season"`
+		Episode   int      `xml:"episode"`
+		Runtime   int      `xml:"runtime,omitempty"`
+	}
+
+	nfoUniqueID struct {
+		Type    string `xml:"type,attr"`
+		Default bool   `xml:"default,attr,omitempty"`
+		ID      string `xml:",chardata"`
+	}
+)
+
+// sidecarPaths returns the paths of the NFO and JSON sidecar files of the
+// ripped title at path. A movie in its own directory gets movie.nfo and
+// mkvbot.json; other titles, e.g., episodes, get files named after them.
+func (app *application) sidecarPaths(path string, episode bool) (nfoPath, jsonPath string) {
+	dir := filepath.Dir(path)
+	if !episode && dir != filepath.Clean(app.cfg.outputDirPath) {
+		return filepath.Join(dir, movieNFOFileName), filepath.Join(dir, sidecarFileName)
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	return base + ".nfo", base + ".mkvbot.json"
+}
+
+// writeSidecars writes the NFO and JSON sidecar files of the title ripped to
+// rec.OutputPath and returns their paths. The title is usable without them,
+// so errors are only logged.
+func (app *application) writeSidecars(src *discSource, rec *history.Record, md *moviedb.MovieMetadata, disc *makemkv.Disc, title *makemkv.Title, episodeName string) []string {
+	if app.cfg.skipSidecars {
+		return nil
+	}
+
+	episode := rec.Season > 0
+	nfoPath, jsonPath := app.sidecarPaths(rec.OutputPath, episode)
+
+	imdbID, tmdbID := movieIDs(md)
+	var runtime int
+	if duration, err := title.GetAttrDuration(defs.Duration); err == nil {
+		runtime = int(duration.Round(time.Minute).Minutes())
+	}
+
+	var nfo any
+	if episode {
+		nfo = &episodeNFO{
+			Title:     episodeName,
+			ShowTitle: md.Name,
+			Season:    rec.Season,
+			Episode:   rec.Episode,
+			Runtime:   runtime,
+		}
+	} else {
+		movie := &movieNFO{
+			Title:         md.Name,
+			OriginalTitle: md.OriginalName,
+			Year:          md.Year,
+			Runtime:       runtime,
+		}
+		if imdbID != "" {
+			movie.UniqueIDs = append(movie.UniqueIDs, &nfoUniqueID{Type: "imdb", ID: imdbID})
+		}
+		if tmdbID != "" {
+			movie.UniqueIDs = append(movie.UniqueIDs, &nfoUniqueID{Type: "tmdb", ID: tmdbID})
+		}
+		if len(movie.UniqueIDs) > 0 {
+			movie.UniqueIDs[0].Default = true
+		}
+		nfo = movie
+	}
+
+	data := &sidecar{
+		MkvbotVersion: Version,
+		Time:          time.Now(),
+		Drive:         rec.Drive,
+		Fingerprint:   rec.Fingerprint,
+		Disc:          disc,
+		Scores:        rec.Scores,
+		TitleIndex:    title.Index,
+		Metadata: &sidecarMetadata{
+			Name:         md.Name,
+			Year:         md.Year,
+			ID:           md.ID,
+			OriginalName: md.OriginalName,
+			IMDbID:       imdbID,
+			TMDbID:       tmdbID,
+			Kind:         md.Kind,
+			Source:       md.Source,
+		},
+		Edition:     rec.Edition,
+		Season:      rec.Season,
+		Episode:     rec.Episode,
+		EpisodeName: episodeName,
+	}
+
+	files := []struct {
+		path   string
+		encode func() ([]byte, error)
+	}{
+		{nfoPath, func() ([]byte, error) {
+			b, err := xml.MarshalIndent(nfo, "", "  ")
+			return append([]byte(xml.Header), append(b, '\n')...), err
+		}},
+		{jsonPath, func() ([]byte, error) {
+			b, err := json.MarshalIndent(data, "", "  ")
+			return append(b, '\n'), err
+		}},
+	}
+
+	var paths []string
+	for _, file := range files {
+		if err := writeSidecar(file.path, file.encode); err != nil {
+			slog.Error("failed to write sidecar file", "disc", src, "path", file.path, "err", err)
+			continue
+		}
+		paths = append(paths, file.path)
+	}
+
+	return paths
+}
+
+func writeSidecar(path string, encode func() ([]byte, error)) error {
+	b, err := encode()
+	if err != nil {
+		return fmt.Errorf("encode %q: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/matroska"
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+// titleTags returns the Matroska tags that describe the movie or episode
+// described by rec and md. The names are the official ones where there are
+// any, so that media servers understand them.
+func titleTags(rec *history.Record, md *moviedb.MovieMetadata, episodeName string) []*matroska.Tag {
+	var tags []*matroska.Tag
+	add := func(name, value string) {
+		if value != "" {
+			tags = append(tags, &matroska.Tag{Name: name, Value: value})
+		}
+	}
+
+	imdbID, tmdbID := movieIDs(md)
+	if rec.Season > 0 {
+		add("TITLE", episodeName)
+		add("PART_NUMBER", strconv.Itoa(rec.Episode))
+		if tmdbID != "" {
+			tmdbID = "tv/" + tmdbID
+		}
+	} else {
+		add("TITLE", rec.Name)
+		if tmdbID != "" {
+			tmdbID = "movie/" + tmdbID
+		}
+
+		// The year of an episode is the year of its series, which is not
+		// when the episode was released.
+		if rec.Year > 0 {
+			add("DATE_RELEASED", strconv.Itoa(rec.Year))
+		}
+	}
+	add("IMDB", imdbID)
+	add("TMDB", tmdbID)
+	add("DISC_LABEL", rec.DiscName)
+	add("DATE_ENCODED", time.Now().Format(time.DateOnly))
+	add("MKVBOT_VERSION", Version)
+
+	return tags
+}
+
+// tagTitle writes the tags into the ripped title at path. The title is usable
+// without them, so errors are only logged.
+func (app *application) tagTitle(src *discSource, path string, tags []*matroska.Tag) {
+	if app.cfg.skipTags {
+		return
+	}
+
+	if err := matroska.WriteTags(path, tags); err != nil {
+		slog.Error("failed to tag title", "disc", src, "path", path, "err", err)
+		return
+	}
+
+	slog.Debug("tagged title", "disc", src, "path", path, "count", len(tags))
+}
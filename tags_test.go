@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/matroska"
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+func TestTitleTags(t *testing.T) {
+	today := time.Now().Format(time.DateOnly)
+
+	for _, tc := range []struct {
+		name        string
+		rec         *history.Record
+		md          *moviedb.MovieMetadata
+		episodeName string
+		expected    []*matroska.Tag
+	}{
+		{
+			name: "movie",
+			rec:  &history.Record{DiscName: "THE_MATRIX", Name: "The Matrix", Year: 1999},
+			md:   &moviedb.MovieMetadata{Name: "The Matrix", Year: 1999, IMDbID: "tt0133093", TMDbID: "603"},
+			expected: []*matroska.Tag{
+				{Name: "TITLE", Value: "The Matrix"},
+				{Name: "DATE_RELEASED", Value: "1999"},
+				{Name: "IMDB", Value: "tt0133093"},
+				{Name: "TMDB", Value: "movie/603"},
+				{Name: "DISC_LABEL", Value: "THE_MATRIX"},
+				{Name: "DATE_ENCODED", Value: today},
+				{Name: "MKVBOT_VERSION", Value: "1.2.3"},
+			},
+		},
+		{
+			name: "movie from the history without a year",
+			rec:  &history.Record{DiscName: "A_MOVIE", Name: "A Movie"},
+			md:   &moviedb.MovieMetadata{Name: "A Movie", ID: "imdb-tt0000001"},
+			expected: []*matroska.Tag{
+				{Name: "TITLE", Value: "A Movie"},
+				{Name: "IMDB", Value: "tt0000001"},
+				{Name: "DISC_LABEL", Value: "A_MOVIE"},
+				{Name: "DATE_ENCODED", Value: today},
+				{Name: "MKVBOT_VERSION", Value: "1.2.3"},
+			},
+		},
+		{
+			name:        "episode",
+			rec:         &history.Record{DiscName: "BREAKING_BAD_S1_D1", Name: "Breaking Bad", Year: 2008, Season: 1, Episode: 2},
+			md:          &moviedb.MovieMetadata{Name: "Breaking Bad", Year: 2008, ID: "tmdb-1396", IMDbID: "tt0903747"},
+			episodeName: "Cat's in the Bag...",
+			expected: []*matroska.Tag{
+				{Name: "TITLE", Value: "Cat's in the Bag..."},
+				{Name: "PART_NUMBER", Value: "2"},
+				{Name: "IMDB", Value: "tt0903747"},
+				{Name: "TMDB", Value: "tv/1396"},
+				{Name: "DISC_LABEL", Value: "BREAKING_BAD_S1_D1"},
+				{Name: "DATE_ENCODED", Value: today},
+				{Name: "MKVBOT_VERSION", Value: "1.2.3"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			version := Version
+			Version = "1.2.3"
+			defer func() { Version = version }()
+
+			assert.Equal(t, tc.expected, titleTags(tc.rec, tc.md, tc.episodeName))
+		})
+	}
+}
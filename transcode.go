@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/ffmpeg"
+	"github.com/curt-hash/mkvbot/pkg/jobs"
+)
+
+// newTranscodeJob returns a job that transcodes the title at path according
+// to the configured preset.
+func (app *application) newTranscodeJob(path string, duration time.Duration) *jobs.Job {
+	return &jobs.Job{
+		Kind:        jobKindTranscode,
+		Description: filepath.Base(path),
+		Params: map[string]string{
+			"path":         path,
+			"preset":       app.cfg.transcodePreset,
+			"duration":     duration.String(),
+			"keepOriginal": strconv.FormatBool(app.cfg.keepOriginal),
+		},
+	}
+}
+
+// transcode replaces the job's file with the transcoded file. The original is
+// kept next to it as the hidden file .NAME.original.mkv if the job says so.
+// The transcoded file is written to a hidden file as well, so that neither is
+// picked up by a media server that scans the directory.
+func (app *application) transcode(ctx context.Context, job *jobs.Job, progress func(float64)) error {
+	f, err := app.ffmpeg()
+	if err != nil {
+		return fmt.Errorf("initialize ffmpeg: %w", err)
+	}
+
+	preset, err := ffmpeg.GetPreset(job.Params["preset"])
+	if err != nil {
+		return err
+	}
+
+	// The duration is only used to report the progress.
+	duration, _ := time.ParseDuration(job.Params["duration"])
+	keepOriginal, _ := strconv.ParseBool(job.Params["keepOriginal"])
+
+	path := job.Params["path"]
+	tmpPath := hiddenPath(path, "transcoding")
+
+	if err := f.Transcode(ctx, path, tmpPath, preset, duration, progress); err != nil {
+		return err
+	}
+
+	if keepOriginal {
+		originalPath := originalPath(path)
+		if err := os.Rename(path, originalPath); err != nil {
+			return fmt.Errorf("rename %q to %q: %w", path, originalPath, err)
+		}
+	} else if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove original: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename %q to %q: %w", tmpPath, path, err)
+	}
+
+	return nil
+}
+
+// originalPath returns the path of the original that is kept when the title at
+// path is transcoded.
+func originalPath(path string) string {
+	return hiddenPath(path, "original")
+}
+
+// hiddenPath returns the path of a hidden file next to path, named like
+// .NAME.SUFFIX.mkv for NAME.mkv.
+func hiddenPath(path, suffix string) string {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	return filepath.Join(dir, "."+strings.TrimSuffix(name, ext)+"."+suffix+ext)
+}
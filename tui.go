@@ -5,11 +5,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/jobs"
 	"github.com/curt-hash/mkvbot/pkg/makemkv"
 	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
 	"github.com/curt-hash/mkvbot/pkg/moviedb"
@@ -20,7 +26,13 @@ import (
 const (
 	userInputPageName   = "userInputPage"
 	chooseTitlePageName = "chooseTitlePage"
+	chooseMoviePageName = "chooseMoviePage"
+	extrasPageName      = "extrasPage"
 	logsPageName        = "logsPage"
+	jobsPageName        = "jobsPage"
+
+	logsPageTitle = "Logs (F2: jobs)"
+	jobsPageTitle = "Jobs (r: retry, c: cancel, F2: logs)"
 
 	progressBarFullChar  = '█'
 	progressBarEmptyChar = '░'
@@ -31,24 +43,31 @@ type textUserInterface struct {
 	*tview.Application
 
 	interruptChan chan struct{}
+	prompts       promptQueue
 
 	// Top
-	statusBox *statusBox
+	statusFlex *tview.Flex
+	statusBox  *statusBox
 
 	// Left
-	leftFlex         *tview.Flex
-	driveInfoBox     *tview.TextView
-	discInfoBox      *tview.TextView
-	movieMetadataBox *tview.TextView
-	titleInfoBox     *tview.TextView
+	drivePages *tview.Pages
+
+	// sourcePages are the drive pages of the views that have a source, as
+	// opposed to, e.g., the jobs view. It is only used by the UI goroutine.
+	sourcePages map[string]bool
 
 	// Right
 	userInputIntroText *tview.TextView
 	userInputForm      *tview.Form
 	logBox             *tview.TextView
+	jobsTable          *tview.Table
 	pages              *tview.Pages
+
+	jobs *jobs.Queue
 }
 
+var _ userInterface = (*textUserInterface)(nil)
+
 func newTextUserInterface(beeper *beeper) *textUserInterface {
 	app := tview.NewApplication()
 
@@ -58,29 +77,10 @@ func newTextUserInterface(beeper *beeper) *textUserInterface {
 	closeOnce := sync.OnceFunc(func() {
 		close(interruptChan)
 	})
-	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyCtrlC:
-			closeOnce()
-			return nil
-		default:
-			return event
-		}
-	})
-
-	statusBox := newStatusBox()
-
-	driveInfoBox := tview.NewTextView().SetWrap(false)
-	driveInfoBox.SetBorder(true).SetTitle("Drive Information")
-
-	discInfoBox := tview.NewTextView().SetWrap(false)
-	discInfoBox.SetBorder(true).SetTitle("Disc Information")
-
-	movieMetadataBox := tview.NewTextView().SetWrap(false)
-	movieMetadataBox.SetBorder(true).SetTitle("Movie Metadata")
+	statusBox := newStatusBox("Status")
+	statusFlex := tview.NewFlex().AddItem(statusBox.box, 0, 1, false)
 
-	titleInfoBox := tview.NewTextView().SetWrap(true)
-	titleInfoBox.SetBorder(true).SetTitle("Title Information")
+	drivePages := tview.NewPages()
 
 	userInputIntroText := tview.NewTextView().SetWrap(true)
 	userInputForm := tview.NewForm()
@@ -99,21 +99,14 @@ func newTextUserInterface(beeper *beeper) *textUserInterface {
 			false,
 		).
 		AddPage(logsPageName, logBox, true, true)
-	pages.SetBorder(true)
-
-	leftFlex := tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(driveInfoBox, 4, 0, false).
-		AddItem(discInfoBox, 10, 0, false).
-		AddItem(movieMetadataBox, 5, 0, false).
-		AddItem(titleInfoBox, 0, 40, false)
+	pages.SetBorder(true).SetTitle(logsPageTitle)
 
 	flex := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(statusBox.box, 7, 0, false).
+		AddItem(statusFlex, 7, 0, false).
 		AddItem(
 			tview.NewFlex().
-				AddItem(leftFlex, 0, 40, false).
+				AddItem(drivePages, 0, 40, false).
 				AddItem(pages, 0, 60, false),
 			0,
 			80,
@@ -122,26 +115,131 @@ func newTextUserInterface(beeper *beeper) *textUserInterface {
 
 	app.SetRoot(flex, true).SetFocus(flex)
 
-	return &textUserInterface{
+	t := &textUserInterface{
 		beeper: beeper,
 
 		Application: app,
 
 		interruptChan: interruptChan,
+		prompts:       make(promptQueue, 1),
 
-		statusBox: statusBox,
+		statusFlex: statusFlex,
+		statusBox:  statusBox,
 
-		leftFlex:         leftFlex,
-		driveInfoBox:     driveInfoBox,
-		discInfoBox:      discInfoBox,
-		movieMetadataBox: movieMetadataBox,
-		titleInfoBox:     titleInfoBox,
+		drivePages:  drivePages,
+		sourcePages: make(map[string]bool),
 
 		userInputIntroText: userInputIntroText,
 		userInputForm:      userInputForm,
 		logBox:             logBox,
+		jobsTable:          tview.NewTable().SetSelectable(true, false),
 		pages:              pages,
 	}
+	pages.AddPage(jobsPageName, t.jobsTable, true, false)
+
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyCtrlC:
+			closeOnce()
+			return nil
+		case tcell.KeyF2:
+			t.toggleJobsPage(flex)
+			return nil
+		default:
+			return event
+		}
+	})
+
+	return t
+}
+
+// showJobs lists the jobs of q on the jobs page, where they can be retried and
+// canceled.
+func (t *textUserInterface) showJobs(q *jobs.Queue) {
+	t.jobs = q
+	t.jobsTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		var action func(id int) error
+		switch event.Rune() {
+		case 'r':
+			action = q.Retry
+		case 'c':
+			action = q.Cancel
+		default:
+			return event
+		}
+
+		row, _ := t.jobsTable.GetSelection()
+		if id, ok := t.jobsTable.GetCell(row, 0).GetReference().(int); ok {
+			// The queue calls updateJobs, which must not block the UI
+			// goroutine.
+			go func() {
+				if err := action(id); err != nil {
+					slog.Error("failed to change job", "job", id, "err", err)
+				}
+			}()
+		}
+
+		return nil
+	})
+
+	t.updateJobs()
+}
+
+// updateJobs refreshes the jobs page.
+func (t *textUserInterface) updateJobs() {
+	all := t.jobs.Jobs()
+	t.QueueUpdateDraw(func() {
+		table := t.jobsTable
+		row, _ := table.GetSelection()
+		selected, _ := table.GetCell(row, 0).GetReference().(int)
+
+		table.Clear()
+		for i, s := range []string{"ID", "Kind", "State", "Job", "Error"} {
+			table.SetCell(0, i, tview.NewTableCell(s).SetSelectable(false))
+		}
+		table.SetCell(0, 3, table.GetCell(0, 3).SetExpansion(1))
+
+		// Newest first.
+		row = 1
+		for i := len(all) - 1; i >= 0; i-- {
+			job := all[i]
+			state := string(job.State)
+			if job.State == jobs.StateRunning {
+				state = fmt.Sprintf("%s %d%%", state, int(job.Progress*100))
+			}
+			color := tview.Styles.PrimaryTextColor
+			if job.State == jobs.StateFailed {
+				color = tcell.ColorRed
+			}
+
+			r := len(all) - i
+			table.SetCell(r, 0, tview.NewTableCell(strconv.Itoa(job.ID)).SetReference(job.ID).SetTextColor(color))
+			table.SetCell(r, 1, tview.NewTableCell(job.Kind).SetTextColor(color))
+			table.SetCell(r, 2, tview.NewTableCell(state).SetTextColor(color))
+			table.SetCell(r, 3, tview.NewTableCell(job.Description).SetTextColor(color))
+			table.SetCell(r, 4, tview.NewTableCell(job.Error).SetTextColor(color))
+			if job.ID == selected {
+				row = r
+			}
+		}
+
+		table.Select(row, 0)
+	})
+}
+
+// toggleJobsPage switches between the logs page and the jobs page, unless the
+// user is being prompted. It must be called from the UI goroutine.
+func (t *textUserInterface) toggleJobsPage(root tview.Primitive) {
+	switch name, _ := t.pages.GetFrontPage(); name {
+	case logsPageName:
+		t.pages.SwitchToPage(jobsPageName)
+		t.pages.SetTitle(jobsPageTitle)
+		t.SetFocus(t.jobsTable)
+	case jobsPageName:
+		t.pages.SwitchToPage(logsPageName)
+		t.pages.SetTitle(logsPageTitle)
+		t.SetFocus(root)
+	}
 }
 
 // waitForInterrupt returns after Ctrl+C.
@@ -153,39 +251,158 @@ func (t *textUserInterface) run() error {
 	return t.Run()
 }
 
-func (t *textUserInterface) setDriveInfo(name, volume string) {
+func (t *textUserInterface) logWriter() io.Writer {
+	return t.logBox
+}
+
+// setStatus sets the application status, which is shown until the first drive
+// is added.
+func (t *textUserInterface) setStatus(format string, args ...any) {
+	s := fmt.Sprintf(format, args...)
 	t.QueueUpdateDraw(func() {
-		t.driveInfoBox.SetText(fmt.Sprintf("Name: %s\nVolume: %s", name, volume))
+		if t.statusBox != nil {
+			t.statusBox.setStatus(s)
+			t.statusBox.update()
+		}
 	})
 }
 
-func (t *textUserInterface) setStatus(format string, args ...any) {
-	t.statusBox.setStatus(fmt.Sprintf(format, args...))
-	t.updateStatusBox()
+// addView adds a status box and an information panel with the given name,
+// e.g., "Drive 0", and returns the view that controls them.
+func (t *textUserInterface) addView(name string) driveUI {
+	v := newDriveView(t, name)
+
+	t.QueueUpdateDraw(func() {
+		if t.statusBox != nil {
+			t.statusFlex.RemoveItem(t.statusBox.box)
+			t.statusBox = nil
+		}
+		t.statusFlex.AddItem(v.statusBox.box, 0, 1, false)
+		t.drivePages.AddPage(v.pageName, v.flex, true, t.drivePages.GetPageCount() == 0)
+	})
+
+	return v
 }
 
-func (t *textUserInterface) setTask(format string, args ...any) {
-	t.statusBox.setTask(fmt.Sprintf(format, args...))
-	t.updateStatusBox()
+// promptQueue serializes prompts so that concurrent drive loops take turns
+// asking the user for input. Goroutines blocked on a channel send are queued
+// in order, so prompts are shown first come, first served.
+type promptQueue chan struct{}
+
+func (q promptQueue) acquire(ctx context.Context) error {
+	select {
+	case q <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (t *textUserInterface) setSubtask(format string, args ...any) {
-	t.statusBox.setSubtask(fmt.Sprintf(format, args...))
-	t.updateStatusBox()
+func (q promptQueue) release() {
+	<-q
 }
 
-func (t *textUserInterface) setProgress(progress float64) {
-	t.statusBox.progress = progress
-	t.updateStatusBox()
+// driveView is the part of the text user interface dedicated to one drive or
+// to the discs of the process command.
+type driveView struct {
+	tui *textUserInterface
+
+	name     string
+	pageName string
+
+	statusBox *statusBox
+
+	flex             *tview.Flex
+	driveInfoBox     *tview.TextView
+	discInfoBox      *tview.TextView
+	movieMetadataBox *tview.TextView
+	titleInfoBox     *tview.TextView
 }
 
-func (t *textUserInterface) updateStatusBox() {
-	t.QueueUpdateDraw(t.statusBox.update)
+var _ driveUI = (*driveView)(nil)
+
+func newDriveView(t *textUserInterface, name string) *driveView {
+	driveInfoBox := tview.NewTextView().SetWrap(false)
+	driveInfoBox.SetBorder(true).SetTitle("Source Information")
+
+	discInfoBox := tview.NewTextView().SetWrap(false)
+	discInfoBox.SetBorder(true).SetTitle("Disc Information")
+
+	movieMetadataBox := tview.NewTextView().SetWrap(false)
+	movieMetadataBox.SetBorder(true).SetTitle("Movie Metadata")
+
+	titleInfoBox := tview.NewTextView().SetWrap(true)
+	titleInfoBox.SetBorder(true).SetTitle("Title Information")
+
+	flex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(driveInfoBox, 4, 0, false).
+		AddItem(discInfoBox, 10, 0, false).
+		AddItem(movieMetadataBox, 5, 0, false).
+		AddItem(titleInfoBox, 0, 40, false)
+
+	return &driveView{
+		tui: t,
+
+		name:     name,
+		pageName: name + " page",
+
+		statusBox: newStatusBox(name),
+
+		flex:             flex,
+		driveInfoBox:     driveInfoBox,
+		discInfoBox:      discInfoBox,
+		movieMetadataBox: movieMetadataBox,
+		titleInfoBox:     titleInfoBox,
+	}
 }
 
-func (t *textUserInterface) setDiscInfo(info makemkv.Info) {
-	t.QueueUpdateDraw(func() {
-		w := t.discInfoBox.BatchWriter()
+func (v *driveView) setStatus(format string, args ...any) {
+	v.statusBox.setStatus(fmt.Sprintf(format, args...))
+	v.updateStatusBox()
+}
+
+func (v *driveView) setTask(format string, args ...any) {
+	v.statusBox.setTask(fmt.Sprintf(format, args...))
+	v.updateStatusBox()
+}
+
+func (v *driveView) setSubtask(format string, args ...any) {
+	v.statusBox.setSubtask(fmt.Sprintf(format, args...))
+	v.updateStatusBox()
+}
+
+func (v *driveView) setProgress(progress float64) {
+	v.statusBox.progress = progress
+	v.updateStatusBox()
+}
+
+func (v *driveView) updateStatusBox() {
+	v.tui.QueueUpdateDraw(v.statusBox.update)
+}
+
+// setSource shows the source. The view's page replaces the page of a view
+// without a source, which may have been added first.
+func (v *driveView) setSource(src *discSource) {
+	v.tui.QueueUpdateDraw(func() {
+		if front, _ := v.tui.drivePages.GetFrontPage(); !v.tui.sourcePages[front] {
+			v.tui.drivePages.SwitchToPage(v.pageName)
+		}
+		v.tui.sourcePages[v.pageName] = true
+
+		if src.Type == makemkv.SourceTypeDrive {
+			v.statusBox.box.SetTitle(fmt.Sprintf("%s: %s", v.name, src.name))
+			v.driveInfoBox.SetText(fmt.Sprintf("Name: %s\nVolume: %s", src.name, src.volume))
+		} else {
+			v.statusBox.box.SetTitle(fmt.Sprintf("%s: %s", v.name, filepath.Base(src.Path)))
+			v.driveInfoBox.SetText(fmt.Sprintf("Path: %s", src.Path))
+		}
+	})
+}
+
+func (v *driveView) setDiscInfo(info makemkv.Info) {
+	v.tui.QueueUpdateDraw(func() {
+		w := v.discInfoBox.BatchWriter()
 		defer w.Close()
 
 		w.Clear()
@@ -198,12 +415,40 @@ func (t *textUserInterface) setDiscInfo(info makemkv.Info) {
 		}
 
 		if n := len(info); n > 0 {
-			t.leftFlex.ResizeItem(t.discInfoBox, n+2, 0)
+			v.flex.ResizeItem(v.discInfoBox, n+2, 0)
 		}
 	})
 }
 
-func (t *textUserInterface) getMovieTitleForSearch(ctx context.Context, q string) (string, error) {
+// beginPrompt waits for the drive's turn to prompt the user and then shows
+// the drive's information panel. The returned function must be called when
+// the prompt is done.
+func (v *driveView) beginPrompt(ctx context.Context) (func(), error) {
+	if err := v.tui.prompts.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	v.tui.QueueUpdateDraw(func() {
+		v.tui.drivePages.SwitchToPage(v.pageName)
+		v.tui.pages.SetTitle(v.name)
+	})
+
+	return func() {
+		v.tui.QueueUpdateDraw(func() {
+			v.tui.pages.SetTitle(logsPageTitle)
+		})
+		v.tui.prompts.release()
+	}, nil
+}
+
+func (v *driveView) getMovieTitleForSearch(ctx context.Context, q string) (string, error) {
+	endPrompt, err := v.beginPrompt(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer endPrompt()
+
+	t := v.tui
 	continueChan := make(chan struct{})
 
 	t.QueueUpdateDraw(func() {
@@ -234,14 +479,132 @@ func (t *textUserInterface) getMovieTitleForSearch(ctx context.Context, q string
 	return t.userInputForm.GetFormItemByLabel("Query").(*tview.InputField).GetText(), nil
 }
 
-func (t *textUserInterface) getMovieMetadata(ctx context.Context, md *moviedb.MovieMetadata) (*moviedb.MovieMetadata, error) {
+// chooseMovieMetadata shows the search results in a table. The user can search
+// again with a different query before choosing a result, or choose none of
+// them to use the query as the movie name.
+func (v *driveView) chooseMovieMetadata(ctx context.Context, q string, results []*moviedb.MovieMetadata, search movieSearchFunc) (*moviedb.MovieMetadata, error) {
+	endPrompt, err := v.beginPrompt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer endPrompt()
+
+	t := v.tui
+	chosenChan := make(chan *moviedb.MovieMetadata, 1)
+
+	const instructions = "Use the arrow keys to highlight a result and press Enter to choose it. Press Tab to edit the query and Enter to search again."
+	intro := tview.NewTextView().SetWrap(true)
+	queryInput := tview.NewInputField().SetLabel("Query ").SetText(q)
+	table := tview.NewTable().SetSelectable(true, false)
+
+	// setResults fills the table. It must be called from the UI goroutine.
+	setResults := func(q string, results []*moviedb.MovieMetadata) {
+		table.Clear()
+		for i, s := range []string{"Name", "Year", "ID", "Kind"} {
+			table.SetCell(0, i, tview.NewTableCell(s).SetSelectable(false).SetExpansion(1))
+		}
+
+		for i, md := range results {
+			r := i + 1
+			table.SetCellSimple(r, 0, md.Name)
+			table.SetCellSimple(r, 1, strconv.Itoa(md.Year))
+			table.SetCellSimple(r, 2, md.ID)
+			table.SetCellSimple(r, 3, md.Kind)
+		}
+
+		table.SetCellSimple(len(results)+1, 0, fmt.Sprintf("None of these (use %q)", q))
+		table.SetSelectedFunc(func(r, _ int) {
+			md := &moviedb.MovieMetadata{
+				Name:   q,
+				Source: metadataSourceQuery,
+			}
+			if r >= 1 && r <= len(results) {
+				md = results[r-1]
+			}
+
+			select {
+			case chosenChan <- md:
+			default:
+			}
+		})
+		table.Select(1, 0).ScrollToBeginning()
+	}
+
+	queryInput.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			q := queryInput.GetText()
+			intro.SetText(fmt.Sprintf("Searching for %q...", q))
+			go func() {
+				results, err := search(ctx, q)
+				t.QueueUpdateDraw(func() {
+					if err != nil {
+						intro.SetText(fmt.Sprintf("Search failed: %s\n\n%s", err, instructions))
+					} else {
+						intro.SetText(instructions)
+					}
+					setResults(q, results)
+					t.SetFocus(table)
+				})
+			}()
+		case tcell.KeyTab, tcell.KeyBacktab, tcell.KeyEscape:
+			t.SetFocus(table)
+		}
+	})
+	table.SetDoneFunc(func(tcell.Key) {
+		t.SetFocus(queryInput)
+	})
+
+	flex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(intro, 5, 0, false).
+		AddItem(queryInput, 2, 0, false).
+		AddItem(table, 0, 100, true)
+
+	t.QueueUpdateDraw(func() {
+		intro.SetText(instructions)
+		setResults(q, results)
+		t.pages.AddAndSwitchToPage(chooseMoviePageName, flex, true)
+		t.SetFocus(table)
+	})
+
+	t.beep()
+
+	var md *moviedb.MovieMetadata
+	select {
+	case md = <-chosenChan:
+		t.QueueUpdateDraw(func() {
+			t.pages.RemovePage(chooseMoviePageName)
+			t.pages.SwitchToPage(logsPageName)
+		})
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// The caller may modify the result.
+	chosen := *md
+	return &chosen, nil
+}
+
+func (v *driveView) getMovieMetadata(ctx context.Context, _ string, md *moviedb.MovieMetadata) (*moviedb.MovieMetadata, error) {
+	endPrompt, err := v.beginPrompt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer endPrompt()
+
+	t := v.tui
 	continueChan := make(chan struct{})
 
 	t.QueueUpdateDraw(func() {
 		var buf strings.Builder
 		fmt.Fprintf(&buf, "Correct the movie metadata below if necessary and then hit Continue.")
-		if md.ID != "" {
-			fmt.Fprintf(&buf, "\n\nhttps://www.imdb.com/title/%s/", strings.TrimPrefix(md.ID, "imdb-"))
+		if id, ok := strings.CutPrefix(md.ID, "imdb-"); ok {
+			fmt.Fprintf(&buf, "\n\nhttps://www.imdb.com/title/%s/", id)
+		} else if id, ok := strings.CutPrefix(md.ID, "tmdb-"); ok && md.Kind == "tvSeries" {
+			fmt.Fprintf(&buf, "\n\nhttps://www.themoviedb.org/tv/%s", id)
+		} else if ok {
+			fmt.Fprintf(&buf, "\n\nhttps://www.themoviedb.org/movie/%s", id)
 		}
 		t.userInputIntroText.SetText(buf.String())
 		t.userInputForm.
@@ -282,18 +645,25 @@ func (t *textUserInterface) getMovieMetadata(ctx context.Context, md *moviedb.Mo
 	return md, nil
 }
 
-func (t *textUserInterface) setMovieMetadata(md *moviedb.MovieMetadata) {
+func (v *driveView) setMovieMetadata(md *moviedb.MovieMetadata) {
 	var s string
 	if md != nil {
 		s = fmt.Sprintf("Name: %s\nYear: %d\nTag: %s", md.Name, md.Year, md.ID)
 	}
 
-	t.QueueUpdateDraw(func() {
-		t.movieMetadataBox.SetText(s)
+	v.tui.QueueUpdateDraw(func() {
+		v.movieMetadataBox.SetText(s)
 	})
 }
 
-func (t *textUserInterface) getBestTitle(ctx context.Context, choices []*makemkv.Title) (*makemkv.Title, error) {
+func (v *driveView) getBestTitle(ctx context.Context, choices []*makemkv.Title) (*makemkv.Title, error) {
+	endPrompt, err := v.beginPrompt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer endPrompt()
+
+	t := v.tui
 	continueChan := make(chan struct{})
 
 	var index int
@@ -302,7 +672,7 @@ func (t *textUserInterface) getBestTitle(ctx context.Context, choices []*makemkv
 		SetSelectionChangedFunc(func(r, _ int) {
 			index = r - 1
 			if index >= 0 && index < len(choices) {
-				t.setTitleInfoFunc(choices[index])()
+				v.setTitleInfoFunc(choices[index])()
 			}
 		}).
 		SetSelectedFunc(func(r, _ int) {
@@ -351,7 +721,7 @@ func (t *textUserInterface) getBestTitle(ctx context.Context, choices []*makemkv
 		).
 		AddItem(table, 0, 100, true)
 
-	t.setTitleInfo(choices[0])
+	v.setTitleInfo(choices[0])
 	t.QueueUpdateDraw(func() {
 		t.pages.AddAndSwitchToPage(chooseTitlePageName, flex, true)
 		t.SetFocus(t.pages)
@@ -376,9 +746,233 @@ func (t *textUserInterface) getBestTitle(ctx context.Context, choices []*makemkv
 	return choices[index], nil
 }
 
-func (t *textUserInterface) setTitleInfoFunc(title *makemkv.Title) func() {
+func (v *driveView) getDuplicateAction(ctx context.Context, prior []*history.Record) (string, string, error) {
+	endPrompt, err := v.beginPrompt(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer endPrompt()
+
+	t := v.tui
+	actionChan := make(chan string, 1)
+	choose := func(action string) {
+		select {
+		case actionChan <- action:
+		default:
+		}
+	}
+
+	t.QueueUpdateDraw(func() {
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "This disc was ripped before:\n")
+		for _, r := range prior {
+			fmt.Fprintf(&buf, "\n%s  %s (%d)  %s", r.EndTime.Format(time.DateTime), r.Name, r.Year, r.OutputPath)
+		}
+		fmt.Fprintf(&buf, "\n\nSkip it, rip it again replacing the previous output, or rip it as a new edition.")
+		t.userInputIntroText.SetText(buf.String())
+		t.userInputForm.
+			Clear(true).
+			AddInputField("Edition", defaultEdition(len(prior)), 0, nil, nil).
+			AddButton("Skip", func() {
+				choose(duplicateActionSkip)
+			}).
+			AddButton("Re-rip", func() {
+				choose(duplicateActionRerip)
+			}).
+			AddButton("Rip as new edition", func() {
+				choose(duplicateActionNewEdition)
+			})
+		t.userInputForm.SetFocus(1)
+
+		t.pages.SwitchToPage(userInputPageName)
+		t.SetFocus(t.pages)
+	})
+
+	t.beep()
+
+	var action string
+	select {
+	case action = <-actionChan:
+		t.QueueUpdateDraw(func() {
+			t.pages.SwitchToPage(logsPageName)
+		})
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+
+	edition := ""
+	if action == duplicateActionNewEdition {
+		edition = t.userInputForm.GetFormItemByLabel("Edition").(*tview.InputField).GetText()
+	}
+
+	return action, edition, nil
+}
+
+// classifyExtras shows the extras in a table in which the user sets the
+// category of each one with a key press.
+func (v *driveView) classifyExtras(ctx context.Context, extras []*makemkv.Title) ([]string, error) {
+	endPrompt, err := v.beginPrompt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer endPrompt()
+
+	t := v.tui
+	continueChan := make(chan struct{})
+
+	// keys are the keys that set the categories, which are in the same order
+	// as extraCategories.
+	keys := []rune{'f', 'b', 'd', 'o', 's'}
+
+	categories := make([]string, len(extras))
+	for i := range categories {
+		categories[i] = extraCategoryOther
+	}
+
+	table := tview.NewTable().SetSelectable(true, false)
+	for i, s := range []string{"Index", "Duration", "Size", "Chapters", "Category"} {
+		table.SetCell(0, i, tview.NewTableCell(s).SetSelectable(false).SetExpansion(1))
+	}
+	for i, title := range extras {
+		r := i + 1
+		table.SetCellSimple(r, 0, strconv.Itoa(title.Index))
+		table.SetCellSimple(r, 1, title.GetAttrDefault(defs.Duration, "-"))
+		table.SetCellSimple(r, 2, title.GetAttrDefault(defs.DiskSize, "-"))
+		table.SetCellSimple(r, 3, title.GetAttrDefault(defs.ChapterCount, "-"))
+		table.SetCellSimple(r, 4, categories[i])
+	}
+	table.SetCellSimple(len(extras)+1, 0, "Continue")
+
+	setCategory := func(r int, category string) {
+		if r >= 1 && r <= len(extras) {
+			categories[r-1] = category
+			table.GetCell(r, 4).SetText(category)
+		}
+	}
+
+	table.
+		SetSelectionChangedFunc(func(r, _ int) {
+			if r >= 1 && r <= len(extras) {
+				v.setTitleInfoFunc(extras[r-1])()
+			}
+		}).
+		SetSelectedFunc(func(r, _ int) {
+			if r == len(extras)+1 {
+				close(continueChan)
+				return
+			}
+
+			// Cycle through the categories.
+			i := slices.Index(extraCategories, categories[r-1])
+			setCategory(r, extraCategories[(i+1)%len(extraCategories)])
+		}).
+		SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if i := slices.Index(keys, unicode.ToLower(event.Rune())); i >= 0 {
+				r, _ := table.GetSelection()
+				setCategory(r, extraCategories[i])
+				return nil
+			}
+
+			return event
+		})
+
+	var legend []string
+	for i, category := range extraCategories {
+		legend = append(legend, fmt.Sprintf("%c = %s", keys[i], category))
+	}
+
+	flex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(
+			tview.NewTextView().
+				SetWrap(true).
+				SetText(fmt.Sprintf("The disc has %d extras. Information about the highlighted title is shown to the left. Set the category of each one with a key (%s) or Enter, then choose Continue.", len(extras), strings.Join(legend, ", "))),
+			5,
+			0,
+			false,
+		).
+		AddItem(table, 0, 100, true)
+
+	v.setTitleInfo(extras[0])
+	t.QueueUpdateDraw(func() {
+		t.pages.AddAndSwitchToPage(extrasPageName, flex, true)
+		t.SetFocus(t.pages)
+	})
+
+	t.beep()
+
+	select {
+	case <-continueChan:
+		t.QueueUpdateDraw(func() {
+			t.pages.RemovePage(extrasPageName)
+			t.pages.SwitchToPage(logsPageName)
+		})
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return categories, nil
+}
+
+func (v *driveView) getEpisodeNumbering(ctx context.Context, series *moviedb.MovieMetadata, episodes []*makemkv.Title, season, firstEpisode int) (int, int, error) {
+	endPrompt, err := v.beginPrompt(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer endPrompt()
+
+	t := v.tui
+	continueChan := make(chan struct{})
+	isDigit := func(textToCheck string, lastChar rune) bool {
+		return len(textToCheck) <= 3 && unicode.IsDigit(lastChar)
+	}
+
+	var numbers [2]int
+	t.QueueUpdateDraw(func() {
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "Found %d episodes of %s. Correct the season and the number of the first episode if necessary and then hit Continue.\n", len(episodes), series.Name)
+		for _, title := range episodes {
+			fmt.Fprintf(&buf, "\nTitle %d  %s", title.Index, title.GetAttrDefault(defs.Duration, "?"))
+		}
+		t.userInputIntroText.SetText(buf.String())
+		t.userInputForm.
+			Clear(true).
+			AddInputField("Season", strconv.Itoa(season), 4, isDigit, nil).
+			AddInputField("First episode", strconv.Itoa(firstEpisode), 4, isDigit, nil).
+			AddButton("Continue", func() {
+				for i, label := range []string{"Season", "First episode"} {
+					n, err := strconv.Atoi(t.userInputForm.GetFormItemByLabel(label).(*tview.InputField).GetText())
+					if err != nil || n < 0 {
+						t.userInputForm.SetFocus(i)
+						return
+					}
+					numbers[i] = n
+				}
+				close(continueChan)
+			})
+		t.userInputForm.SetFocus(2)
+
+		t.pages.SwitchToPage(userInputPageName)
+		t.SetFocus(t.pages)
+	})
+
+	t.beep()
+
+	select {
+	case <-continueChan:
+		t.QueueUpdateDraw(func() {
+			t.pages.SwitchToPage(logsPageName)
+		})
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+
+	return numbers[0], numbers[1], nil
+}
+
+func (v *driveView) setTitleInfoFunc(title *makemkv.Title) func() {
 	return func() {
-		w := t.titleInfoBox.BatchWriter()
+		w := v.titleInfoBox.BatchWriter()
 		defer w.Close()
 
 		w.Clear()
@@ -388,8 +982,8 @@ func (t *textUserInterface) setTitleInfoFunc(title *makemkv.Title) func() {
 	}
 }
 
-func (t *textUserInterface) setTitleInfo(title *makemkv.Title) {
-	t.QueueUpdateDraw(t.setTitleInfoFunc(title))
+func (v *driveView) setTitleInfo(title *makemkv.Title) {
+	v.tui.QueueUpdateDraw(v.setTitleInfoFunc(title))
 }
 
 type statusBox struct {
@@ -400,9 +994,9 @@ type statusBox struct {
 	progress float64
 }
 
-func newStatusBox() *statusBox {
+func newStatusBox(title string) *statusBox {
 	box := tview.NewTextView().SetWrap(false)
-	box.SetBorder(true).SetTitle("Status")
+	box.SetBorder(true).SetTitle(title)
 
 	return &statusBox{
 		box:      box,
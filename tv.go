@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/history"
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/moviedb"
+)
+
+// tvMinLengthSeconds replaces the default --minlength in TV mode, since
+// episodes are often shorter than the default.
+const tvMinLengthSeconds = 600
+
+var (
+	// seasonPattern matches the season number in a disc name, e.g.,
+	// "SHOW_S2_D1" or "Show Season 2 Disc 1".
+	seasonPattern = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:season|series|s)[ _.-]*(\d{1,2})(?:[^0-9]|$)`)
+
+	// discNumberPattern matches the disc number in a disc name.
+	discNumberPattern = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:disc|disk|d)[ _.-]*\d{1,2}(?:[^0-9]|$)`)
+)
+
+// seasonFromDiscName returns the season number in the disc name or 0 if there
+// is none.
+func seasonFromDiscName(name string) int {
+	m := seasonPattern.FindStringSubmatch(name)
+	if m == nil {
+		return 0
+	}
+
+	season, _ := strconv.Atoi(m[1])
+	return season
+}
+
+// seriesQuery returns the disc name without the season and disc numbers, for
+// searching the movie database.
+func seriesQuery(name string) string {
+	q := seasonPattern.ReplaceAllString(name, " ")
+	q = discNumberPattern.ReplaceAllString(q, " ")
+	q = regexp.MustCompile("[^a-zA-Z0-9 ]+").ReplaceAllString(q, " ")
+	if q = strings.Join(strings.Fields(q), " "); q == "" {
+		return name
+	}
+
+	return q
+}
+
+// backupEpisodes backs up every episode title of the disc. Episodes are
+// numbered after the last episode of the season in the history, so that
+// numbering continues across the discs of a season. Each episode gets its own
+// history record. If only some of the episodes of the disc were backed up
+// before, e.g., because one failed, the others are backed up without asking.
+func (app *application) backupEpisodes(ctx context.Context, src *discSource, view driveUI, disc *makemkv.Disc) error {
+	view.setStatus("Finding episode titles")
+	episodes := disc.EpisodeTitles()
+	if len(episodes) == 0 {
+		return errors.New("no episode titles found")
+	}
+
+	indexes := make([]int, len(episodes))
+	for i, title := range episodes {
+		indexes[i] = title.Index
+	}
+	slog.Info("found episode titles", "disc", src, "titles", indexes)
+
+	discName, fingerprint := disc.GetAttrDefault(defs.Name, ""), disc.Fingerprint()
+	newRecord := func(titleIndex int) *history.Record {
+		return &history.Record{
+			StartTime:   time.Now(),
+			Drive:       src.name,
+			DiscName:    discName,
+			Fingerprint: fingerprint,
+			TitleIndex:  titleIndex,
+		}
+	}
+
+	prior, err := app.history.FindRipped(fingerprint)
+	if err != nil {
+		return fmt.Errorf("find previous rips: %w", err)
+	}
+
+	var (
+		series        *moviedb.MovieMetadata
+		season, first int
+		edition       string
+		overwrite     bool
+
+		// ripped are the episode numbers that were backed up before, if the
+		// backup of the disc is resumed.
+		ripped map[int]bool
+	)
+	if len(prior) > 0 {
+		last := prior[len(prior)-1]
+		series = &moviedb.MovieMetadata{
+			Name:   last.Name,
+			Year:   last.Year,
+			ID:     last.MovieID,
+			Source: metadataSourceHistory,
+		}
+
+		// Number the episodes as before, unless the disc was ripped as a
+		// movie.
+		season, first = max(seasonFromDiscName(discName), 1), 1
+		if last.Episode > 0 {
+			season, first = last.Season, last.Episode
+			ripped = make(map[int]bool)
+			for _, r := range prior {
+				if r.Season == season && r.Episode > 0 {
+					first = min(first, r.Episode)
+					if r.Edition == last.Edition {
+						ripped[r.Episode] = true
+					}
+				}
+			}
+		}
+
+		missing := 0
+		for i := range episodes {
+			if ripped != nil && !ripped[first+i] {
+				missing++
+			}
+		}
+
+		if missing > 0 {
+			slog.Info("resuming partly ripped disc", "disc", src, "fingerprint", fingerprint, "season", season, "missing", missing)
+			edition = last.Edition
+		} else {
+			ripped = nil
+			slog.Info("disc was ripped before", "disc", src, "fingerprint", fingerprint, "path", last.OutputPath, "count", len(prior))
+
+			view.setStatus("Disc was ripped before")
+			action, e, err := view.getDuplicateAction(ctx, prior)
+			if err != nil {
+				return fmt.Errorf("get duplicate action: %w", err)
+			}
+
+			switch action {
+			case duplicateActionSkip:
+				rec := newRecord(-1)
+				rec.Name, rec.Year, rec.MovieID = series.Name, series.Year, series.ID
+				rec.Outcome = history.OutcomeSkipped
+				app.addHistoryRecord(rec, nil)
+				return app.ejectDisc(ctx, src, view)
+			case duplicateActionRerip:
+				overwrite = true
+			case duplicateActionNewEdition:
+				edition = e
+			}
+		}
+	} else {
+		view.setStatus("Getting series metadata")
+		if series, err = app.lookupMetadata(ctx, view, seriesQuery(discName), app.searchSeriesDB); err != nil {
+			return fmt.Errorf("get series metadata: %w", err)
+		}
+
+		season = max(seasonFromDiscName(discName), 1)
+		last, err := app.history.LastEpisode(series.ID, series.Name, season)
+		if err != nil {
+			return fmt.Errorf("find last episode: %w", err)
+		}
+
+		view.setStatus("Numbering episodes")
+		if season, first, err = view.getEpisodeNumbering(ctx, series, episodes, season, last+1); err != nil {
+			return fmt.Errorf("get episode numbering: %w", err)
+		}
+	}
+	view.setMovieMetadata(series)
+
+	view.setStatus("Getting episode names")
+	names := app.getEpisodeNames(ctx, series, season)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for i, title := range episodes {
+		if ripped[first+i] {
+			continue
+		}
+
+		rec := newRecord(title.Index)
+		rec.Name, rec.Year, rec.MovieID = series.Name, series.Year, series.ID
+		rec.Edition, rec.Season, rec.Episode = edition, season, first+i
+
+		if err := app.backupEpisode(ctx, src, view, disc, title, series, rec, names[rec.Episode], overwrite); err != nil {
+			app.addHistoryRecord(rec, err)
+			return fmt.Errorf("backup episode S%02dE%02d: %w", rec.Season, rec.Episode, err)
+		}
+
+		rec.Outcome = history.OutcomeSuccess
+		app.tagTitle(src, rec.OutputPath, titleTags(rec, series, names[rec.Episode]))
+		sidecars := app.writeSidecars(src, rec, series, disc, title, names[rec.Episode])
+
+		// The hooks update the record once they have run.
+		app.addHistoryRecord(rec, nil)
+		app.queueFollowUpJobs(src, title, rec.OutputPath, sidecars, newHookEvent(rec, series, title))
+	}
+
+	if err := app.ejectDisc(ctx, src, view); err != nil {
+		return err
+	}
+
+	app.ui.beep()
+	return nil
+}
+
+// backupEpisode backs up the episode described by rec and sets its output
+// path.
+func (app *application) backupEpisode(ctx context.Context, src *discSource, view driveUI, disc *makemkv.Disc, title *makemkv.Title, series *moviedb.MovieMetadata, rec *history.Record, episodeName string, overwrite bool) error {
+	view.setTitleInfo(title)
+
+	data := newNameData(series, rec.Edition, disc, title)
+	data.Season, data.Episode, data.EpisodeName = rec.Season, rec.Episode, sanitizeFileName(episodeName)
+	dstPath, err := app.outputPath(app.tvNameTemplate, data)
+	if err != nil {
+		return err
+	}
+	rec.OutputPath = dstPath
+
+	view.setStatus("Backing up episode S%02dE%02d", rec.Season, rec.Episode)
+	return app.backupTitle(ctx, src, view, title, dstPath, overwrite)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeasonFromDiscName(t *testing.T) {
+	for _, tc := range []struct {
+		discName string
+		season   int
+		query    string
+	}{
+		{"SHOW_S2_D1", 2, "SHOW"},
+		{"SHOW_SEASON_3_DISC_2", 3, "SHOW"},
+		{"Show Season 2 Disc 1", 2, "Show"},
+		{"show.series.4.disk.3", 4, "show"},
+		{"BREAKING_BAD_S01_D02", 1, "BREAKING BAD"},
+		{"THE_WIRE_SEASON10", 10, "THE WIRE"},
+		{"THE_SOPRANOS_D1", 0, "THE SOPRANOS"},
+		{"PLANET_EARTH", 0, "PLANET EARTH"},
+		{"S1_D1", 1, "S1_D1"},
+	} {
+		assert.Equal(t, tc.season, seasonFromDiscName(tc.discName), tc.discName)
+		assert.Equal(t, tc.query, seriesQuery(tc.discName), tc.discName)
+	}
+}
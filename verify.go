@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/matroska"
+)
+
+const (
+	// The duration of a ripped title may differ from the duration reported by
+	// makemkvcon by the larger of these.
+	verifyDurationTolerance      = 2 * time.Second
+	verifyDurationToleranceRatio = 0.01
+
+	// The size of a ripped title must be within these ratios of the size
+	// reported by makemkvcon. It is usually a bit smaller, since the MKV
+	// container is leaner than the disc's, and it is smaller still if the
+	// profile's selection string leaves out some tracks.
+	verifyMinSizeRatio = 0.5
+	verifyMaxSizeRatio = 1.1
+)
+
+// diskSizeUnits are the units of the DiskSize attribute, e.g., "28.4 GB".
+var diskSizeUnits = map[string]int64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// verifyTitle checks that the Matroska file at path is a complete rip of the
+// title: its tracks, duration and size must agree with the title's streams and
+// attributes. Since the profile's selection string may leave out audio and
+// subtitle tracks, the file must have the same number of video tracks, but
+// may have fewer (although at least one) audio tracks and fewer subtitle
+// tracks than the title has streams.
+func verifyTitle(title *makemkv.Title, path string) error {
+	f, err := matroska.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	want := make(map[defs.TypeCode]int)
+	for _, stream := range title.Streams {
+		want[stream.Type()]++
+	}
+	video := f.CountTracks(matroska.TrackTypeVideo)
+	audio := f.CountTracks(matroska.TrackTypeAudio)
+	subtitles := f.CountTracks(matroska.TrackTypeSubtitle)
+	if video != want[defs.TypeCodeVideo] {
+		problems = append(problems, fmt.Sprintf("%d video tracks instead of %d", video, want[defs.TypeCodeVideo]))
+	}
+	if audio > want[defs.TypeCodeAudio] || (audio == 0 && want[defs.TypeCodeAudio] > 0) {
+		problems = append(problems, fmt.Sprintf("%d audio tracks instead of 1 to %d", audio, want[defs.TypeCodeAudio]))
+	}
+	if subtitles > want[defs.TypeCodeSubtitles] {
+		problems = append(problems, fmt.Sprintf("%d subtitle tracks instead of at most %d", subtitles, want[defs.TypeCodeSubtitles]))
+	}
+
+	if duration, err := title.GetAttrDuration(defs.Duration); err == nil {
+		tolerance := max(verifyDurationTolerance, time.Duration(float64(duration)*verifyDurationToleranceRatio))
+		if diff := (f.Duration - duration).Abs(); diff > tolerance {
+			problems = append(problems, fmt.Sprintf("duration %s instead of %s", f.Duration.Round(time.Second), duration))
+		}
+	}
+
+	if size, ok := titleSize(title); ok {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if ratio := float64(fi.Size()) / float64(size); ratio < verifyMinSizeRatio || ratio > verifyMaxSizeRatio {
+			problems = append(problems, fmt.Sprintf("size %d bytes instead of about %d", fi.Size(), size))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("file does not match title %d: %s", title.Index, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// titleSize returns the size of the title in bytes, preferably from the exact
+// DiscSizeBytes attribute, else from the rounded DiskSize attribute.
+func titleSize(title *makemkv.Title) (int64, bool) {
+	if size, err := title.GetAttrInt(defs.DiscSizeBytes); err == nil && size > 0 {
+		return int64(size), true
+	}
+
+	v, unit, ok := strings.Cut(title.GetAttrDefault(defs.DiskSize, ""), " ")
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n <= 0 || diskSizeUnits[unit] == 0 {
+		return 0, false
+	}
+
+	return int64(n * float64(diskSizeUnits[unit])), true
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/curt-hash/mkvbot/pkg/makemkv"
+	"github.com/curt-hash/mkvbot/pkg/makemkv/defs"
+	"github.com/curt-hash/mkvbot/pkg/matroska"
+)
+
+// newVerifyTitle returns a title with the given duration and size in bytes
+// and a video stream, two audio streams and two subtitle streams.
+func newVerifyTitle(duration string, size int) *makemkv.Title {
+	title := &makemkv.Title{
+		Info: makemkv.Info{
+			{ID: int(defs.Duration), Value: makemkv.Str(duration)},
+			{ID: int(defs.DiscSizeBytes), Value: makemkv.Str(strconv.Itoa(size))},
+		},
+	}
+	for i, typ := range []defs.TypeCode{defs.TypeCodeVideo, defs.TypeCodeAudio, defs.TypeCodeAudio, defs.TypeCodeSubtitles, defs.TypeCodeSubtitles} {
+		title.GetStream(i).Info = makemkv.Info{{ID: int(defs.Type), Code: int(typ)}}
+	}
+
+	return title
+}
+
+// writeRip writes a Matroska file with the given numbers of video, audio and
+// subtitle tracks and the given duration, extended to size bytes.
+func writeRip(t *testing.T, video, audio, subtitles int, duration time.Duration, size int64) string {
+	t.Helper()
+
+	f := &matroska.File{Duration: duration}
+	for typ, n := range map[matroska.TrackType]int{
+		matroska.TrackTypeVideo:    video,
+		matroska.TrackTypeAudio:    audio,
+		matroska.TrackTypeSubtitle: subtitles,
+	} {
+		for range n {
+			f.Tracks = append(f.Tracks, &matroska.Track{Number: uint64(len(f.Tracks) + 1), Type: typ})
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "title.mkv")
+	fp, err := os.Create(path)
+	require.NoError(t, err)
+	defer fp.Close()
+
+	require.NoError(t, matroska.Write(fp, f))
+	require.NoError(t, fp.Truncate(size))
+
+	return path
+}
+
+func TestVerifyTitle(t *testing.T) {
+	const size = 1_000_000
+
+	for _, tc := range []struct {
+		name                    string
+		titleDuration           string
+		video, audio, subtitles int
+		duration                time.Duration
+		size                    int64
+		problems                []string
+	}{
+		{
+			name:  "complete",
+			video: 1, audio: 2, subtitles: 2,
+		},
+		{
+			name:  "fewer audio and subtitle tracks",
+			video: 1, audio: 1,
+		},
+		{
+			name:  "no audio tracks",
+			video: 1, subtitles: 2,
+			problems: []string{"0 audio tracks instead of 1 to 2"},
+		},
+		{
+			name:  "too many audio tracks",
+			video: 1, audio: 3,
+			problems: []string{"3 audio tracks instead of 1 to 2"},
+		},
+		{
+			name:  "too many subtitle tracks",
+			video: 1, audio: 2, subtitles: 3,
+			problems: []string{"3 subtitle tracks instead of at most 2"},
+		},
+		{
+			name:     "every track count is wrong",
+			video:    2,
+			problems: []string{"2 video tracks instead of 1", "0 audio tracks instead of 1 to 2"},
+		},
+		{
+			name:  "duration within 1%",
+			video: 1, audio: 2,
+			duration: time.Hour + 36*time.Second,
+		},
+		{
+			name:  "duration off by more than 1%",
+			video: 1, audio: 2,
+			duration: time.Hour - 37*time.Second,
+			problems: []string{"duration 59m23s instead of 1h0m0s"},
+		},
+		{
+			name:          "short duration within 2 seconds",
+			titleDuration: "0:01:00",
+			video:         1, audio: 2,
+			duration: 62 * time.Second,
+		},
+		{
+			name:          "short duration off by more than 2 seconds",
+			titleDuration: "0:01:00",
+			video:         1, audio: 2,
+			duration: 63 * time.Second,
+			problems: []string{"duration 1m3s instead of 1m0s"},
+		},
+		{
+			name:  "half the size",
+			video: 1, audio: 2,
+			size: size / 2,
+		},
+		{
+			name:  "less than half the size",
+			video: 1, audio: 2,
+			size:     size/2 - 1,
+			problems: []string{"size 499999 bytes instead of about 1000000"},
+		},
+		{
+			name:  "slightly larger",
+			video: 1, audio: 2,
+			size: size * 11 / 10,
+		},
+		{
+			name:  "much larger",
+			video: 1, audio: 2,
+			size:     size*11/10 + 1,
+			problems: []string{"size 1100001 bytes instead of about 1000000"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.titleDuration == "" {
+				tc.titleDuration = "1:00:00"
+			}
+			title := newVerifyTitle(tc.titleDuration, size)
+
+			if tc.duration == 0 {
+				tc.duration, _ = title.GetAttrDuration(defs.Duration)
+			}
+			if tc.size == 0 {
+				tc.size = size
+			}
+			path := writeRip(t, tc.video, tc.audio, tc.subtitles, tc.duration, tc.size)
+
+			err := verifyTitle(title, path)
+			if len(tc.problems) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, problem := range tc.problems {
+				assert.ErrorContains(t, err, problem)
+			}
+		})
+	}
+}
+
+func TestVerifyTitleNotMatroska(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "title.mkv")
+	require.NoError(t, os.WriteFile(path, []byte("not matroska"), 0600))
+	require.Error(t, verifyTitle(newVerifyTitle("1:00:00", 1000), path))
+}
+
+func TestTitleSize(t *testing.T) {
+	for _, tc := range []struct {
+		discSizeBytes, diskSize string
+		expected                int64
+		ok                      bool
+	}{
+		{"1234567", "1.2 MB", 1234567, true},
+		{"", "28.4 GB", 30494267801, true},
+		{"0", "700 MB", 700 << 20, true},
+		{"", "512 B", 512, true},
+		{"", "2 TB", 2 << 40, true},
+		{"", "28.4 XB", 0, false},
+		{"", "28.4GB", 0, false},
+		{"", "GB", 0, false},
+		{"", "lots GB", 0, false},
+		{"", "0 GB", 0, false},
+		{"", "-1 GB", 0, false},
+		{"", "", 0, false},
+	} {
+		title := &makemkv.Title{}
+		if tc.discSizeBytes != "" {
+			title.Info = append(title.Info, &makemkv.Attribute{ID: int(defs.DiscSizeBytes), Value: makemkv.Str(tc.discSizeBytes)})
+		}
+		if tc.diskSize != "" {
+			title.Info = append(title.Info, &makemkv.Attribute{ID: int(defs.DiskSize), Value: makemkv.Str(tc.diskSize)})
+		}
+
+		size, ok := titleSize(title)
+		assert.Equal(t, tc.ok, ok, "%+v", tc)
+		assert.Equal(t, tc.expected, size, "%+v", tc)
+	}
+}